@@ -3,31 +3,70 @@ package main
 import (
 	"context"
 	"errors"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/zYoma/gophermart/internal/app"
 	"github.com/zYoma/gophermart/internal/config"
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.uber.org/zap"
 )
 
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	// получаем конфигурацию
 	cfg, err := config.GetConfig()
 	if err != nil {
 		panic(err)
 	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
 
 	// инициализируем логер
-	if err := logger.Initialize(cfg.LogLevel); err != nil {
+	if err := logger.Initialize(cfg.LogLevel, logger.Sampling{
+		Initial:    cfg.LogSamplingInitial,
+		Thereafter: cfg.LogSamplingThereafter,
+	}); err != nil {
 		panic(err)
 	}
+	// сбрасываем буфер логера последним, после остановки всех компонентов
+	defer logger.Log.Sync()
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	go func() {
+		// после первого сигнала возвращаем обработку по умолчанию: повторный сигнал завершает процесс сразу
+		<-ctx.Done()
+		stop()
+	}()
+
+	// по SIGHUP перечитываем секреты из *_FILE
+	go reloadSecretsOnHangup(ctx, cfg)
+
+	// инициализируем трассировку
+	shutdownTracing, err := tracing.Initialize(ctx, cfg.TraceExporter, cfg.TraceEndpoint)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		// отправляем оставшиеся спаны, контекст приложения к этому моменту уже отменён
+		flushCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Log.Error("не удалось отправить спаны", zap.Error(err))
+		}
+	}()
 
 	// инициализация приложения
-	application, err := app.New(ctx, cfg)
+	application, err := app.New(cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -42,3 +81,23 @@ func main() {
 		panic(err)
 	}
 }
+
+func reloadSecretsOnHangup(ctx context.Context, cfg *config.Config) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			changed, err := cfg.ReloadSecrets()
+			if err != nil {
+				logger.Log.Error("не удалось перечитать секреты, продолжаем с прежними", zap.Error(err))
+				continue
+			}
+			logger.Log.Info("секреты перечитаны", zap.Strings("changed", changed))
+		}
+	}
+}
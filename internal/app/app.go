@@ -5,20 +5,30 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/zYoma/gophermart/internal/app/server"
 	"github.com/zYoma/gophermart/internal/config"
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/storage"
+	"go.uber.org/zap"
 
 	"github.com/zYoma/gophermart/internal/storage/postgres"
 )
 
 type App struct {
-	Server *server.HTTPServer
+	Server   *server.HTTPServer
+	provider storage.Provider
 }
 
 var ErrServerStoped = errors.New("server stoped")
 
-func New(ctx context.Context, cfg *config.Config) (*App, error) {
+// хранилище, умеющее отдавать статистику пула соединений
+type poolStatProvider interface {
+	PoolStat() *pgxpool.Stat
+}
+
+func New(cfg *config.Config) (*App, error) {
 	provider, err := postgres.New(cfg)
 	if err != nil {
 		return nil, err
@@ -28,8 +38,18 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
-	server := server.New(ctx, provider, cfg)
-	return &App{Server: server}, nil
+	// метрики состояния хранилища собираются в момент запроса /metrics
+	metrics.RegisterPendingOrders(provider.CountPendingOrders)
+	if pool, ok := provider.(poolStatProvider); ok {
+		metrics.RegisterPgxPool(pool.PoolStat)
+	}
+
+	server, err := server.New(provider, cfg)
+	if err != nil {
+		provider.Close()
+		return nil, err
+	}
+	return &App{Server: server, provider: provider}, nil
 }
 
 func (s *App) Run(ctx context.Context) error {
@@ -46,9 +66,13 @@ func (s *App) Run(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		// При получении сигнала завершения останавливаем сервер
-		if err := s.Server.Shutdown(ctx); err != nil {
-			return err
+		// При получении сигнала останавливаем сервер и фоновые задачи, затем закрываем хранилище
+		logger.Log.Info("получен сигнал остановки")
+		if err := s.Server.Shutdown(); err != nil {
+			logger.Log.Error("остановка прошла с ошибками", zap.Error(err))
+		}
+		if err := s.provider.Close(); err != nil {
+			logger.Log.Error("не удалось закрыть хранилище", zap.Error(err))
 		}
 		return ErrServerStoped
 	case err := <-errChan:
@@ -2,50 +2,95 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/zYoma/gophermart/internal/app/tasks"
 	"github.com/zYoma/gophermart/internal/config"
 	"github.com/zYoma/gophermart/internal/handlers"
+	"github.com/zYoma/gophermart/internal/logger"
 	"github.com/zYoma/gophermart/internal/storage"
 )
 
 type HTTPServer struct {
-	server *http.Server
-	wg     *sync.WaitGroup
+	server  *http.Server
+	service *handlers.HandlerService
+	cfg     *config.Config
+	// периодические задачи живут до остановки HTTP-сервера, а не до сигнала
+	stopTasks context.CancelFunc
+	wg        *sync.WaitGroup
+	workers   *tasks.Workers
 }
 
 func New(
-	ctx context.Context,
 	provider storage.Provider,
 	cfg *config.Config,
-) *HTTPServer {
+) (*HTTPServer, error) {
+
+	server := newHTTPServer(cfg)
+	if cfg.TLSEnabled() {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
 
 	// создаем сервис обработчик
 	service := handlers.New(provider, cfg)
 
-	// запускаем горутину для обработки заказов
+	// запускаем фоновые задачи: обработку заказов, сверку начислений, сгорание баллов,
+	// пересчёт уровней, снятие просроченных резервов, очистку ключей идемпотентности и удаление аккаунтов
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
-	wg.Add(1)
-	taskService := tasks.New(provider, cfg, &wg)
-	go taskService.UpdateOrdersStatus(ctx)
+	wg.Add(7)
+	taskService := tasks.New(provider, cfg, &wg, service.Workers())
+	go taskService.UpdateOrdersStatus(tasksCtx)
+	go taskService.ReconcileAccruals(tasksCtx)
+	go taskService.ExpirePoints(tasksCtx)
+	go taskService.RecomputeTiers(tasksCtx)
+	go taskService.ReleaseExpiredHolds(tasksCtx)
+	go taskService.CleanupIdempotencyKeys(tasksCtx)
+	go taskService.DeleteAccounts(tasksCtx)
 
 	// получаем роутер
-	router := service.GetRouter()
+	server.Handler = service.GetRouter()
 
-	server := &http.Server{
-		Addr:    cfg.RunAddr,
-		Handler: router,
-	}
 	return &HTTPServer{
-		server: server,
-		wg:     &wg,
+		server:    server,
+		service:   service,
+		cfg:       cfg,
+		stopTasks: stopTasks,
+		wg:        &wg,
+		workers:   service.Workers(),
+	}, nil
+}
+
+// newHTTPServer создаёт сервер с таймаутами из конфига: без них медленный клиент держит соединение бесконечно
+func newHTTPServer(cfg *config.Config) *http.Server {
+	return &http.Server{
+		Addr:              cfg.RunAddr,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+		// ошибки сервера (рукопожатия TLS, таймауты) пишем в общий лог
+		ErrorLog: zap.NewStdLog(logger.Log),
 	}
 }
 
 func (a *HTTPServer) Run() error {
-	err := a.server.ListenAndServe()
+	var err error
+	if a.server.TLSConfig != nil {
+		// сертификат отдаёт TLSConfig.GetCertificate, поэтому пути к файлам не передаём
+		err = a.server.ListenAndServeTLS("", "")
+	} else {
+		err = a.server.ListenAndServe()
+	}
 	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -53,7 +98,60 @@ func (a *HTTPServer) Run() error {
 	return nil
 }
 
-func (a *HTTPServer) Shutdown(ctx context.Context) error {
-	a.wg.Wait()
-	return a.server.Shutdown(ctx)
+// Shutdown останавливает сервис по шагам, у каждого шага свой таймаут:
+// снятие готовности, приём и завершение запросов, периодические задачи, обработка заказов.
+// Ошибки шагов не прерывают остановку, а возвращаются вместе в конце.
+func (a *HTTPServer) Shutdown() error {
+	var errs []error
+
+	// снимаем готовность и даём балансировщику время убрать под из ротации, пока слушатель ещё открыт
+	a.service.StartDraining()
+	logger.Log.Info("готовность снята, ожидание перед остановкой сервера", zap.Duration("delay", a.cfg.ShutdownDelay))
+	time.Sleep(a.cfg.ShutdownDelay)
+
+	// перестаём принимать соединения и ждём запросы в обработке
+	httpCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownHTTPTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(httpCtx); err != nil {
+		logger.Log.Error("запросы не завершились вовремя, соединения закрыты принудительно", zap.Error(err))
+		errs = append(errs, err, a.server.Close())
+	}
+	logger.Log.Info("HTTP-сервер остановлен")
+
+	// останавливаем опрос системы лояльности и остальные периодические задачи
+	a.stopTasks()
+	if err := waitTimeout(a.wg, a.cfg.ShutdownTasksTimeout); err != nil {
+		logger.Log.Error("периодические задачи не остановились вовремя", zap.Error(err))
+		errs = append(errs, err)
+	}
+	logger.Log.Info("периодические задачи остановлены")
+
+	// даём уже начатой обработке заказов записать результат; прерванные заказы опросим после перезапуска
+	accrualCtx, cancelAccrual := context.WithTimeout(context.Background(), a.cfg.ShutdownAccrualTimeout)
+	defer cancelAccrual()
+	if err := a.workers.Shutdown(accrualCtx); err != nil {
+		logger.Log.Warn("обработка заказов прервана, они будут опрошены после перезапуска", zap.Error(err))
+		errs = append(errs, err)
+	}
+	logger.Log.Info("обработка заказов остановлена")
+
+	return errors.Join(errs...)
+}
+
+// ждёт WaitGroup не дольше timeout
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return context.DeadlineExceeded
+	}
 }
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zYoma/gophermart/internal/config"
+)
+
+func TestServer_DropsSlowClients(t *testing.T) {
+	cfg := &config.Config{
+		ServerReadHeaderTimeout: 200 * time.Millisecond,
+		ServerReadTimeout:       time.Second,
+		ServerWriteTimeout:      time.Second,
+		ServerIdleTimeout:       time.Second,
+	}
+	srv := newHTTPServer(cfg)
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// клиент отправляет заголовки по одному байту, так и не завершив запрос
+	started := time.Now()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for _, b := range []byte("GET /api/user/orders HTTP/1.1\r\nHost: localhost\r\nX-Slow: ") {
+			if _, err := conn.Write([]byte{b}); err != nil {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		for {
+			if _, err := conn.Write([]byte("a")); err != nil {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	// сервер закрывает соединение по ReadHeaderTimeout, не дожидаясь конца заголовков
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	response, err := io.ReadAll(conn)
+	// сервер закрывает сокет с непрочитанными байтами клиента, поэтому ядро может ответить RST вместо FIN
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("соединение должен закрыть сервер, а не дедлайн теста")
+	}
+	if err != nil {
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+	}
+	assert.Less(t, time.Since(started), 2*time.Second)
+	// net/http может успеть ответить ошибкой перед закрытием, но не обработать запрос
+	assert.NotContains(t, string(response), "200 OK")
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("сервер не закрыл соединение медленного клиента")
+	}
+}
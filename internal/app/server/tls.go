@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/config"
+	"github.com/zYoma/gophermart/internal/logger"
+)
+
+// как часто при рукопожатии проверяем, не поменялись ли файлы сертификата
+const certCheckInterval = time.Second
+
+var (
+	ErrLoadCertificate = errors.New("load TLS certificate")
+	ErrLoadClientCA    = errors.New("load TLS client CA")
+)
+
+var tlsVersions = map[string]uint16{
+	config.TLSVersion12: tls.VersionTLS12,
+	config.TLSVersion13: tls.VersionTLS13,
+}
+
+// newTLSConfig собирает настройки TLS: сертификат перечитывается при изменении файлов,
+// HTTP/2 согласуется через ALPN, клиентский сертификат проверяется, если задан CA партнёров.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tlsVersions[cfg.TLSMinVersion],
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLoadClientCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in %s", ErrLoadClientCA, cfg.TLSClientCAFile)
+		}
+		// сертификат нужен только партнёрскому API, поэтому на уровне TLS он необязателен:
+		// предъявленный проверяется здесь, а его наличие требует middleware партнёрских маршрутов
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// certReloader отдаёт сертификат сервера и перечитывает его, когда меняются файлы
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certStamp fileStamp
+	keyStamp  fileStamp
+	checkedAt time.Time
+}
+
+// время изменения и размер файла, по ним замечаем замену сертификата
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= certCheckInterval {
+		r.checkedAt = time.Now()
+		if r.changed() {
+			// сертификат могут записывать прямо сейчас, поэтому при ошибке продолжаем с прежним
+			if err := r.reload(); err != nil {
+				logger.Log.Error("не удалось перечитать TLS-сертификат, используется прежний", zap.Error(err))
+			} else {
+				logger.Log.Info("TLS-сертификат перечитан", zap.String("cert", r.certFile))
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) changed() bool {
+	certStamp, err := stampOf(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyStamp, err := stampOf(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return certStamp != r.certStamp || keyStamp != r.keyStamp
+}
+
+func (r *certReloader) reload() error {
+	certStamp, err := stampOf(r.certFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadCertificate, err)
+	}
+	keyStamp, err := stampOf(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadCertificate, err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadCertificate, err)
+	}
+	r.cert, r.certStamp, r.keyStamp = &cert, certStamp, keyStamp
+	return nil
+}
+
+func stampOf(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zYoma/gophermart/internal/config"
+)
+
+// testCA выпускает сертификаты для тестов
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue возвращает сертификат и ключ в PEM
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) writeServerCert(t *testing.T, dir string, commonName string) (string, string) {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, commonName, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// serveTLS запускает сервер с настройками TLS из конфига; обработчик отвечает протоколом и CN клиента
+func serveTLS(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	tlsConfig, err := newTLSConfig(cfg)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		TLSConfig: tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := ""
+			if len(r.TLS.VerifiedChains) > 0 {
+				client = r.TLS.VerifiedChains[0][0].Subject.CommonName
+			}
+			fmt.Fprintf(w, "%s %s", r.Proto, client)
+		}),
+	}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func newClient(ca *testCA, tlsConfig *tls.Config) *http.Client {
+	tlsConfig.RootCAs = ca.pool
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}}
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func servedCommonName(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestTLS_HTTP2AndMinVersion(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.writeServerCert(t, t.TempDir(), "server")
+	url := serveTLS(t, &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: config.TLSVersion13})
+
+	assert.Equal(t, "HTTP/2.0 ", get(t, newClient(ca, &tls.Config{}), url))
+
+	// клиент без TLS 1.3 не подключается
+	_, err := newClient(ca, &tls.Config{MaxVersion: tls.VersionTLS12}).Get(url)
+	assert.Error(t, err)
+}
+
+func TestTLS_CertificateReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeServerCert(t, dir, "first")
+	url := serveTLS(t, &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: config.TLSVersion12})
+
+	newConnClient := func() *http.Client {
+		client := newClient(ca, &tls.Config{})
+		client.Transport.(*http.Transport).DisableKeepAlives = true
+		return client
+	}
+	assert.Equal(t, "first", servedCommonName(t, newConnClient(), url))
+
+	// подменяем сертификат и сдвигаем время изменения, чтобы не зависеть от точности часов ФС
+	ca.writeServerCert(t, dir, "second")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+
+	assert.Eventually(t, func() bool {
+		return servedCommonName(t, newConnClient(), url) == "second"
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// повреждённый файл не ломает рукопожатия: остаётся прежний сертификат
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	past := future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, past, past))
+	time.Sleep(certCheckInterval + 100*time.Millisecond)
+	assert.Equal(t, "second", servedCommonName(t, newConnClient(), url))
+}
+
+func TestTLS_ClientCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeServerCert(t, dir, "server")
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+
+	url := serveTLS(t, &config.Config{
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSMinVersion:   config.TLSVersion12,
+		TLSClientCAFile: caFile,
+	})
+
+	// без сертификата соединение устанавливается, решение принимают обработчики
+	assert.Equal(t, "HTTP/2.0 ", get(t, newClient(ca, &tls.Config{}), url))
+
+	certPEM, keyPEM := ca.issue(t, "partner", x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0 partner", get(t, newClient(ca, &tls.Config{Certificates: []tls.Certificate{clientCert}}), url))
+
+	// сертификат чужого CA отклоняется при рукопожатии
+	foreignPEM, foreignKey := newTestCA(t).issue(t, "stranger", x509.ExtKeyUsageClientAuth)
+	foreignCert, err := tls.X509KeyPair(foreignPEM, foreignKey)
+	require.NoError(t, err)
+	_, err = newClient(ca, &tls.Config{Certificates: []tls.Certificate{foreignCert}}).Get(url)
+	assert.Error(t, err)
+}
+
+func TestTLS_ConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeServerCert(t, dir, "server")
+	emptyCA := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(emptyCA, []byte("no certificates"), 0o600))
+
+	_, err := newTLSConfig(&config.Config{TLSCertFile: filepath.Join(dir, "missing.crt"), TLSKeyFile: keyFile})
+	assert.ErrorIs(t, err, ErrLoadCertificate)
+
+	_, err = newTLSConfig(&config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: emptyCA})
+	assert.ErrorIs(t, err, ErrLoadClientCA)
+}
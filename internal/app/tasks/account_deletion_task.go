@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// с определённым интервалом обезличивает аккаунты, срок ожидания удаления которых истёк;
+// финансовые записи остаются под новым логином
+func (t *TaskService) DeleteAccounts(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "delete_accounts"))
+
+	ticker := time.NewTicker(t.cfg.AccountDeletionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.deleteAccounts(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TaskService) deleteAccounts(ctx context.Context) {
+	ctx, span := tracing.Tracer.Start(ctx, "DeleteAccounts")
+	defer span.End()
+
+	// ошибка по одному аккаунту не мешает обезличить остальные, поэтому число обезличенных учитываем всегда
+	anonymized, err := t.provider.AnonymizeDueAccounts(ctx)
+	span.SetAttributes(attribute.Int64("anonymized", anonymized))
+	if anonymized > 0 {
+		logger.FromContext(ctx).Info("аккаунты обезличены", zap.Int64("anonymized", anonymized))
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.FromContext(ctx).Error("не удалось обезличить часть аккаунтов", zap.Error(err))
+	}
+}
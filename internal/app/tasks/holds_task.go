@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// с определённым интервалом снимает резервы, которые магазин не списал и не отменил до истечения срока;
+// баллы возвращаются на баланс пользователя
+func (t *TaskService) ReleaseExpiredHolds(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "release_expired_holds"))
+
+	ticker := time.NewTicker(t.cfg.HoldReleaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.releaseExpiredHolds(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TaskService) releaseExpiredHolds(ctx context.Context) {
+	ctx, span := tracing.Tracer.Start(ctx, "ReleaseExpiredHolds")
+	defer span.End()
+
+	// ошибка по одному резерву не мешает снять остальные, поэтому число снятых учитываем всегда
+	released, err := t.provider.ReleaseExpiredHolds(ctx)
+	span.SetAttributes(attribute.Int64("released", released))
+	if released > 0 {
+		logger.FromContext(ctx).Info("просроченные резервы сняты", zap.Int64("released", released))
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.FromContext(ctx).Error("не удалось снять часть просроченных резервов", zap.Error(err))
+	}
+}
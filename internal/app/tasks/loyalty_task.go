@@ -10,23 +10,33 @@ import (
 	"github.com/zYoma/gophermart/internal/config"
 	"github.com/zYoma/gophermart/internal/integrations/loyalty"
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
 	"github.com/zYoma/gophermart/internal/storage"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+const idempotencyCleanupInterval = time.Hour
+
 type TaskService struct {
 	provider storage.Provider
 	cfg      *config.Config
 	wg       *sync.WaitGroup
+	// обработка отдельных заказов переживает остановку опроса и завершается по своему таймауту
+	workers *Workers
 }
 
-func New(provider storage.Provider, cfg *config.Config, wg *sync.WaitGroup) *TaskService {
-	return &TaskService{provider: provider, cfg: cfg, wg: wg}
+func New(provider storage.Provider, cfg *config.Config, wg *sync.WaitGroup, workers *Workers) *TaskService {
+	return &TaskService{provider: provider, cfg: cfg, wg: wg, workers: workers}
 }
 
 // с определённым интервалом проверяет начисления в системе лояльности для заказов с не конечными статусами
 func (t *TaskService) UpdateOrdersStatus(ctx context.Context) {
 	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "update_orders_status"))
 
 	ticker := time.NewTicker(time.Duration(t.cfg.CheckOrderInterval) * time.Second)
 	defer ticker.Stop()
@@ -35,8 +45,34 @@ func (t *TaskService) UpdateOrdersStatus(ctx context.Context) {
 		select {
 		case <-ticker.C:
 			// сработал таймер
-			registeredOrders := t.getOrders(ctx)
-			t.startProccessed(ctx, registeredOrders)
+			pollCtx, span := tracing.Tracer.Start(ctx, "UpdateOrdersStatus")
+			registeredOrders := t.getOrders(pollCtx)
+			span.SetAttributes(attribute.Int("orders.count", len(registeredOrders)))
+			t.startProccessed(pollCtx, registeredOrders)
+			span.End()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// периодически удаляет истёкшие ключи идемпотентности
+func (t *TaskService) CleanupIdempotencyKeys(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "cleanup_idempotency_keys"))
+
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := t.provider.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				logger.FromContext(ctx).Error("не удалось удалить истёкшие ключи идемпотентности", zap.Error(err))
+				continue
+			}
+			logger.FromContext(ctx).Debug("удалены истёкшие ключи идемпотентности", zap.Int64("count", deleted))
 		case <-ctx.Done():
 			return
 		}
@@ -48,12 +84,19 @@ func (t *TaskService) startProccessed(ctx context.Context, orders []string) {
 		return
 	}
 
+	poll := trace.LinkFromContext(ctx)
 	for _, order := range orders {
 		// Избегаем проблемы захвата переменной в замыкании, копируя значение в локальную переменную цикла
 		order := order
-		go func() {
-			OrderProccessed(ctx, order, t.provider, t.cfg)
-		}()
+		started := t.workers.Go(func(workerCtx context.Context) {
+			metrics.AccrualWorkersBusy.Inc()
+			defer metrics.AccrualWorkersBusy.Dec()
+			OrderProccessed(logger.Inherit(workerCtx, ctx), order, t.provider, t.cfg, poll)
+		})
+		if !started {
+			// сервис останавливается, оставшиеся заказы подхватит следующий запуск
+			return
+		}
 	}
 
 }
@@ -61,29 +104,47 @@ func (t *TaskService) startProccessed(ctx context.Context, orders []string) {
 func (t *TaskService) getOrders(ctx context.Context) []string {
 	orders, err := t.provider.GetRegisteresOrders(ctx)
 	if err != nil {
-		logger.Log.Error("cannot get orders", zap.Error(err))
+		logger.FromContext(ctx).Error("cannot get orders", zap.Error(err))
 		return nil
 	}
 	return orders
 }
 
-func OrderProccessed(ctx context.Context, order string, provider storage.Provider, cfg *config.Config) {
-	orderResp, err := loyalty.GetPointsByOrder(fmt.Sprintf("%s/api/orders/%s", cfg.AcrualURL, order))
+// OrderProccessed запрашивает начисление по заказу и сохраняет результат.
+// Через links передаётся спан запроса, породившего фоновую обработку.
+func OrderProccessed(ctx context.Context, order string, provider storage.Provider, cfg *config.Config, links ...trace.Link) {
+	ctx, span := tracing.Tracer.Start(ctx, "OrderProccessed",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.String("order.number", order)),
+	)
+	defer span.End()
+	ctx = logger.With(ctx, zap.String("order", order))
+
+	orderResp, err := loyalty.GetPointsByOrder(ctx, fmt.Sprintf("%s/api/orders/%s", cfg.AcrualURL, order))
 	if err != nil {
 		if errors.Is(err, loyalty.ErrNotFound) {
 			orderResp = &loyalty.OrderResponse{Order: order, Status: "PROCESSING"}
 		} else {
-			logger.Log.Error("не удалось получить данные по заказу", zap.Error(err))
+			logger.FromContext(ctx).Error("не удалось получить данные по заказу", zap.Error(err))
+			span.SetStatus(codes.Error, err.Error())
 			return
 		}
 	}
 
 	// обмновляем данные по заказу и пополняем баланс пользователя
-	errDB := provider.UpdateOrderAndAccrualPoints(ctx, orderResp)
+	credited, errDB := provider.UpdateOrderAndAccrualPoints(ctx, orderResp)
 	if errDB != nil {
-		logger.Log.Error("не удалось обновить заказ", zap.Error(err))
+		logger.FromContext(ctx).Error("не удалось обновить заказ", zap.Error(errDB))
+		span.RecordError(errDB)
+		span.SetStatus(codes.Error, errDB.Error())
 		return
 	}
+	span.SetAttributes(attribute.String("order.status", string(orderResp.Status)))
+
+	// в метрику идёт начисленное пользователю с учётом множителя уровня, а не сумма системы лояльности
+	if credited > 0 {
+		metrics.PointsAccrued.Add(credited)
+	}
 
-	logger.Log.Sugar().Infof("заказ %s обработан. Записан статус: %s", order, orderResp.Status)
+	logger.FromContext(ctx).Info("заказ обработан", zap.String("status", string(orderResp.Status)))
 }
@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// с определённым интервалом сжигает баллы, срок которых истёк, и записывает их в историю сгорания
+func (t *TaskService) ExpirePoints(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "expire_points"))
+
+	ticker := time.NewTicker(t.cfg.PointsExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.expirePoints(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TaskService) expirePoints(ctx context.Context) {
+	ctx, span := tracing.Tracer.Start(ctx, "ExpirePoints")
+	defer span.End()
+
+	// ошибка по одному пользователю не мешает сжечь баллы остальных, поэтому отчёт учитываем всегда
+	report, err := t.provider.ExpirePoints(ctx)
+	metrics.PointsExpired.Add(report.Points)
+	span.SetAttributes(attribute.Int("users", report.Users), attribute.Float64("points", report.Points))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.FromContext(ctx).Error("не удалось сжечь просроченные баллы", zap.Error(err))
+	}
+	if report.Users > 0 {
+		logger.FromContext(ctx).Info("просроченные баллы сожжены",
+			zap.Int("users", report.Users),
+			zap.Float64("points", report.Points),
+		)
+	}
+}
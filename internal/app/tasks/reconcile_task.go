@@ -0,0 +1,154 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/integrations/loyalty"
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var errAccrualNotFinal = errors.New("accrual is not final yet")
+
+// ReconcileReport итоги одного прохода сверки начислений
+type ReconcileReport struct {
+	Checked       int
+	Discrepancies int
+	Applied       int
+	Clamped       int
+	Held          int
+	Failed        int
+}
+
+// с определённым интервалом сверяет начисления по обработанным заказам с системой лояльности,
+// которая может пересчитать их в любой момент
+func (t *TaskService) ReconcileAccruals(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "reconcile_accruals"))
+
+	if t.cfg.ReconcileInterval <= 0 {
+		logger.FromContext(ctx).Info("сверка начислений отключена")
+		return
+	}
+
+	ticker := time.NewTicker(t.cfg.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report := t.reconcile(ctx)
+			logger.FromContext(ctx).Info("сверка начислений завершена",
+				zap.Int("checked", report.Checked),
+				zap.Int("discrepancies", report.Discrepancies),
+				zap.Int("applied", report.Applied),
+				zap.Int("clamped", report.Clamped),
+				zap.Int("held", report.Held),
+				zap.Int("failed", report.Failed),
+			)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TaskService) reconcile(ctx context.Context) ReconcileReport {
+	var report ReconcileReport
+
+	ctx, span := tracing.Tracer.Start(ctx, "ReconcileAccruals")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("reconcile.checked", report.Checked),
+			attribute.Int("reconcile.discrepancies", report.Discrepancies),
+			attribute.Int("reconcile.failed", report.Failed),
+		)
+		span.End()
+	}()
+
+	since := time.Now().Add(-t.cfg.ReconcileWindow)
+	orders, err := t.provider.GetProcessedOrdersSince(ctx, since)
+	if err != nil {
+		logger.FromContext(ctx).Error("не удалось получить обработанные заказы", zap.Error(err))
+		return report
+	}
+
+	policy := models.NegativeBalancePolicy(t.cfg.NegativeBalancePolicy)
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return report
+		}
+		report.Checked++
+		orderCtx := logger.With(ctx, zap.String("order", order.Number))
+
+		newAccrual, err := upstreamAccrual(orderCtx, order.Number, t.cfg.AcrualURL)
+		if err != nil {
+			if !errors.Is(err, loyalty.ErrNotFound) && !errors.Is(err, errAccrualNotFinal) {
+				logger.FromContext(orderCtx).Error("не удалось получить данные по заказу", zap.Error(err))
+				report.Failed++
+			}
+			continue
+		}
+		if newAccrual == order.Accrual {
+			continue
+		}
+
+		adjustment, err := t.provider.AdjustOrderAccrual(orderCtx, order.Number, newAccrual, policy)
+		if err != nil {
+			logger.FromContext(orderCtx).Error("не удалось скорректировать начисление", zap.Error(err))
+			report.Failed++
+			continue
+		}
+		if adjustment == nil {
+			continue
+		}
+
+		report.Discrepancies++
+		switch adjustment.Status {
+		case models.AdjustmentApplied:
+			report.Applied++
+		case models.AdjustmentClamped:
+			report.Clamped++
+		case models.AdjustmentHeld:
+			report.Held++
+		}
+
+		logger.FromContext(ctx).Warn("начисление по заказу изменилось в системе лояльности",
+			zap.String("order", adjustment.Order),
+			zap.String("user", adjustment.UserLogin),
+			zap.Float64("previous_accrual", adjustment.PreviousAccrual),
+			zap.Float64("new_accrual", adjustment.NewAccrual),
+			zap.Float64("applied", adjustment.Applied),
+			zap.String("status", string(adjustment.Status)),
+		)
+	}
+
+	return report
+}
+
+// актуальное начисление по заказу в системе лояльности; отказ в расчёте означает нулевое начисление
+func upstreamAccrual(ctx context.Context, order string, acrualURL string) (float64, error) {
+	orderResp, err := loyalty.GetPointsByOrder(ctx, fmt.Sprintf("%s/api/orders/%s", acrualURL, order))
+	if err != nil {
+		return 0, err
+	}
+
+	switch orderResp.Status {
+	case loyalty.StatusProcessed:
+		if orderResp.Accrual == nil {
+			return 0, nil
+		}
+		return *orderResp.Accrual, nil
+	case loyalty.StatusInvalid:
+		return 0, nil
+	default:
+		// расчёт снова в процессе, сверим в следующий раз
+		return 0, errAccrualNotFinal
+	}
+}
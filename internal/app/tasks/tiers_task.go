@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// с определённым интервалом пересчитывает уровни пользователей по начислениям за скользящее окно;
+// новый множитель действует для начислений, обработанных после пересчёта
+func (t *TaskService) RecomputeTiers(ctx context.Context) {
+	defer t.wg.Done()
+	ctx = logger.With(ctx, zap.String("task", "recompute_tiers"))
+
+	ticker := time.NewTicker(t.cfg.TierRecomputeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.recomputeTiers(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TaskService) recomputeTiers(ctx context.Context) {
+	ctx, span := tracing.Tracer.Start(ctx, "RecomputeTiers")
+	defer span.End()
+
+	changed, err := t.provider.RecomputeTiers(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.FromContext(ctx).Error("не удалось пересчитать уровни пользователей", zap.Error(err))
+		return
+	}
+	span.SetAttributes(attribute.Int64("changed", changed))
+	if changed > 0 {
+		logger.FromContext(ctx).Info("уровни пользователей пересчитаны", zap.Int64("changed", changed))
+	}
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers отслеживает фоновую обработку заказов, чтобы при остановке дождаться её или отменить.
+// Прерванная обработка безопасна: заказ остаётся в не конечном статусе и будет опрошен снова.
+type Workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewWorkers() *Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workers{ctx: ctx, cancel: cancel}
+}
+
+// Go запускает обработку в отдельной горутине; после начала остановки новая работа не принимается
+func (w *Workers) Go(f func(ctx context.Context)) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		f(w.ctx)
+	}()
+	return true
+}
+
+// Shutdown ждёт завершения запущенной обработки. Если ctx истёк раньше, контекст обработчиков
+// отменяется, и Shutdown возвращает ошибку контекста, не дожидаясь их.
+func (w *Workers) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
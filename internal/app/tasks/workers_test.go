@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkers_ShutdownWaitsForInFlightWork(t *testing.T) {
+	workers := NewWorkers()
+	finished := make(chan struct{})
+	require.True(t, workers.Go(func(ctx context.Context) {
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, workers.Shutdown(ctx))
+
+	select {
+	case <-finished:
+	default:
+		t.Fatal("shutdown returned before work finished")
+	}
+}
+
+func TestWorkers_ShutdownCancelsWorkAfterTimeout(t *testing.T) {
+	workers := NewWorkers()
+	cancelled := make(chan struct{})
+	require.True(t, workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, workers.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("work was not cancelled")
+	}
+}
+
+func TestWorkers_RejectsWorkAfterShutdown(t *testing.T) {
+	workers := NewWorkers()
+	require.NoError(t, workers.Shutdown(context.Background()))
+
+	assert.False(t, workers.Go(func(ctx context.Context) {
+		t.Error("work started after shutdown")
+	}))
+}
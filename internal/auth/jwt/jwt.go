@@ -16,7 +16,10 @@ type Claims struct {
 
 const TokenExp = time.Hour * 3
 
-var ErrCreateToken = errors.New("create token")
+var (
+	ErrCreateToken  = errors.New("create token")
+	ErrInvalidToken = errors.New("invalid token")
+)
 
 // BuildJWTString создаёт токен и возвращает его в виде строки.
 func BuildJWTString(login string, secret string) (string, error) {
@@ -42,7 +45,26 @@ func BuildJWTString(login string, secret string) (string, error) {
 	return tokenString, nil
 }
 
-func GetUserID(tokenString string, secret string) string {
+// GetUserID возвращает пользователя из токена, подписанного любым из секретов; пустая строка, если токен не подходит.
+// Несколько секретов нужны на время ротации, чтобы выданные до неё токены продолжали работать.
+func GetUserID(tokenString string, secrets ...string) string {
+	var err error
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		var userID string
+		if userID, err = parseUserID(tokenString, secret); err == nil {
+			return userID
+		}
+	}
+	if err != nil {
+		logger.Log.Sugar().Errorf("Токен не валидный: %s", err)
+	}
+	return ""
+}
+
+func parseUserID(tokenString string, secret string) (string, error) {
 
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims,
@@ -53,14 +75,12 @@ func GetUserID(tokenString string, secret string) string {
 			return []byte(secret), nil
 		})
 	if err != nil {
-		logger.Log.Sugar().Errorf("Неожиданный метод подписи: %s", err)
-		return ""
+		return "", err
 	}
 
 	if !token.Valid {
-		logger.Log.Sugar().Errorf("Токен не валидный")
-		return ""
+		return "", ErrInvalidToken
 	}
 
-	return claims.UserID
+	return claims.UserID, nil
 }
@@ -1,77 +1,457 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envConfigFile     = "CONFIG_FILE"
+	envEnvironment    = "APP_ENV"
+	envServerAddress  = "RUN_ADDRESS"
+	envAcrualURL      = "ACCRUAL_SYSTEM_ADDRESS"
+	envLoggerLevel    = "LOG_LEVEL"
+	envSamplingFirst  = "LOG_SAMPLING_INITIAL"
+	envSamplingNext   = "LOG_SAMPLING_THEREAFTER"
+	envDSN            = "DATABASE_URI"
+	envDSNFile        = "DATABASE_URI_FILE"
+	envTokenSecret    = "TOKEN_SECRET"
+	envTokenFile      = "TOKEN_SECRET_FILE"
+	envOrderInterval  = "CHECK_ORDER_INTERVAL"
+	envIdempotencyTTL = "IDEMPOTENCY_KEY_TTL"
+	envAdminToken     = "ADMIN_TOKEN"
+	envAdminTokenFile = "ADMIN_TOKEN_FILE"
+	envReconcileInt   = "RECONCILE_INTERVAL"
+	envReconcileWin   = "RECONCILE_WINDOW"
+	envNegativePolicy = "RECONCILE_NEGATIVE_BALANCE_POLICY"
+	envTraceExporter  = "TRACE_EXPORTER"
+	envTraceEndpoint  = "TRACE_OTLP_ENDPOINT"
+	envShutdownDelay  = "SHUTDOWN_DELAY"
+	envShutdownHTTP   = "SHUTDOWN_HTTP_TIMEOUT"
+	envShutdownTasks  = "SHUTDOWN_TASKS_TIMEOUT"
+	envShutdownAcrual = "SHUTDOWN_ACCRUAL_TIMEOUT"
+	envPointsExpiry   = "POINTS_EXPIRY_MONTHS"
+	envExpiryInterval = "POINTS_EXPIRY_INTERVAL"
+	envExpiringSoon   = "POINTS_EXPIRING_SOON_WINDOW"
+	envTierWindow     = "TIER_WINDOW_MONTHS"
+	envTierInterval   = "TIER_RECOMPUTE_INTERVAL"
+	envTransferLimit  = "TRANSFER_DAILY_LIMIT"
+	envTransferCount  = "TRANSFER_DAILY_COUNT"
+	envReferrerReward = "REFERRAL_REFERRER_REWARD"
+	envRefereeReward  = "REFERRAL_REFEREE_REWARD"
+	envReferralLimit  = "REFERRAL_MONTHLY_LIMIT"
+	envWithdrawMax    = "WITHDRAWAL_MAX_SUM"
+	envWithdrawDaily  = "WITHDRAWAL_DAILY_LIMIT"
+	envFraudUploads   = "FRAUD_ORDER_HOURLY_LIMIT"
+	envFraudRatio     = "FRAUD_MIN_PROCESSED_RATIO"
+	envFraudRatioMin  = "FRAUD_RATIO_MIN_ORDERS"
+	envFraudNewIP     = "FRAUD_NEW_IP_WINDOW"
+	envHoldTTL        = "HOLD_TTL"
+	envSupportHook    = "SUPPORT_WEBHOOK_URL"
+	envDeletionDelay  = "ACCOUNT_DELETION_DELAY"
+	envDeletionInt    = "ACCOUNT_DELETION_INTERVAL"
+	envHoldInterval   = "HOLD_RELEASE_INTERVAL"
+	envReadHeader     = "SERVER_READ_HEADER_TIMEOUT"
+	envReadTimeout    = "SERVER_READ_TIMEOUT"
+	envWriteTimeout   = "SERVER_WRITE_TIMEOUT"
+	envIdleTimeout    = "SERVER_IDLE_TIMEOUT"
+	envMaxBodyBytes   = "MAX_BODY_BYTES"
+	envTLSCert        = "TLS_CERT_FILE"
+	envTLSKey         = "TLS_KEY_FILE"
+	envTLSMinVersion  = "TLS_MIN_VERSION"
+	envTLSClientCA    = "TLS_CLIENT_CA_FILE"
 )
 
-var flagRunAddr string
-var flagAcrualtURL string
-var flagLogLevel string
-var flagDSN string
-var flagTokenSecret string
-var flagCheckOrderInterval int
+const (
+	EnvironmentDev        = "dev"
+	EnvironmentProduction = "production"
+)
 
 const (
-	envServerAddress = "RUN_ADDRESS"
-	envAcrualURL     = "ACCRUAL_SYSTEM_ADDRESS"
-	envLoggerLevel   = "LOG_LEVEL"
-	envDSN           = "DATABASE_URI"
-	envTokenSecret   = "TOKEN_SECRET"
-	envOrderInterval = "CHECK_ORDER_INTERVAL"
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
 )
 
+// DefaultTokenSecret секрет для JWT по умолчанию, допустим только в окружении dev
+const DefaultTokenSecret = "secret_for_test_only"
+
+// Config настройки сервиса. Источники применяются по возрастанию приоритета:
+// значения по умолчанию < файл конфигурации < переменные окружения < флаги командной строки.
+// Ключи файла совпадают с именами переменных окружения в нижнем регистре.
 type Config struct {
-	RunAddr            string
-	AcrualURL          string
-	LogLevel           string
-	DSN                string
-	TokenSecret        string
-	CheckOrderInterval int
+	// Environment dev или production; вне dev запрещены небезопасные значения по умолчанию
+	Environment        string        `yaml:"app_env" toml:"app_env"`
+	RunAddr            string        `yaml:"run_address" toml:"run_address"`
+	AcrualURL          string        `yaml:"accrual_system_address" toml:"accrual_system_address"`
+	LogLevel           string        `yaml:"log_level" toml:"log_level"`
+	DSN                string        `yaml:"database_uri" toml:"database_uri"`
+	TokenSecret        string        `yaml:"token_secret" toml:"token_secret"`
+	CheckOrderInterval int           `yaml:"check_order_interval" toml:"check_order_interval"`
+	IdempotencyKeyTTL  time.Duration `yaml:"idempotency_key_ttl" toml:"idempotency_key_ttl"`
+	AdminToken         string        `yaml:"admin_token" toml:"admin_token"`
+	// ReconcileInterval интервал сверки обработанных заказов, 0 отключает сверку
+	ReconcileInterval     time.Duration `yaml:"reconcile_interval" toml:"reconcile_interval"`
+	ReconcileWindow       time.Duration `yaml:"reconcile_window" toml:"reconcile_window"`
+	NegativeBalancePolicy string        `yaml:"reconcile_negative_balance_policy" toml:"reconcile_negative_balance_policy"`
+	// LogSamplingInitial сколько одинаковых сообщений в секунду пишется без сэмплирования, 0 отключает его
+	LogSamplingInitial    int `yaml:"log_sampling_initial" toml:"log_sampling_initial"`
+	LogSamplingThereafter int `yaml:"log_sampling_thereafter" toml:"log_sampling_thereafter"`
+	// TraceExporter куда отправлять спаны: none, stdout или otlp
+	TraceExporter string `yaml:"trace_exporter" toml:"trace_exporter"`
+	TraceEndpoint string `yaml:"trace_otlp_endpoint" toml:"trace_otlp_endpoint"`
+	// ShutdownDelay сколько сервер продолжает принимать запросы после снятия готовности
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	// ShutdownHTTPTimeout сколько ждём завершения запросов в обработке
+	ShutdownHTTPTimeout time.Duration `yaml:"shutdown_http_timeout" toml:"shutdown_http_timeout"`
+	// ShutdownTasksTimeout сколько ждём остановки периодических задач
+	ShutdownTasksTimeout time.Duration `yaml:"shutdown_tasks_timeout" toml:"shutdown_tasks_timeout"`
+	// ShutdownAccrualTimeout сколько ждём обработки заказов, уже отправленных в систему лояльности
+	ShutdownAccrualTimeout time.Duration `yaml:"shutdown_accrual_timeout" toml:"shutdown_accrual_timeout"`
+	// PointsExpiryMonths через сколько месяцев после начисления сгорают баллы, 0 — не сгорают
+	PointsExpiryMonths int `yaml:"points_expiry_months" toml:"points_expiry_months"`
+	// PointsExpiryInterval как часто сжигаются просроченные баллы
+	PointsExpiryInterval time.Duration `yaml:"points_expiry_interval" toml:"points_expiry_interval"`
+	// PointsExpiringSoonWindow за сколько до сгорания баллы попадают в expiring_soon баланса
+	PointsExpiringSoonWindow time.Duration `yaml:"points_expiring_soon_window" toml:"points_expiring_soon_window"`
+	// TierWindowMonths за сколько последних месяцев начисления учитываются при расчёте уровня;
+	// сами уровни, их пороги и множители хранятся в таблице loyalty_tiers
+	TierWindowMonths int `yaml:"tier_window_months" toml:"tier_window_months"`
+	// TierRecomputeInterval как часто пересчитываются уровни пользователей
+	TierRecomputeInterval time.Duration `yaml:"tier_recompute_interval" toml:"tier_recompute_interval"`
+	// TransferDailyLimit сколько баллов пользователь может перевести другим за последние сутки, 0 — без ограничения
+	TransferDailyLimit float64 `yaml:"transfer_daily_limit" toml:"transfer_daily_limit"`
+	// TransferDailyCount сколько переводов пользователь может сделать за последние сутки, 0 — без ограничения
+	TransferDailyCount int `yaml:"transfer_daily_count" toml:"transfer_daily_count"`
+	// ReferralReferrerReward и ReferralRefereeReward сколько баллов получают пригласивший и приглашённый,
+	// когда первый заказ приглашённого обработан; обе нулевые — реферальная программа выключена
+	ReferralReferrerReward float64 `yaml:"referral_referrer_reward" toml:"referral_referrer_reward"`
+	ReferralRefereeReward  float64 `yaml:"referral_referee_reward" toml:"referral_referee_reward"`
+	// ReferralMonthlyLimit сколько наград пригласивший может получить за месяц, 0 — без ограничения
+	ReferralMonthlyLimit int `yaml:"referral_monthly_limit" toml:"referral_monthly_limit"`
+	// WithdrawalMaxSum сколько баллов можно списать или зарезервировать за раз, 0 — без ограничения
+	WithdrawalMaxSum float64 `yaml:"withdrawal_max_sum" toml:"withdrawal_max_sum"`
+	// WithdrawalDailyLimit сколько баллов пользователь может списать за последние сутки вместе с активными резервами, 0 — без ограничения
+	WithdrawalDailyLimit float64 `yaml:"withdrawal_daily_limit" toml:"withdrawal_daily_limit"`
+	// FraudOrderHourlyLimit сколько заказов пользователь может загрузить за час; сверх лимита загрузка
+	// блокируется, а аккаунт отправляется на проверку. 0 — без ограничения
+	FraudOrderHourlyLimit int `yaml:"fraud_order_hourly_limit" toml:"fraud_order_hourly_limit"`
+	// FraudMinProcessedRatio какая доля заказов в конечном статусе должна быть обработана с начислением;
+	// при меньшей доле загрузка блокируется, а аккаунт отправляется на проверку. 0 — правило выключено
+	FraudMinProcessedRatio float64 `yaml:"fraud_min_processed_ratio" toml:"fraud_min_processed_ratio"`
+	// FraudRatioMinOrders со скольких заказов в конечном статусе проверяется доля обработанных
+	FraudRatioMinOrders int `yaml:"fraud_ratio_min_orders" toml:"fraud_ratio_min_orders"`
+	// FraudNewIPWindow сколько после первого входа с нового адреса списания с него требуют подтверждения паролем, 0 — не требуют
+	FraudNewIPWindow time.Duration `yaml:"fraud_new_ip_window" toml:"fraud_new_ip_window"`
+	// SupportWebhookURL куда отправляются уведомления поддержке о новых спорах по заказам; пусто — только в лог
+	SupportWebhookURL string `yaml:"support_webhook_url" toml:"support_webhook_url"`
+	// AccountDeletionDelay сколько ждём после запроса на удаление аккаунта, прежде чем обезличить его;
+	// вход в аккаунт в это время отменяет удаление
+	AccountDeletionDelay time.Duration `yaml:"account_deletion_delay" toml:"account_deletion_delay"`
+	// AccountDeletionInterval как часто обезличиваются аккаунты, срок ожидания которых истёк
+	AccountDeletionInterval time.Duration `yaml:"account_deletion_interval" toml:"account_deletion_interval"`
+	// HoldTTL сколько живёт резерв баллов, если магазин его не списал и не отменил
+	HoldTTL time.Duration `yaml:"hold_ttl" toml:"hold_ttl"`
+	// HoldReleaseInterval как часто снимаются просроченные резервы
+	HoldReleaseInterval time.Duration `yaml:"hold_release_interval" toml:"hold_release_interval"`
+	// ServerReadHeaderTimeout сколько ждём заголовки запроса; защищает от медленных клиентов (slowloris)
+	ServerReadHeaderTimeout time.Duration `yaml:"server_read_header_timeout" toml:"server_read_header_timeout"`
+	ServerReadTimeout       time.Duration `yaml:"server_read_timeout" toml:"server_read_timeout"`
+	ServerWriteTimeout      time.Duration `yaml:"server_write_timeout" toml:"server_write_timeout"`
+	// ServerIdleTimeout сколько держим keep-alive соединение без запросов
+	ServerIdleTimeout time.Duration `yaml:"server_idle_timeout" toml:"server_idle_timeout"`
+	// MaxBodyBytes предельный размер тела запроса; у маршрутов с небольшими телами свои, меньшие пределы
+	MaxBodyBytes int `yaml:"max_body_bytes" toml:"max_body_bytes"`
+
+	// TLSCertFile и TLSKeyFile включают HTTPS; файлы перечитываются при изменении без перезапуска
+	TLSCertFile string `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file" toml:"tls_key_file"`
+	// TLSMinVersion минимальная версия TLS: 1.2 или 1.3
+	TLSMinVersion string `yaml:"tls_min_version" toml:"tls_min_version"`
+	// TLSClientCAFile сертификаты CA, которыми подписаны клиентские сертификаты партнёров;
+	// если задан, административное и партнёрское API требуют клиентский сертификат
+	TLSClientCAFile string `yaml:"tls_client_ca_file" toml:"tls_client_ca_file"`
+
+	// TokenSecretFile, AdminTokenFile и DSNFile файлы с секретами; их значения важнее заданных напрямую
+	// и перечитываются по SIGHUP
+	TokenSecretFile string `yaml:"token_secret_file" toml:"token_secret_file"`
+	AdminTokenFile  string `yaml:"admin_token_file" toml:"admin_token_file"`
+	DSNFile         string `yaml:"database_uri_file" toml:"database_uri_file"`
+
+	// ConfigFile путь к файлу конфигурации, из которого загружены настройки
+	ConfigFile string `yaml:"-" toml:"-"`
+	// PrintConfig напечатать итоговую конфигурацию и выйти
+	PrintConfig bool `yaml:"-" toml:"-"`
+
+	secrets *secretStore
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Environment:              EnvironmentDev,
+		RunAddr:                  ":8081",
+		AcrualURL:                "http://localhost:8080",
+		LogLevel:                 "info",
+		TokenSecret:              DefaultTokenSecret,
+		CheckOrderInterval:       60,
+		IdempotencyKeyTTL:        24 * time.Hour,
+		ReconcileInterval:        time.Hour,
+		ReconcileWindow:          30 * 24 * time.Hour,
+		NegativeBalancePolicy:    "clamp",
+		LogSamplingInitial:       100,
+		LogSamplingThereafter:    100,
+		TraceExporter:            "none",
+		ShutdownDelay:            5 * time.Second,
+		ShutdownHTTPTimeout:      10 * time.Second,
+		ShutdownTasksTimeout:     5 * time.Second,
+		ShutdownAccrualTimeout:   8 * time.Second,
+		PointsExpiryInterval:     time.Hour,
+		PointsExpiringSoonWindow: 30 * 24 * time.Hour,
+		TierWindowMonths:         12,
+		TierRecomputeInterval:    time.Hour,
+		TransferDailyLimit:       5000,
+		TransferDailyCount:       10,
+		ReferralReferrerReward:   100,
+		ReferralRefereeReward:    50,
+		ReferralMonthlyLimit:     10,
+		FraudOrderHourlyLimit:    100,
+		FraudMinProcessedRatio:   0.1,
+		FraudRatioMinOrders:      20,
+		FraudNewIPWindow:         time.Hour,
+		AccountDeletionDelay:     30 * 24 * time.Hour,
+		AccountDeletionInterval:  time.Hour,
+		HoldTTL:                  15 * time.Minute,
+		HoldReleaseInterval:      time.Minute,
+		ServerReadHeaderTimeout:  5 * time.Second,
+		ServerReadTimeout:        15 * time.Second,
+		ServerWriteTimeout:       30 * time.Second,
+		ServerIdleTimeout:        2 * time.Minute,
+		MaxBodyBytes:             1 << 20,
+		TLSMinVersion:            TLSVersion12,
+	}
 }
 
 func GetConfig() (*Config, error) {
+	return load(flag.CommandLine, os.Args[1:], os.Getenv)
+}
+
+func load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	cfg := defaultConfig()
+
 	// парсим аргументы командной строки
-	flag.StringVar(&flagRunAddr, "a", ":8081", "address and port to run server")
-	flag.StringVar(&flagAcrualtURL, "r", "http://localhost:8080", "accrual system url")
-	flag.StringVar(&flagLogLevel, "l", "info", "log level")
-	flag.StringVar(&flagDSN, "d", "", "DB DSN")
-	flag.StringVar(&flagTokenSecret, "s", "secret_for_test_only", "secret for jwt")
-	flag.IntVar(&flagCheckOrderInterval, "i", 60, "interval in seconds between attempts to check the reason")
-	flag.Parse()
+	fs.StringVar(&cfg.ConfigFile, "config", "", "path to YAML or TOML config file")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print effective config with secrets redacted and exit")
+	fs.StringVar(&cfg.Environment, "env", cfg.Environment, "environment: dev or production")
+	fs.StringVar(&cfg.RunAddr, "a", cfg.RunAddr, "address and port to run server")
+	fs.StringVar(&cfg.AcrualURL, "r", cfg.AcrualURL, "accrual system url")
+	fs.StringVar(&cfg.LogLevel, "l", cfg.LogLevel, "log level")
+	fs.IntVar(&cfg.LogSamplingInitial, "log-sampling-initial", cfg.LogSamplingInitial, "identical log entries per second written before sampling starts, 0 disables sampling")
+	fs.IntVar(&cfg.LogSamplingThereafter, "log-sampling-thereafter", cfg.LogSamplingThereafter, "after the initial entries only every Nth identical entry per second is written")
+	fs.StringVar(&cfg.DSN, "d", cfg.DSN, "DB DSN")
+	fs.StringVar(&cfg.TokenSecret, "s", cfg.TokenSecret, "secret for jwt")
+	fs.StringVar(&cfg.DSNFile, "dsn-file", cfg.DSNFile, "file with DB DSN, re-read on SIGHUP")
+	fs.StringVar(&cfg.TokenSecretFile, "token-secret-file", cfg.TokenSecretFile, "file with secret for jwt, re-read on SIGHUP")
+	fs.IntVar(&cfg.CheckOrderInterval, "i", cfg.CheckOrderInterval, "interval in seconds between attempts to check the reason")
+	fs.DurationVar(&cfg.IdempotencyKeyTTL, "idempotency-ttl", cfg.IdempotencyKeyTTL, "how long responses for Idempotency-Key are kept")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "bearer token for admin and partner API, empty disables it")
+	fs.StringVar(&cfg.AdminTokenFile, "admin-token-file", cfg.AdminTokenFile, "file with bearer token for admin and partner API, re-read on SIGHUP")
+	fs.DurationVar(&cfg.ReconcileInterval, "reconcile-interval", cfg.ReconcileInterval, "interval between reconciliations of processed orders, 0 disables it")
+	fs.DurationVar(&cfg.ReconcileWindow, "reconcile-window", cfg.ReconcileWindow, "how far back processed orders are reconciled")
+	fs.StringVar(&cfg.NegativeBalancePolicy, "reconcile-negative-policy", cfg.NegativeBalancePolicy, "what to do when a reversal makes balance negative: clamp or hold")
+	fs.StringVar(&cfg.TraceExporter, "trace-exporter", cfg.TraceExporter, "where to export traces: none, stdout or otlp")
+	fs.StringVar(&cfg.TraceEndpoint, "trace-endpoint", cfg.TraceEndpoint, "OTLP/HTTP traces endpoint URL, empty uses OTEL_EXPORTER_OTLP_* settings")
+	fs.DurationVar(&cfg.ShutdownDelay, "shutdown-delay", cfg.ShutdownDelay, "how long to keep serving after readiness is withdrawn on shutdown")
+	fs.DurationVar(&cfg.ShutdownHTTPTimeout, "shutdown-http-timeout", cfg.ShutdownHTTPTimeout, "how long to wait for in-flight HTTP requests on shutdown")
+	fs.DurationVar(&cfg.ShutdownTasksTimeout, "shutdown-tasks-timeout", cfg.ShutdownTasksTimeout, "how long to wait for periodic tasks to stop on shutdown")
+	fs.DurationVar(&cfg.ShutdownAccrualTimeout, "shutdown-accrual-timeout", cfg.ShutdownAccrualTimeout, "how long to wait for in-flight accrual processing on shutdown")
+	fs.IntVar(&cfg.PointsExpiryMonths, "points-expiry-months", cfg.PointsExpiryMonths, "months after accrual when points expire, 0 disables expiration")
+	fs.DurationVar(&cfg.PointsExpiryInterval, "points-expiry-interval", cfg.PointsExpiryInterval, "interval between runs that expire points")
+	fs.DurationVar(&cfg.PointsExpiringSoonWindow, "points-expiring-soon", cfg.PointsExpiringSoonWindow, "how far ahead balance reports points as expiring soon")
+	fs.IntVar(&cfg.TierWindowMonths, "tier-window-months", cfg.TierWindowMonths, "how many recent months of accruals count towards the loyalty tier")
+	fs.DurationVar(&cfg.TierRecomputeInterval, "tier-recompute-interval", cfg.TierRecomputeInterval, "interval between loyalty tier recomputations")
+	fs.Float64Var(&cfg.TransferDailyLimit, "transfer-daily-limit", cfg.TransferDailyLimit, "points a user may transfer to others within 24 hours, 0 disables the limit")
+	fs.IntVar(&cfg.TransferDailyCount, "transfer-daily-count", cfg.TransferDailyCount, "transfers a user may make within 24 hours, 0 disables the limit")
+	fs.Float64Var(&cfg.ReferralReferrerReward, "referral-referrer-reward", cfg.ReferralReferrerReward, "points credited to the referrer when the referee's first order is processed")
+	fs.Float64Var(&cfg.ReferralRefereeReward, "referral-referee-reward", cfg.ReferralRefereeReward, "points credited to the referee when their first order is processed")
+	fs.IntVar(&cfg.ReferralMonthlyLimit, "referral-monthly-limit", cfg.ReferralMonthlyLimit, "referral rewards a referrer may receive within a month, 0 disables the limit")
+	fs.Float64Var(&cfg.WithdrawalMaxSum, "withdrawal-max-sum", cfg.WithdrawalMaxSum, "points a single withdrawal or hold may take, 0 disables the limit")
+	fs.Float64Var(&cfg.WithdrawalDailyLimit, "withdrawal-daily-limit", cfg.WithdrawalDailyLimit, "points a user may withdraw within 24 hours including active holds, 0 disables the limit")
+	fs.IntVar(&cfg.FraudOrderHourlyLimit, "fraud-order-hourly-limit", cfg.FraudOrderHourlyLimit, "orders a user may upload within an hour before uploads are blocked, 0 disables the rule")
+	fs.Float64Var(&cfg.FraudMinProcessedRatio, "fraud-min-processed-ratio", cfg.FraudMinProcessedRatio, "minimum share of finished orders that must be processed, 0 disables the rule")
+	fs.IntVar(&cfg.FraudRatioMinOrders, "fraud-ratio-min-orders", cfg.FraudRatioMinOrders, "finished orders a user needs before the processed ratio is checked")
+	fs.DurationVar(&cfg.FraudNewIPWindow, "fraud-new-ip-window", cfg.FraudNewIPWindow, "how long withdrawals from a newly seen IP require password confirmation, 0 disables the rule")
+	fs.StringVar(&cfg.SupportWebhookURL, "support-webhook-url", cfg.SupportWebhookURL, "URL that receives support notifications about order disputes, empty only logs them")
+	fs.DurationVar(&cfg.AccountDeletionDelay, "account-deletion-delay", cfg.AccountDeletionDelay, "how long after a deletion request the account is anonymized, logging in meanwhile cancels it")
+	fs.DurationVar(&cfg.AccountDeletionInterval, "account-deletion-interval", cfg.AccountDeletionInterval, "interval between runs that anonymize accounts due for deletion")
+	fs.DurationVar(&cfg.HoldTTL, "hold-ttl", cfg.HoldTTL, "how long a points hold lives before it is released automatically")
+	fs.DurationVar(&cfg.HoldReleaseInterval, "hold-release-interval", cfg.HoldReleaseInterval, "interval between runs that release expired holds")
+	fs.DurationVar(&cfg.ServerReadHeaderTimeout, "read-header-timeout", cfg.ServerReadHeaderTimeout, "how long to wait for request headers")
+	fs.DurationVar(&cfg.ServerReadTimeout, "read-timeout", cfg.ServerReadTimeout, "how long to wait for the whole request including body")
+	fs.DurationVar(&cfg.ServerWriteTimeout, "write-timeout", cfg.ServerWriteTimeout, "how long writing a response may take")
+	fs.DurationVar(&cfg.ServerIdleTimeout, "idle-timeout", cfg.ServerIdleTimeout, "how long to keep idle keep-alive connections")
+	fs.IntVar(&cfg.MaxBodyBytes, "max-body-bytes", cfg.MaxBodyBytes, "maximum request body size in bytes")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", cfg.TLSCertFile, "TLS certificate file, enables HTTPS together with -tls-key")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", cfg.TLSKeyFile, "TLS private key file")
+	fs.StringVar(&cfg.TLSMinVersion, "tls-min-version", cfg.TLSMinVersion, "minimum TLS version: 1.2 or 1.3")
+	fs.StringVar(&cfg.TLSClientCAFile, "tls-client-ca", cfg.TLSClientCAFile, "CA bundle for partner client certificates, requires them for admin and partner API")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// запоминаем явно заданные флаги: они применяются последними, поверх файла и окружения
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if cfg.ConfigFile == "" {
+		cfg.ConfigFile = getenv(envConfigFile)
+	}
+	if cfg.ConfigFile != "" {
+		if err := loadFile(cfg.ConfigFile, cfg); err != nil {
+			return nil, err
+		}
+	}
 
 	// если есть переменные окружения, используем их значения
-	if envRunAddr := os.Getenv(envServerAddress); envRunAddr != "" {
-		flagRunAddr = envRunAddr
+	if err := applyEnv(cfg, getenv); err != nil {
+		return nil, err
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// секреты из файлов применяются последними, чтобы значения по умолчанию не перекрывали их
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
 	}
-	if envAcrualSystemURL := os.Getenv(envAcrualURL); envAcrualSystemURL != "" {
-		flagAcrualtURL = envAcrualSystemURL
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
-	if envLogLevel := os.Getenv(envLoggerLevel); envLogLevel != "" {
-		flagLogLevel = envLogLevel
+	cfg.initSecrets()
+	return cfg, nil
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	stringVars := map[string]*string{
+		envEnvironment:    &cfg.Environment,
+		envServerAddress:  &cfg.RunAddr,
+		envAcrualURL:      &cfg.AcrualURL,
+		envLoggerLevel:    &cfg.LogLevel,
+		envDSN:            &cfg.DSN,
+		envTokenSecret:    &cfg.TokenSecret,
+		envAdminToken:     &cfg.AdminToken,
+		envNegativePolicy: &cfg.NegativeBalancePolicy,
+		envTraceExporter:  &cfg.TraceExporter,
+		envTraceEndpoint:  &cfg.TraceEndpoint,
+		envTokenFile:      &cfg.TokenSecretFile,
+		envAdminTokenFile: &cfg.AdminTokenFile,
+		envDSNFile:        &cfg.DSNFile,
+		envTLSCert:        &cfg.TLSCertFile,
+		envTLSKey:         &cfg.TLSKeyFile,
+		envTLSMinVersion:  &cfg.TLSMinVersion,
+		envTLSClientCA:    &cfg.TLSClientCAFile,
+		envSupportHook:    &cfg.SupportWebhookURL,
 	}
-	if envDBDSN := os.Getenv(envDSN); envDBDSN != "" {
-		flagDSN = envDBDSN
+	ints := map[string]*int{
+		envSamplingFirst: &cfg.LogSamplingInitial,
+		envSamplingNext:  &cfg.LogSamplingThereafter,
+		envOrderInterval: &cfg.CheckOrderInterval,
+		envMaxBodyBytes:  &cfg.MaxBodyBytes,
+		envPointsExpiry:  &cfg.PointsExpiryMonths,
+		envTierWindow:    &cfg.TierWindowMonths,
+		envTransferCount: &cfg.TransferDailyCount,
+		envReferralLimit: &cfg.ReferralMonthlyLimit,
+		envFraudUploads:  &cfg.FraudOrderHourlyLimit,
+		envFraudRatioMin: &cfg.FraudRatioMinOrders,
 	}
-	if envJWTSecret := os.Getenv(envTokenSecret); envJWTSecret != "" {
-		flagTokenSecret = envJWTSecret
+	floats := map[string]*float64{
+		envTransferLimit:  &cfg.TransferDailyLimit,
+		envReferrerReward: &cfg.ReferralReferrerReward,
+		envRefereeReward:  &cfg.ReferralRefereeReward,
+		envWithdrawMax:    &cfg.WithdrawalMaxSum,
+		envWithdrawDaily:  &cfg.WithdrawalDailyLimit,
+		envFraudRatio:     &cfg.FraudMinProcessedRatio,
+	}
+	durations := map[string]*time.Duration{
+		envIdempotencyTTL: &cfg.IdempotencyKeyTTL,
+		envReconcileInt:   &cfg.ReconcileInterval,
+		envReconcileWin:   &cfg.ReconcileWindow,
+		envShutdownDelay:  &cfg.ShutdownDelay,
+		envShutdownHTTP:   &cfg.ShutdownHTTPTimeout,
+		envShutdownTasks:  &cfg.ShutdownTasksTimeout,
+		envShutdownAcrual: &cfg.ShutdownAccrualTimeout,
+		envExpiryInterval: &cfg.PointsExpiryInterval,
+		envExpiringSoon:   &cfg.PointsExpiringSoonWindow,
+		envTierInterval:   &cfg.TierRecomputeInterval,
+		envFraudNewIP:     &cfg.FraudNewIPWindow,
+		envDeletionDelay:  &cfg.AccountDeletionDelay,
+		envDeletionInt:    &cfg.AccountDeletionInterval,
+		envHoldTTL:        &cfg.HoldTTL,
+		envHoldInterval:   &cfg.HoldReleaseInterval,
+		envReadHeader:     &cfg.ServerReadHeaderTimeout,
+		envReadTimeout:    &cfg.ServerReadTimeout,
+		envWriteTimeout:   &cfg.ServerWriteTimeout,
+		envIdleTimeout:    &cfg.ServerIdleTimeout,
 	}
-	if envCheckOrderInterval := os.Getenv(envOrderInterval); envCheckOrderInterval != "" {
-		intValue, err := strconv.Atoi(envCheckOrderInterval)
-		if err != nil {
-			return nil, err
-		}
-		flagCheckOrderInterval = intValue
 
+	var errs []error
+	for name, target := range stringVars {
+		if value := getenv(name); value != "" {
+			*target = value
+		}
+	}
+	for name, target := range ints {
+		if value := getenv(name); value != "" {
+			intValue, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			*target = intValue
+		}
+	}
+	for name, target := range floats {
+		if value := getenv(name); value != "" {
+			floatValue, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			*target = floatValue
+		}
 	}
+	for name, target := range durations {
+		if value := getenv(name); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			*target = duration
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return &Config{
-		RunAddr:            flagRunAddr,
-		AcrualURL:          flagAcrualtURL,
-		LogLevel:           flagLogLevel,
-		DSN:                flagDSN,
-		TokenSecret:        flagTokenSecret,
-		CheckOrderInterval: flagCheckOrderInterval,
-	}, nil
+// Print печатает конфигурацию в YAML со скрытыми секретами; вывод можно использовать как файл конфигурации
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	return encoder.Encode(c.Redacted())
+}
+
+// TLSEnabled сообщает, что сервер должен отдавать HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrConfigFormat = errors.New("unsupported config file format")
+
+// loadFile читает файл конфигурации поверх уже заполненного cfg; формат определяется по расширению.
+// Неизвестные ключи считаются ошибкой, чтобы опечатка не превращалась в молча проигнорированную настройку.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// пустой файл допустим и ничего не меняет
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parse config file %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrConfigFormat, path)
+	}
+	return nil
+}
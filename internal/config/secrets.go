@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSecretFile = errors.New("secret file")
+
+// Secrets секреты, которые можно сменить без перезапуска: по SIGHUP они перечитываются из *_FILE
+type Secrets struct {
+	TokenSecret string
+	// PreviousTokenSecret секрет до последней ротации; токены, подписанные им, принимаются до истечения срока
+	PreviousTokenSecret string
+	AdminToken          string
+	DSN                 string
+}
+
+// файл с секретом и поле конфигурации, в которое он читается
+type secretFile struct {
+	name   string
+	path   string
+	target func(s *Secrets) *string
+}
+
+func (c *Config) secretFiles() []secretFile {
+	files := []secretFile{
+		{name: "token_secret", path: c.TokenSecretFile, target: func(s *Secrets) *string { return &s.TokenSecret }},
+		{name: "admin_token", path: c.AdminTokenFile, target: func(s *Secrets) *string { return &s.AdminToken }},
+		{name: "database_uri", path: c.DSNFile, target: func(s *Secrets) *string { return &s.DSN }},
+	}
+	configured := files[:0]
+	for _, f := range files {
+		if f.path != "" {
+			configured = append(configured, f)
+		}
+	}
+	return configured
+}
+
+// readSecretFiles читает секреты из файлов поверх значений из остальных источников
+func (c *Config) readSecretFiles() error {
+	secrets := Secrets{TokenSecret: c.TokenSecret, AdminToken: c.AdminToken, DSN: c.DSN}
+	if _, err := c.readSecrets(&secrets); err != nil {
+		return err
+	}
+	c.TokenSecret, c.AdminToken, c.DSN = secrets.TokenSecret, secrets.AdminToken, secrets.DSN
+	return nil
+}
+
+// readSecrets читает все настроенные файлы в secrets и возвращает имена изменившихся секретов
+func (c *Config) readSecrets(secrets *Secrets) ([]string, error) {
+	var changed []string
+	var errs []error
+	for _, f := range c.secretFiles() {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrSecretFile, f.name, err))
+			continue
+		}
+		// редакторы и echo добавляют перевод строки в конце, он не считается частью секрета
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%w: %s: file %s is empty", ErrSecretFile, f.name, f.path))
+			continue
+		}
+		if target := f.target(secrets); *target != value {
+			*target = value
+			changed = append(changed, f.name)
+		}
+	}
+	return changed, errors.Join(errs...)
+}
+
+// хранилище текущих секретов, общее для всех копий конфигурации
+type secretStore struct {
+	current atomic.Pointer[Secrets]
+}
+
+// Secrets возвращает текущие секреты. Поля TokenSecret, AdminToken и DSN хранят значения на момент запуска,
+// поэтому код, которому важна ротация, читает секреты только через этот метод.
+func (c *Config) Secrets() Secrets {
+	if c.secrets == nil {
+		return Secrets{TokenSecret: c.TokenSecret, AdminToken: c.AdminToken, DSN: c.DSN}
+	}
+	return *c.secrets.current.Load()
+}
+
+func (c *Config) initSecrets() {
+	c.secrets = &secretStore{}
+	c.secrets.current.Store(&Secrets{TokenSecret: c.TokenSecret, AdminToken: c.AdminToken, DSN: c.DSN})
+}
+
+// ReloadSecrets перечитывает секреты из *_FILE и возвращает имена изменившихся.
+// При любой ошибке текущие секреты остаются прежними.
+func (c *Config) ReloadSecrets() ([]string, error) {
+	if c.secrets == nil {
+		c.initSecrets()
+	}
+	current := c.Secrets()
+	next := current
+	changed, err := c.readSecrets(&next)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if next.TokenSecret == DefaultTokenSecret && c.Environment != EnvironmentDev {
+		return nil, fmt.Errorf("%w: token_secret: %w", ErrInvalidConfig, ErrDefaultSecret)
+	}
+	if next.DSN != current.DSN {
+		if _, err := pgx.ParseConfig(next.DSN); err != nil {
+			return nil, fmt.Errorf("%w: database_uri: cannot be parsed", ErrInvalidConfig)
+		}
+	}
+	if next.TokenSecret != current.TokenSecret {
+		next.PreviousTokenSecret = current.TokenSecret
+	}
+	c.secrets.current.Store(&next)
+	return changed, nil
+}
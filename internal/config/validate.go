@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/tracing"
+)
+
+// значение, которым в выводе конфигурации заменяются секреты
+const redacted = "REDACTED"
+
+var (
+	ErrInvalidConfig = errors.New("invalid config")
+	ErrDefaultSecret = errors.New("default token secret is allowed only in dev environment")
+)
+
+// пароль в DSN вида "host=... password=..."
+var dsnPasswordRe = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Validate проверяет итоговую конфигурацию и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Environment != EnvironmentDev && c.Environment != EnvironmentProduction {
+		invalid("app_env", "must be %q or %q, got %q", EnvironmentDev, EnvironmentProduction, c.Environment)
+	}
+	if _, _, err := net.SplitHostPort(c.RunAddr); err != nil {
+		invalid("run_address", "%s", err)
+	}
+	if err := validateHTTPURL(c.AcrualURL); err != nil {
+		invalid("accrual_system_address", "%s", err)
+	}
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		invalid("log_level", "%s", err)
+	}
+	if c.LogSamplingInitial < 0 {
+		invalid("log_sampling_initial", "must not be negative")
+	}
+	if c.LogSamplingThereafter < 0 {
+		invalid("log_sampling_thereafter", "must not be negative")
+	}
+	if c.DSN != "" {
+		// текст ошибки pgx может содержать DSN целиком, поэтому не выводим его
+		if _, err := pgx.ParseConfig(c.DSN); err != nil {
+			invalid("database_uri", "cannot be parsed")
+		}
+	}
+	if c.TokenSecret == "" {
+		invalid("token_secret", "must not be empty")
+	} else if c.TokenSecret == DefaultTokenSecret && c.Environment != EnvironmentDev {
+		errs = append(errs, fmt.Errorf("%w: token_secret: %w", ErrInvalidConfig, ErrDefaultSecret))
+	}
+	if c.CheckOrderInterval <= 0 {
+		invalid("check_order_interval", "must be positive")
+	}
+	if c.IdempotencyKeyTTL <= 0 {
+		invalid("idempotency_key_ttl", "must be positive")
+	}
+	if c.ReconcileInterval < 0 {
+		invalid("reconcile_interval", "must not be negative")
+	}
+	if c.ReconcileWindow <= 0 {
+		invalid("reconcile_window", "must be positive")
+	}
+	switch models.NegativeBalancePolicy(c.NegativeBalancePolicy) {
+	case models.NegativeBalanceClamp, models.NegativeBalanceHold:
+	default:
+		invalid("reconcile_negative_balance_policy", "must be %q or %q, got %q",
+			models.NegativeBalanceClamp, models.NegativeBalanceHold, c.NegativeBalancePolicy)
+	}
+	switch c.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		invalid("trace_exporter", "must be one of %q, %q, %q, got %q",
+			tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.TraceExporter)
+	}
+	if c.TraceEndpoint != "" {
+		if err := validateHTTPURL(c.TraceEndpoint); err != nil {
+			invalid("trace_otlp_endpoint", "%s", err)
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		invalid("shutdown_delay", "must not be negative")
+	}
+	if c.ShutdownHTTPTimeout <= 0 {
+		invalid("shutdown_http_timeout", "must be positive")
+	}
+	if c.ShutdownTasksTimeout <= 0 {
+		invalid("shutdown_tasks_timeout", "must be positive")
+	}
+	if c.ShutdownAccrualTimeout <= 0 {
+		invalid("shutdown_accrual_timeout", "must be positive")
+	}
+	if c.PointsExpiryMonths < 0 {
+		invalid("points_expiry_months", "must not be negative")
+	}
+	if c.PointsExpiryInterval <= 0 {
+		invalid("points_expiry_interval", "must be positive")
+	}
+	if c.PointsExpiringSoonWindow <= 0 {
+		invalid("points_expiring_soon_window", "must be positive")
+	}
+	if c.TierWindowMonths <= 0 {
+		invalid("tier_window_months", "must be positive")
+	}
+	if c.TierRecomputeInterval <= 0 {
+		invalid("tier_recompute_interval", "must be positive")
+	}
+	if c.TransferDailyLimit < 0 {
+		invalid("transfer_daily_limit", "must not be negative")
+	}
+	if c.TransferDailyCount < 0 {
+		invalid("transfer_daily_count", "must not be negative")
+	}
+	if c.ReferralReferrerReward < 0 {
+		invalid("referral_referrer_reward", "must not be negative")
+	}
+	if c.ReferralRefereeReward < 0 {
+		invalid("referral_referee_reward", "must not be negative")
+	}
+	if c.ReferralMonthlyLimit < 0 {
+		invalid("referral_monthly_limit", "must not be negative")
+	}
+	if c.WithdrawalMaxSum < 0 {
+		invalid("withdrawal_max_sum", "must not be negative")
+	}
+	if c.WithdrawalDailyLimit < 0 {
+		invalid("withdrawal_daily_limit", "must not be negative")
+	}
+	if c.FraudOrderHourlyLimit < 0 {
+		invalid("fraud_order_hourly_limit", "must not be negative")
+	}
+	if c.FraudMinProcessedRatio < 0 || c.FraudMinProcessedRatio > 1 {
+		invalid("fraud_min_processed_ratio", "must be between 0 and 1")
+	}
+	if c.FraudRatioMinOrders < 0 {
+		invalid("fraud_ratio_min_orders", "must not be negative")
+	}
+	if c.FraudNewIPWindow < 0 {
+		invalid("fraud_new_ip_window", "must not be negative")
+	}
+	if c.SupportWebhookURL != "" {
+		if err := validateHTTPURL(c.SupportWebhookURL); err != nil {
+			invalid("support_webhook_url", "%s", err)
+		}
+	}
+	// за время ожидания истекают все токены, выданные до запроса на удаление, а новые выдаются только
+	// при входе, который удаление отменяет; иначе токен пережил бы аккаунт и подошёл бы к новому с тем же логином
+	if c.AccountDeletionDelay < jwt.TokenExp {
+		invalid("account_deletion_delay", "must be at least the token lifetime %s", jwt.TokenExp)
+	}
+	if c.AccountDeletionInterval <= 0 {
+		invalid("account_deletion_interval", "must be positive")
+	}
+	if c.HoldTTL <= 0 {
+		invalid("hold_ttl", "must be positive")
+	}
+	if c.HoldReleaseInterval <= 0 {
+		invalid("hold_release_interval", "must be positive")
+	}
+	if c.ServerReadHeaderTimeout <= 0 {
+		invalid("server_read_header_timeout", "must be positive")
+	}
+	if c.ServerReadTimeout <= 0 {
+		invalid("server_read_timeout", "must be positive")
+	}
+	if c.ServerWriteTimeout <= 0 {
+		invalid("server_write_timeout", "must be positive")
+	}
+	if c.ServerIdleTimeout <= 0 {
+		invalid("server_idle_timeout", "must be positive")
+	}
+	if c.MaxBodyBytes <= 0 {
+		invalid("max_body_bytes", "must be positive")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		invalid("tls_cert_file", "tls_cert_file and tls_key_file must be set together")
+	}
+	if c.TLSMinVersion != TLSVersion12 && c.TLSMinVersion != TLSVersion13 {
+		invalid("tls_min_version", "must be %q or %q, got %q", TLSVersion12, TLSVersion13, c.TLSMinVersion)
+	}
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		invalid("tls_client_ca_file", "requires tls_cert_file and tls_key_file")
+	}
+	return errors.Join(errs...)
+}
+
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("host is missing")
+	}
+	return nil
+}
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены заглушкой
+func (c *Config) Redacted() Config {
+	r := *c
+	if r.TokenSecret != "" {
+		r.TokenSecret = redacted
+	}
+	if r.AdminToken != "" {
+		r.AdminToken = redacted
+	}
+	// в адресе вебхука обычно зашит токен
+	if r.SupportWebhookURL != "" {
+		r.SupportWebhookURL = redacted
+	}
+	r.DSN = redactDSN(r.DSN)
+	return r
+}
+
+// скрывает пароль в DSN как в URL-форме, так и в форме key=value
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPasswordRe.ReplaceAllString(dsn, "${1}"+redacted)
+}
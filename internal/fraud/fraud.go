@@ -0,0 +1,134 @@
+// Package fraud проверяет загрузку заказов и списания антифрод-правилами до выполнения операции.
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zYoma/gophermart/internal/config"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// Source сведения о пользователе для правил; реализуется хранилищем
+type Source interface {
+	GetFraudSignals(ctx context.Context, userLogin string, ip string) (models.FraudSignals, error)
+}
+
+// Rule антифрод-правило. Check возвращает решение и нужно ли отправить аккаунт на проверку;
+// ALLOW без отметки значит, что правило не сработало.
+type Rule struct {
+	Name       string
+	Operations []models.FraudOperation
+	Check      func(signals models.FraudSignals, now time.Time) (models.FraudDecision, bool)
+}
+
+func (r Rule) appliesTo(op models.FraudOperation) bool {
+	for _, o := range r.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+type Engine struct {
+	source Source
+	rules  []Rule
+	now    func() time.Time
+}
+
+func New(source Source, rules []Rule) *Engine {
+	return &Engine{source: source, rules: rules, now: time.Now}
+}
+
+// Rules правила с порогами из конфигурации; правило с нулевым порогом выключено
+func Rules(cfg *config.Config) []Rule {
+	var rules []Rule
+
+	if limit := cfg.FraudOrderHourlyLimit; limit > 0 {
+		// перебор номеров по алгоритму Луна даёт много загрузок за короткое время
+		rules = append(rules, Rule{
+			Name:       "order-upload-rate",
+			Operations: []models.FraudOperation{models.FraudOrderUpload},
+			Check: func(s models.FraudSignals, _ time.Time) (models.FraudDecision, bool) {
+				if s.OrdersLastHour >= limit {
+					return models.FraudBlock, true
+				}
+				return models.FraudAllow, false
+			},
+		})
+	}
+
+	if ratio, minOrders := cfg.FraudMinProcessedRatio, cfg.FraudRatioMinOrders; ratio > 0 {
+		// подобранные номера система лояльности не знает, и такие заказы становятся INVALID
+		rules = append(rules, Rule{
+			Name:       "low-processed-ratio",
+			Operations: []models.FraudOperation{models.FraudOrderUpload},
+			Check: func(s models.FraudSignals, _ time.Time) (models.FraudDecision, bool) {
+				if s.FinishedOrders > 0 && s.FinishedOrders >= minOrders &&
+					float64(s.ProcessedOrders)/float64(s.FinishedOrders) < ratio {
+					return models.FraudBlock, true
+				}
+				return models.FraudAllow, false
+			},
+		})
+	}
+
+	if window := cfg.FraudNewIPWindow; window > 0 {
+		// украденный токен или пароль обычно используют с нового адреса сразу после входа;
+		// самый первый адрес пользователя новым не считается
+		rules = append(rules, Rule{
+			Name:       "new-ip-withdrawal",
+			Operations: []models.FraudOperation{models.FraudWithdrawal},
+			Check: func(s models.FraudSignals, now time.Time) (models.FraudDecision, bool) {
+				if s.OtherIPs > 0 && (s.IPFirstSeen == nil || now.Sub(*s.IPFirstSeen) < window) {
+					return models.FraudChallenge, false
+				}
+				return models.FraudAllow, false
+			},
+		})
+	}
+
+	// пока аккаунт на проверке, списания подтверждаются паролем
+	rules = append(rules, Rule{
+		Name:       "flagged-account",
+		Operations: []models.FraudOperation{models.FraudWithdrawal},
+		Check: func(s models.FraudSignals, _ time.Time) (models.FraudDecision, bool) {
+			if s.Flagged {
+				return models.FraudChallenge, false
+			}
+			return models.FraudAllow, false
+		},
+	})
+
+	return rules
+}
+
+// Evaluate проверяет операцию всеми правилами для неё; действует самое строгое из решений
+func (e *Engine) Evaluate(ctx context.Context, op models.FraudOperation, userLogin string, ip string) (models.FraudVerdict, error) {
+	verdict := models.FraudVerdict{UserLogin: userLogin, Operation: op, IP: ip, Decision: models.FraudAllow}
+
+	signals, err := e.source.GetFraudSignals(ctx, userLogin, ip)
+	if err != nil {
+		return verdict, fmt.Errorf("get fraud signals: %w", err)
+	}
+
+	now := e.now()
+	for _, rule := range e.rules {
+		if !rule.appliesTo(op) {
+			continue
+		}
+		decision, flag := rule.Check(signals, now)
+		if decision == models.FraudAllow && !flag {
+			continue
+		}
+		verdict.Rules = append(verdict.Rules, rule.Name)
+		verdict.Flag = verdict.Flag || flag
+		if decision.Severity() > verdict.Decision.Severity() {
+			verdict.Decision = decision
+		}
+	}
+
+	return verdict, nil
+}
@@ -0,0 +1,128 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/config"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	cfg := &config.Config{
+		FraudOrderHourlyLimit:  10,
+		FraudMinProcessedRatio: 0.1,
+		FraudRatioMinOrders:    20,
+		FraudNewIPWindow:       time.Hour,
+	}
+	now := time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	testCases := []struct {
+		name             string
+		op               models.FraudOperation
+		signals          models.FraudSignals
+		expectedDecision models.FraudDecision
+		expectedRules    []string
+		expectedFlag     bool
+	}{
+		{
+			name:             "обычная загрузка",
+			op:               models.FraudOrderUpload,
+			signals:          models.FraudSignals{OrdersLastHour: 3, FinishedOrders: 30, ProcessedOrders: 20},
+			expectedDecision: models.FraudAllow,
+		},
+		{
+			name:             "часовой лимит загрузок",
+			op:               models.FraudOrderUpload,
+			signals:          models.FraudSignals{OrdersLastHour: 10},
+			expectedDecision: models.FraudBlock,
+			expectedRules:    []string{"order-upload-rate"},
+			expectedFlag:     true,
+		},
+		{
+			name:             "мало обработанных заказов",
+			op:               models.FraudOrderUpload,
+			signals:          models.FraudSignals{FinishedOrders: 40, ProcessedOrders: 3},
+			expectedDecision: models.FraudBlock,
+			expectedRules:    []string{"low-processed-ratio"},
+			expectedFlag:     true,
+		},
+		{
+			name:             "доля не проверяется на малой истории",
+			op:               models.FraudOrderUpload,
+			signals:          models.FraudSignals{FinishedOrders: 5},
+			expectedDecision: models.FraudAllow,
+		},
+		{
+			name:             "правила загрузки не действуют на списания",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{OrdersLastHour: 50, IPFirstSeen: &old},
+			expectedDecision: models.FraudAllow,
+		},
+		{
+			name:             "списание с нового адреса",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{IPFirstSeen: &recent, OtherIPs: 2},
+			expectedDecision: models.FraudChallenge,
+			expectedRules:    []string{"new-ip-withdrawal"},
+		},
+		{
+			name:             "списание с адреса без входа",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{OtherIPs: 1},
+			expectedDecision: models.FraudChallenge,
+			expectedRules:    []string{"new-ip-withdrawal"},
+		},
+		{
+			name:             "первый адрес пользователя",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{IPFirstSeen: &recent},
+			expectedDecision: models.FraudAllow,
+		},
+		{
+			name:             "адрес известен дольше окна",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{IPFirstSeen: &old, OtherIPs: 2},
+			expectedDecision: models.FraudAllow,
+		},
+		{
+			name:             "аккаунт на проверке",
+			op:               models.FraudWithdrawal,
+			signals:          models.FraudSignals{IPFirstSeen: &recent, OtherIPs: 1, Flagged: true},
+			expectedDecision: models.FraudChallenge,
+			expectedRules:    []string{"new-ip-withdrawal", "flagged-account"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := new(mocks.Source)
+			source.On("GetFraudSignals", mock.Anything, "user", "10.0.0.1").Return(tc.signals, nil)
+
+			engine := New(source, Rules(cfg))
+			engine.now = func() time.Time { return now }
+
+			verdict, err := engine.Evaluate(context.Background(), tc.op, "user", "10.0.0.1")
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedDecision, verdict.Decision)
+			assert.Equal(t, tc.expectedRules, verdict.Rules)
+			assert.Equal(t, tc.expectedFlag, verdict.Flag)
+		})
+	}
+}
+
+func TestRules_DisabledThresholds(t *testing.T) {
+	rules := Rules(&config.Config{})
+
+	// без порогов остаётся только подтверждение списаний с аккаунта на проверке
+	require.Len(t, rules, 1)
+	assert.Equal(t, "flagged-account", rules[0].Name)
+}
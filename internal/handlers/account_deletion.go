@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// планирует удаление аккаунта. Аккаунт обезличивается по истечении AccountDeletionDelay,
+// до этого удаление отменяется запросом DELETE или входом в аккаунт.
+func (h *HandlerService) RequestAccountDeletion(w http.ResponseWriter, r *http.Request) {
+
+	var request models.DeletionRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// удаление необратимо, поэтому одного токена мало
+	if !h.checkUserPassword(r, userID, request.Password) {
+		writeError(w, r, ErrWrongCredentials)
+		return
+	}
+
+	deletion, err := h.provider.RequestAccountDeletion(r.Context(), userID, h.cfg.AccountDeletionDelay)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("запрошено удаление аккаунта", zap.Time("delete_after", deletion.DeleteAfter))
+
+	w.WriteHeader(http.StatusAccepted)
+	render.JSON(w, r, deletion)
+}
+
+func (h *HandlerService) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.provider.CancelAccountDeletion(r.Context(), userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("удаление аккаунта отменено")
+	w.WriteHeader(http.StatusNoContent)
+}
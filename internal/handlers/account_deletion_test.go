@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/hash"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_AccountDeletion(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.AccountDeletionDelay = 30 * 24 * time.Hour
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	passHash, _ := hash.HashPassword("password")
+	requested := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		auth            bool
+		setup           func(m *mocks.StorageProvider)
+		expectedCode    int
+		expectedProblem models.ProblemCode
+	}{
+		{
+			name:   "запрос удаления",
+			method: http.MethodPost,
+			path:   "/api/user/deletion",
+			body:   `{"password":"password"}`,
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("RequestAccountDeletion", mock.Anything, "user", 30*24*time.Hour).
+					Return(models.AccountDeletion{RequestedAt: requested, DeleteAfter: requested.Add(30 * 24 * time.Hour)}, nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:   "неверный пароль",
+			method: http.MethodPost,
+			path:   "/api/user/deletion",
+			body:   `{"password":"wrong"}`,
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+			},
+			expectedCode:    http.StatusUnauthorized,
+			expectedProblem: models.ProblemInvalidCredentials,
+		},
+		{
+			name:         "запрос без пароля",
+			method:       http.MethodPost,
+			path:         "/api/user/deletion",
+			body:         `{}`,
+			auth:         true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "запрос без токена",
+			method:       http.MethodPost,
+			path:         "/api/user/deletion",
+			body:         `{"password":"password"}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "отмена удаления",
+			method: http.MethodDelete,
+			path:   "/api/user/deletion",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "отмена незапрошенного удаления",
+			method: http.MethodDelete,
+			path:   "/api/user/deletion",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(postgres.ErrDeletionNotFound)
+			},
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: models.ProblemDeletionNotFound,
+		},
+		{
+			name:   "вход отменяет удаление",
+			method: http.MethodPost,
+			path:   "/api/user/login",
+			body:   `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "вход в уже обезличенный аккаунт",
+			method: http.MethodPost,
+			path:   "/api/user/login",
+			body:   `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(postgres.ErrUserNotFound)
+			},
+			expectedCode:    http.StatusUnauthorized,
+			expectedProblem: models.ProblemInvalidCredentials,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+			allowFraudChecks(providerMock)
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			if tc.auth {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedProblem != "" {
+				var problem models.Problem
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+				assert.Equal(t, tc.expectedProblem, problem.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// создаёт бонусную кампанию, например приветственный бонус при регистрации
+func (h *HandlerService) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+
+	var campaign models.Campaign
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &campaign); err != nil {
+		return
+	}
+
+	if campaign.EndsAt != nil {
+		startsAt := time.Now()
+		if campaign.StartsAt != nil {
+			startsAt = *campaign.StartsAt
+		}
+		if !campaign.EndsAt.After(startsAt) {
+			writeValidationError(w, r, []models.FieldError{{
+				Field:   "ends_at",
+				Code:    "gtfield",
+				Message: "field ends_at must be after starts_at",
+			}})
+			return
+		}
+	}
+
+	created, err := h.provider.CreateCampaign(r.Context(), campaign)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("создана бонусная кампания",
+		zap.Int64("campaign", created.ID),
+		zap.String("event", string(created.Event)),
+		zap.Float64("amount", created.Amount),
+	)
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, created)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_CreateCampaign(t *testing.T) {
+	startsAt := time.Now().UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		name         string
+		body         string
+		callsStorage bool
+		expectedCode int
+	}{
+		{
+			name:         "бессрочная кампания с текущего момента",
+			body:         `{"name":"Приветственный бонус","event":"REGISTRATION","amount":50}`,
+			callsStorage: true,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "кампания с периодом",
+			body:         `{"name":"Весна","event":"REGISTRATION","amount":50,"starts_at":"2030-03-01T00:00:00Z","ends_at":"2030-06-01T00:00:00Z"}`,
+			callsStorage: true,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "сумма с долями копеек",
+			body:         `{"name":"Весна","event":"REGISTRATION","amount":0.001}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "конец раньше начала",
+			body:         `{"name":"Весна","event":"REGISTRATION","amount":50,"starts_at":"2030-06-01T00:00:00Z","ends_at":"2030-03-01T00:00:00Z"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "уже закончилась",
+			body:         `{"name":"Весна","event":"REGISTRATION","amount":50,"ends_at":"2020-03-01T00:00:00Z"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "неизвестное событие",
+			body:         `{"name":"Бонус","event":"BIRTHDAY","amount":50}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			if tc.callsStorage {
+				providerMock.On("CreateCampaign", mock.Anything, mock.MatchedBy(func(c models.Campaign) bool {
+					return c.Event == models.CampaignRegistration && c.Amount == 50
+				})).Return(models.Campaign{ID: 1, Event: models.CampaignRegistration, Amount: 50, StartsAt: &startsAt}, nil)
+			}
+
+			srv := httptest.NewServer(New(providerMock, GetMockConfig()).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/admin/campaigns", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer admin")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/integrations/support"
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/utils"
+)
+
+// открывает спор о заказе, который первым загрузил другой пользователь; решение принимает поддержка
+func (h *HandlerService) CreateDispute(w http.ResponseWriter, r *http.Request) {
+
+	var request models.DisputeRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	order := chi.URLParam(r, "number")
+	if !utils.CheckLuhn(order) {
+		writeError(w, r, ErrInvalidOrderNumber)
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	dispute, err := h.provider.CreateDispute(r.Context(), order, userID, request.Evidence)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("открыт спор по заказу",
+		zap.Int64("dispute_id", dispute.ID),
+		zap.String("order", order),
+		zap.String("owner", dispute.Owner),
+	)
+	h.notifySupport(r, dispute)
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, dispute)
+}
+
+// уведомление уходит в фоне: спор уже записан, и медленный вебхук не должен задерживать ответ
+func (h *HandlerService) notifySupport(r *http.Request, dispute models.Dispute) {
+	webhookURL := h.cfg.SupportWebhookURL
+	if webhookURL == "" {
+		return
+	}
+	reqCtx := r.Context()
+	h.workers.Go(func(ctx context.Context) {
+		ctx = logger.Inherit(ctx, reqCtx)
+		if err := support.NotifyDispute(ctx, webhookURL, dispute); err != nil {
+			logger.FromContext(ctx).Error("не удалось уведомить поддержку о споре",
+				zap.Int64("dispute_id", dispute.ID),
+				zap.Error(err),
+			)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/integrations/support"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_CreateDispute(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	dispute := models.Dispute{
+		ID: 3, Order: "2377225624", Claimant: "user", Owner: "other", Evidence: "receipt 0042",
+		Status: models.DisputeOpen, CreatedAt: time.Date(2024, 3, 28, 9, 0, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name            string
+		path            string
+		body            string
+		setup           func(m *mocks.StorageProvider)
+		expectedCode    int
+		expectedProblem models.ProblemCode
+	}{
+		{
+			name: "спор открыт",
+			path: "/api/user/orders/2377225624/disputes",
+			body: `{"evidence":"receipt 0042"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "2377225624", "user", "receipt 0042").Return(dispute, nil)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "без доказательств",
+			path:            "/api/user/orders/2377225624/disputes",
+			body:            `{"evidence":""}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: models.ProblemValidationFailed,
+		},
+		{
+			name:            "неверный номер заказа",
+			path:            "/api/user/orders/12345/disputes",
+			body:            `{"evidence":"receipt 0042"}`,
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: models.ProblemInvalidOrderNumber,
+		},
+		{
+			name: "заказ не загружен",
+			path: "/api/user/orders/2377225624/disputes",
+			body: `{"evidence":"receipt 0042"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "2377225624", "user", "receipt 0042").Return(models.Dispute{}, postgres.ErrOrderNotFound)
+			},
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: models.ProblemOrderNotFound,
+		},
+		{
+			name: "свой заказ",
+			path: "/api/user/orders/2377225624/disputes",
+			body: `{"evidence":"receipt 0042"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "2377225624", "user", "receipt 0042").Return(models.Dispute{}, postgres.ErrOrderAlreadyYours)
+			},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: models.ProblemOrderAlreadyYours,
+		},
+		{
+			name: "спор уже открыт",
+			path: "/api/user/orders/2377225624/disputes",
+			body: `{"evidence":"receipt 0042"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "2377225624", "user", "receipt 0042").Return(models.Dispute{}, postgres.ErrDisputeExists)
+			},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: models.ProblemDisputeExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedProblem != "" {
+				var problem models.Problem
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+				assert.Equal(t, tc.expectedProblem, problem.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerService_CreateDisputeNotifiesSupport(t *testing.T) {
+	notifications := make(chan support.DisputeNotification, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification support.DisputeNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err == nil {
+			notifications <- notification
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	cfg := GetMockConfig()
+	cfg.SupportWebhookURL = webhook.URL
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	providerMock := mocks.NewStorageProvider(t)
+	providerMock.On("CreateDispute", mock.Anything, "2377225624", "user", "receipt 0042").
+		Return(models.Dispute{ID: 3, Order: "2377225624", Claimant: "user", Owner: "other", Status: models.DisputeOpen}, nil)
+
+	service := New(providerMock, cfg)
+	srv := httptest.NewServer(service.GetRouter())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/orders/2377225624/disputes", bytes.NewBufferString(`{"evidence":"receipt 0042"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, "order_dispute_opened", notification.Event)
+		assert.Equal(t, int64(3), notification.Dispute.ID)
+		assert.Equal(t, "other", notification.Dispute.Owner)
+	case <-time.After(5 * time.Second):
+		t.Fatal("поддержка не получила уведомление")
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/utils"
+)
+
+// резервирует баллы под оплату заказа на время, пока магазин проводит платёж
+func (h *HandlerService) CreateHold(w http.ResponseWriter, r *http.Request) {
+
+	var request models.HoldRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !utils.CheckLuhn(request.Order) {
+		logger.FromContext(r.Context()).Error("номер заказа не валидный")
+		writeError(w, r, ErrInvalidOrderNumber)
+		return
+	}
+
+	hold, err := h.provider.CreateHold(r.Context(), userID, request.Order, request.Sum, h.cfg.HoldTTL, h.withdrawalLimits())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, hold)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_CreateHold(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.HoldTTL = 15 * time.Minute
+	cfg.WithdrawalMaxSum = 1000
+	cfg.WithdrawalDailyLimit = 5000
+	limits := models.WithdrawalLimits{Max: 1000, Daily: 5000}
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	testCases := []struct {
+		name         string
+		body         models.HoldRequest
+		storageErr   error
+		callsStorage bool
+		expectedCode int
+	}{
+		{
+			name:         "успешный резерв",
+			body:         models.HoldRequest{Order: "2377225624", Sum: 120},
+			callsStorage: true,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "нулевая сумма",
+			body:         models.HoldRequest{Order: "2377225624"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "невалидный номер заказа",
+			body:         models.HoldRequest{Order: "2377225625", Sum: 120},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "недостаточно средств",
+			body:         models.HoldRequest{Order: "2377225624", Sum: 120},
+			storageErr:   postgres.ErrFewPoints,
+			callsStorage: true,
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:         "сумма с долями копеек",
+			body:         models.HoldRequest{Order: "2377225624", Sum: 120.005},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "превышен суточный лимит",
+			body:         models.HoldRequest{Order: "2377225624", Sum: 120},
+			storageErr:   fmt.Errorf("%w: 100.00 of 5000.00 points left for today", postgres.ErrWithdrawalDaily),
+			callsStorage: true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "заказ уже оплачен",
+			body:         models.HoldRequest{Order: "2377225624", Sum: 120},
+			storageErr:   postgres.ErrOrderPaid,
+			callsStorage: true,
+			expectedCode: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			allowFraudChecks(providerMock)
+			created := time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC)
+			hold := models.Hold{
+				ID:        7,
+				Order:     tc.body.Order,
+				Sum:       tc.body.Sum,
+				Status:    models.HoldActive,
+				CreatedAt: created,
+				ExpiresAt: created.Add(cfg.HoldTTL),
+			}
+			if tc.callsStorage {
+				providerMock.On("CreateHold", mock.Anything, "user", tc.body.Order, tc.body.Sum, cfg.HoldTTL, limits).
+					Return(hold, tc.storageErr)
+			}
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			var buf bytes.Buffer
+			require.NoError(t, json.NewEncoder(&buf).Encode(tc.body))
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/holds", &buf)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+			if tc.expectedCode != http.StatusCreated {
+				return
+			}
+			var response models.Hold
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, hold, response)
+		})
+	}
+}
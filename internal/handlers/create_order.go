@@ -3,63 +3,64 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
-	"github.com/go-chi/render"
-	"go.uber.org/zap"
-
 	"github.com/zYoma/gophermart/internal/app/tasks"
 	"github.com/zYoma/gophermart/internal/logger"
-	"github.com/zYoma/gophermart/internal/models"
 	"github.com/zYoma/gophermart/internal/storage/postgres"
 	"github.com/zYoma/gophermart/internal/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (h *HandlerService) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
-	w.Header().Set("Content-Type", "application/json")
 	body, err := io.ReadAll(r.Body)
-	if err != nil || len(body) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, models.Error("empty body"))
+	if err != nil {
+		writeError(w, r, bodyError(err, ErrEmptyBody))
+		return
+	}
+	if len(body) == 0 {
+		writeError(w, r, ErrEmptyBody)
 		return
 	}
 
 	orderNumber := string(body)
 	if !utils.CheckLuhn(orderNumber) {
-		logger.Log.Error("номер заказа не валидный")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		render.JSON(w, r, models.Error("not valid order number"))
+		logger.FromContext(r.Context()).Error("номер заказа не валидный")
+		writeError(w, r, ErrInvalidOrderNumber)
 		return
 	}
 
 	userID, err := getUserFromRequest(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		render.JSON(w, r, models.Error("Unauthorized"))
+		writeError(w, r, err)
 		return
 	}
 
 	err = h.provider.CreateOrder(r.Context(), orderNumber, userID)
 	if err != nil {
-		if errors.Is(err, postgres.ErrCreatedByOtherUser) {
-			w.WriteHeader(http.StatusConflict)
-			render.JSON(w, r, models.Error("order created by other user"))
-			return
-		}
 		if errors.Is(err, postgres.ErrOrderAlredyExist) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при запросе в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error create order"))
+		if errors.Is(err, postgres.ErrCreatedByOtherUser) {
+			// если номер чужой по ошибке, заказ можно оспорить
+			err = fmt.Errorf("%w: to claim it open a dispute at /api/user/orders/%s/disputes", err, orderNumber)
+		}
+		writeError(w, r, err)
 		return
 	}
 
-	// в фоне сразу пробуем получить данные по заказу
-	go tasks.OrderProccessed(context.Background(), orderNumber, h.provider, h.cfg)
+	// в фоне сразу пробуем получить данные по заказу; фоновая обработка переживает запрос,
+	// поэтому получает свой корневой спан со ссылкой на спан запроса
+	link := trace.LinkFromContext(r.Context())
+	reqCtx := r.Context()
+	h.workers.Go(func(ctx context.Context) {
+		// логи фоновой обработки несут request_id и пользователя из запроса
+		tasks.OrderProccessed(logger.Inherit(ctx, reqCtx), orderNumber, h.provider, h.cfg, link)
+	})
 
 	w.WriteHeader(http.StatusAccepted)
 
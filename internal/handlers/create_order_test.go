@@ -18,6 +18,7 @@ import (
 func TestHandlerService_CreateOrder(t *testing.T) {
 	cfg := GetMockConfig()
 	providerMock := new(mocks.StorageProvider)
+	allowFraudChecks(providerMock)
 	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
 
 	service := New(providerMock, cfg)
@@ -66,7 +67,7 @@ func TestHandlerService_CreateOrder(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Настройка поведения моков
 			providerMock.On("CreateOrder", mock.Anything, tc.body, "user").Return(tc.expectedError)
-			providerMock.On("UpdateOrderAndAccrualPoints", mock.Anything, mock.Anything).Return(nil)
+			providerMock.On("UpdateOrderAndAccrualPoints", mock.Anything, mock.Anything).Return(0.0, nil)
 
 			body := bytes.NewBufferString(tc.body)
 			// Создание запроса
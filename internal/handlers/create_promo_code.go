@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// создаёт промокод; по умолчанию каждый пользователь может активировать его один раз
+func (h *HandlerService) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+
+	var promo models.PromoCode
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &promo); err != nil {
+		return
+	}
+
+	promo.Code = normalizePromoCode(promo.Code)
+	if promo.PerUserLimit == 0 {
+		promo.PerUserLimit = 1
+	}
+	promo.Redemptions = 0
+
+	created, err := h.provider.CreatePromoCode(r.Context(), promo)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("создан промокод",
+		zap.String("code", created.Code),
+		zap.Float64("amount", created.Amount),
+	)
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, created)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_CreatePromoCode(t *testing.T) {
+	maxRedemptions := 1000
+
+	testCases := []struct {
+		name         string
+		body         string
+		expected     *models.PromoCode
+		storageErr   error
+		expectedCode int
+	}{
+		{
+			name:         "код в верхнем регистре и один раз на пользователя по умолчанию",
+			body:         `{"code":"welcome2024","amount":100,"max_redemptions":1000,"redemptions":5}`,
+			expected:     &models.PromoCode{Code: "WELCOME2024", Amount: 100, MaxRedemptions: &maxRedemptions, PerUserLimit: 1},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "свой лимит на пользователя",
+			body:         `{"code":"SORRY","amount":50,"per_user_limit":3}`,
+			expected:     &models.PromoCode{Code: "SORRY", Amount: 50, PerUserLimit: 3},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "код уже существует",
+			body:         `{"code":"SORRY","amount":50}`,
+			expected:     &models.PromoCode{Code: "SORRY", Amount: 50, PerUserLimit: 1},
+			storageErr:   postgres.ErrPromoExists,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "нулевая сумма",
+			body:         `{"code":"FREE","amount":0}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "сумма с долями копеек",
+			body:         `{"code":"FREE","amount":0.001}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "нулевой общий лимит",
+			body:         `{"code":"FREE","amount":10,"max_redemptions":0}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			if tc.expected != nil {
+				providerMock.On("CreatePromoCode", mock.Anything, *tc.expected).Return(*tc.expected, tc.storageErr)
+			}
+
+			srv := httptest.NewServer(New(providerMock, GetMockConfig()).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/admin/promo-codes", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer admin")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+			if tc.expectedCode != http.StatusCreated {
+				return
+			}
+			var response models.PromoCode
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, *tc.expected, response)
+		})
+	}
+}
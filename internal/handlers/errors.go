@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+const problemContentType = "application/problem+json"
+
+var (
+	ErrEmptyBody          = errors.New("empty request body")
+	ErrMalformedBody      = errors.New("malformed request body")
+	ErrInvalidOrderNumber = errors.New("invalid order number")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrWrongCredentials   = errors.New("wrong credentials")
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidQuery       = errors.New("invalid query parameter")
+	ErrNotFound           = errors.New("resource not found")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrBodyTooLarge       = errors.New("request body too large")
+	ErrTransferToSelf     = errors.New("cannot transfer points to yourself")
+	ErrOperationBlocked   = errors.New("operation blocked by fraud checks")
+	ErrChallengeRequired  = errors.New("operation must be confirmed with the account password")
+)
+
+// описание ошибки, отдаваемой клиенту
+type problemSpec struct {
+	status int
+	code   models.ProblemCode
+	title  string
+}
+
+// сопоставление ошибок слоя хранения и обработчиков с ответом клиенту
+var problemSpecs = []struct {
+	err  error
+	spec problemSpec
+}{
+	{ErrEmptyBody, problemSpec{http.StatusBadRequest, models.ProblemEmptyBody, "Request body is empty"}},
+	{ErrMalformedBody, problemSpec{http.StatusBadRequest, models.ProblemMalformedBody, "Request body cannot be decoded"}},
+	{ErrInvalidOrderNumber, problemSpec{http.StatusUnprocessableEntity, models.ProblemInvalidOrderNumber, "Order number is not valid"}},
+	{ErrUnauthorized, problemSpec{http.StatusUnauthorized, models.ProblemUnauthorized, "Authentication required"}},
+	{ErrGetUserFromRequest, problemSpec{http.StatusUnauthorized, models.ProblemUnauthorized, "Authentication required"}},
+	{ErrWrongCredentials, problemSpec{http.StatusUnauthorized, models.ProblemInvalidCredentials, "Wrong login or password"}},
+	{postgres.ErrUserNotFound, problemSpec{http.StatusUnauthorized, models.ProblemInvalidCredentials, "Wrong login or password"}},
+	{postgres.ErrConflict, problemSpec{http.StatusConflict, models.ProblemUserExists, "User already exists"}},
+	{postgres.ErrCreatedByOtherUser, problemSpec{http.StatusConflict, models.ProblemOrderOwnedByOther, "Order was uploaded by another user"}},
+	{ErrInvalidIdempotencyKey, problemSpec{http.StatusBadRequest, models.ProblemInvalidIdempotency, "Idempotency key is not valid"}},
+	{ErrIdempotencyKeyReused, problemSpec{http.StatusUnprocessableEntity, models.ProblemIdempotencyReused, "Idempotency key reused with a different request"}},
+	{ErrRequestInProgress, problemSpec{http.StatusConflict, models.ProblemRequestInProgress, "Request is still in progress"}},
+	{ErrInvalidQuery, problemSpec{http.StatusBadRequest, models.ProblemInvalidQuery, "Query parameter is not valid"}},
+	{ErrNotFound, problemSpec{http.StatusNotFound, models.ProblemNotFound, "Resource not found"}},
+	{ErrMethodNotAllowed, problemSpec{http.StatusMethodNotAllowed, models.ProblemMethodNotAllowed, "Method not allowed"}},
+	{ErrBodyTooLarge, problemSpec{http.StatusRequestEntityTooLarge, models.ProblemPayloadTooLarge, "Request body is too large"}},
+	{ErrForbidden, problemSpec{http.StatusForbidden, models.ProblemForbidden, "Access denied"}},
+	{postgres.ErrWithdrawalNotFound, problemSpec{http.StatusNotFound, models.ProblemWithdrawalNotFound, "Withdrawal not found"}},
+	{postgres.ErrAlreadyRefunded, problemSpec{http.StatusConflict, models.ProblemAlreadyRefunded, "Withdrawal is already fully refunded"}},
+	{postgres.ErrRefundExceedsSum, problemSpec{http.StatusUnprocessableEntity, models.ProblemRefundExceedsSum, "Refund exceeds the remaining withdrawn sum"}},
+	{postgres.ErrFewPoints, problemSpec{http.StatusPaymentRequired, models.ProblemInsufficientPoints, "There are not enough points on balance"}},
+	{ErrTransferToSelf, problemSpec{http.StatusUnprocessableEntity, models.ProblemTransferToSelf, "Points cannot be transferred to yourself"}},
+	{postgres.ErrRecipientNotFound, problemSpec{http.StatusUnprocessableEntity, models.ProblemRecipientNotFound, "Recipient not found"}},
+	{postgres.ErrTransferLimit, problemSpec{http.StatusUnprocessableEntity, models.ProblemTransferLimit, "Daily transfer limit exceeded"}},
+	{postgres.ErrPromoNotFound, problemSpec{http.StatusNotFound, models.ProblemPromoNotFound, "Promo code not found"}},
+	{postgres.ErrPromoExpired, problemSpec{http.StatusUnprocessableEntity, models.ProblemPromoExpired, "Promo code has expired"}},
+	{postgres.ErrPromoExhausted, problemSpec{http.StatusConflict, models.ProblemPromoExhausted, "Promo code redemption limit reached"}},
+	{postgres.ErrPromoRedeemed, problemSpec{http.StatusConflict, models.ProblemPromoRedeemed, "Promo code already redeemed"}},
+	{postgres.ErrPromoExists, problemSpec{http.StatusConflict, models.ProblemPromoExists, "Promo code already exists"}},
+	{postgres.ErrReferralNotFound, problemSpec{http.StatusUnprocessableEntity, models.ProblemReferralNotFound, "Referral code not found"}},
+	{postgres.ErrOrderPaid, problemSpec{http.StatusConflict, models.ProblemOrderPaid, "Order already paid with points"}},
+	{postgres.ErrWithdrawalTooLarge, problemSpec{http.StatusUnprocessableEntity, models.ProblemWithdrawalTooLarge, "Withdrawal exceeds the per-withdrawal limit"}},
+	{postgres.ErrWithdrawalDaily, problemSpec{http.StatusUnprocessableEntity, models.ProblemWithdrawalDaily, "Daily withdrawal limit exceeded"}},
+	{postgres.ErrHoldNotFound, problemSpec{http.StatusNotFound, models.ProblemHoldNotFound, "Hold not found"}},
+	{postgres.ErrHoldNotActive, problemSpec{http.StatusConflict, models.ProblemHoldNotActive, "Hold is not active"}},
+	{ErrOperationBlocked, problemSpec{http.StatusForbidden, models.ProblemOperationBlocked, "Operation blocked"}},
+	{ErrChallengeRequired, problemSpec{http.StatusPreconditionRequired, models.ProblemChallengeRequired, "Confirmation required"}},
+	{postgres.ErrFlagNotFound, problemSpec{http.StatusNotFound, models.ProblemFlagNotFound, "Flagged user not found"}},
+	{postgres.ErrOrderNotFound, problemSpec{http.StatusNotFound, models.ProblemOrderNotFound, "Order not found"}},
+	{postgres.ErrOrderAlreadyYours, problemSpec{http.StatusConflict, models.ProblemOrderAlreadyYours, "Order already belongs to you"}},
+	{postgres.ErrDisputeExists, problemSpec{http.StatusConflict, models.ProblemDisputeExists, "Dispute for the order is already open"}},
+	{postgres.ErrDisputeNotFound, problemSpec{http.StatusNotFound, models.ProblemDisputeNotFound, "Dispute not found"}},
+	{postgres.ErrDisputeNotOpen, problemSpec{http.StatusConflict, models.ProblemDisputeNotOpen, "Dispute is already resolved"}},
+	{postgres.ErrDeletionNotFound, problemSpec{http.StatusNotFound, models.ProblemDeletionNotFound, "Account deletion is not requested"}},
+	{postgres.ErrCaptureExceedsHold, problemSpec{http.StatusUnprocessableEntity, models.ProblemCaptureExceedsHold, "Capture exceeds held sum"}},
+}
+
+var internalProblem = problemSpec{http.StatusInternalServerError, models.ProblemInternal, "Internal server error"}
+
+// writeError отвечает клиенту ошибкой в формате application/problem+json.
+// Неизвестные ошибки логируются и превращаются в 500 без подробностей.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	spec, ok := lookupProblem(err)
+	if !ok {
+		// request_id, пользователь и маршрут уже в логере из контекста
+		logger.FromContext(r.Context()).Error("внутренняя ошибка",
+			zap.String("path", r.URL.Path),
+			zap.Error(err),
+		)
+		writeProblem(w, r, spec, "", nil)
+		return
+	}
+	// по подробностям ошибки входа можно было бы отличить несуществующий логин от неверного пароля
+	detail := err.Error()
+	if spec.code == models.ProblemInvalidCredentials {
+		detail = ""
+	}
+	writeProblem(w, r, spec, detail, nil)
+}
+
+// writeValidationError отвечает ошибкой валидации с перечнем невалидных полей.
+func writeValidationError(w http.ResponseWriter, r *http.Request, fields []models.FieldError) {
+	spec := problemSpec{http.StatusBadRequest, models.ProblemValidationFailed, "Request validation failed"}
+	writeProblem(w, r, spec, "", fields)
+}
+
+// bodyError превращает ошибку чтения тела в ответ клиенту: превышение лимита отдаётся как 413,
+// остальные ошибки как fallback
+func bodyError(err error, fallback error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxBytesErr.Limit)
+	}
+	return fallback
+}
+
+func lookupProblem(err error) (problemSpec, bool) {
+	for _, p := range problemSpecs {
+		if errors.Is(err, p.err) {
+			return p.spec, true
+		}
+	}
+	return internalProblem, false
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, spec problemSpec, detail string, fields []models.FieldError) {
+	problem := models.Problem{
+		Type:      spec.code.Type(),
+		Title:     spec.title,
+		Status:    spec.status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      spec.code,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    fields,
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(spec.status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logger.FromContext(r.Context()).Error("не удалось записать ответ", zap.Error(err))
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_ProblemResponses(t *testing.T) {
+	cfg := GetMockConfig()
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	service := New(providerMock, cfg)
+	r := service.GetRouter()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	providerMock.On("Withdrow", mock.Anything, 1000.0, "user", mock.Anything, false, models.WithdrawalLimits{}).Return(postgres.ErrFewPoints)
+	providerMock.On("Withdrow", mock.Anything, 500.0, "user", mock.Anything, false, models.WithdrawalLimits{}).Return(fmt.Errorf("connection refused"))
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           string
+		token          string
+		requestID      string
+		expectedCode   int
+		expectedStatus models.ProblemCode
+		expectedFields []string
+	}{
+		{
+			name:           "недостаточно средств",
+			path:           "/api/user/balance/withdraw",
+			body:           `{"order":"2377225624","sum":1000}`,
+			token:          token,
+			requestID:      "req-1",
+			expectedCode:   http.StatusPaymentRequired,
+			expectedStatus: models.ProblemInsufficientPoints,
+		},
+		{
+			name:           "внутренняя ошибка",
+			path:           "/api/user/balance/withdraw",
+			body:           `{"order":"2377225624","sum":500}`,
+			token:          token,
+			requestID:      "req-2",
+			expectedCode:   http.StatusInternalServerError,
+			expectedStatus: models.ProblemInternal,
+		},
+		{
+			name:           "ошибки валидации полей",
+			path:           "/api/user/register",
+			body:           `{}`,
+			requestID:      "req-3",
+			expectedCode:   http.StatusBadRequest,
+			expectedStatus: models.ProblemValidationFailed,
+			expectedFields: []string{"login", "password"},
+		},
+		{
+			name:           "без токена",
+			path:           "/api/user/balance/withdraw",
+			body:           `{"order":"2377225624","sum":1000}`,
+			requestID:      "req-4",
+			expectedCode:   http.StatusUnauthorized,
+			expectedStatus: models.ProblemUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("X-Request-Id", tc.requestID)
+			if tc.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tc.token))
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+			var problem models.Problem
+			err = json.NewDecoder(resp.Body).Decode(&problem)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, problem.Code)
+			assert.Equal(t, tc.expectedStatus.Type(), problem.Type)
+			assert.Equal(t, tc.expectedCode, problem.Status)
+			assert.Equal(t, tc.requestID, problem.RequestID)
+			assert.Equal(t, tc.path, problem.Instance)
+
+			var fields []string
+			for _, f := range problem.Errors {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tc.expectedFields, fields)
+
+			// внутренние подробности наружу не отдаются
+			if tc.expectedCode == http.StatusInternalServerError {
+				assert.Empty(t, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestHandlerService_NoContentHasNoBody(t *testing.T) {
+	cfg := GetMockConfig()
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	service := New(providerMock, cfg)
+	srv := httptest.NewServer(service.GetRouter())
+	defer srv.Close()
+
+	providerMock.On("GetUserOrders", mock.Anything, "user").Return(nil, postgres.ErrOrdersNotFound)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/orders", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
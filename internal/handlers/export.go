@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatZIP  = "zip"
+)
+
+// выгружает все данные пользователя одним JSON или ZIP-архивом с отдельным файлом на каждый раздел
+func (h *HandlerService) ExportUserData(w http.ResponseWriter, r *http.Request) {
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+	if format != exportFormatJSON && format != exportFormatZIP {
+		writeError(w, r, fmt.Errorf("%w: format must be one of json, zip", ErrInvalidQuery))
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	export, err := h.collectExport(r, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("выгружены данные пользователя", zap.String("format", format))
+
+	if format == exportFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, export)
+		return
+	}
+
+	// архив собирается целиком до ответа, чтобы ошибка не оборвала уже начатую выгрузку
+	archive, err := exportArchive(export)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive); err != nil {
+		logger.FromContext(r.Context()).Error("не удалось записать ответ", zap.Error(err))
+	}
+}
+
+// разделы без записей выгружаются пустыми списками, а не пропадают из выгрузки
+func (h *HandlerService) collectExport(r *http.Request, userID string) (models.UserExport, error) {
+	ctx := r.Context()
+	export := models.UserExport{
+		ExportedAt:     time.Now().UTC(),
+		Orders:         models.Orders{},
+		Withdrawals:    models.Withdrawals{},
+		Transfers:      models.Transfers{},
+		BalanceHistory: []models.BalanceEntry{},
+	}
+
+	var err error
+	if export.Profile, err = h.provider.GetUserProfile(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Balance, err = h.provider.GetUserBalance(ctx, userID); err != nil {
+		return export, err
+	}
+
+	orders, err := h.provider.GetUserOrders(ctx, userID)
+	if err != nil && !errors.Is(err, postgres.ErrOrdersNotFound) {
+		return export, err
+	}
+	export.Orders = append(export.Orders, orders...)
+
+	withdrawals, err := h.provider.GetUserWithdrawals(ctx, userID)
+	if err != nil && !errors.Is(err, postgres.ErrWithdrawalsNotFound) {
+		return export, err
+	}
+	export.Withdrawals = append(export.Withdrawals, withdrawals...)
+
+	transfers, err := h.provider.GetUserTransfers(ctx, userID)
+	if err != nil && !errors.Is(err, postgres.ErrTransfersNotFound) {
+		return export, err
+	}
+	export.Transfers = append(export.Transfers, transfers...)
+
+	history, err := h.provider.GetBalanceHistory(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	export.BalanceHistory = append(export.BalanceHistory, history...)
+
+	return export, nil
+}
+
+func exportArchive(export models.UserExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"balance.json", export.Balance},
+		{"orders.json", export.Orders},
+		{"withdrawals.json", export.Withdrawals},
+		{"transfers.json", export.Transfers},
+		{"balance_history.json", export.BalanceHistory},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
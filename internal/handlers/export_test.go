@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_ExportUserData(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	accrual := 500.0
+	uploaded := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	userData := func(m *mocks.StorageProvider) {
+		m.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{Login: "user", ReferralCode: "K5QXGZ3B"}, nil)
+		m.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{Current: 500}, nil)
+		m.On("GetUserOrders", mock.Anything, "user").
+			Return([]models.Order{{Number: "79927398713", Status: "PROCESSED", Accrual: &accrual, UploadedAt: uploaded}}, nil)
+		m.On("GetUserWithdrawals", mock.Anything, "user").Return(nil, postgres.ErrWithdrawalsNotFound)
+		m.On("GetUserTransfers", mock.Anything, "user").Return(nil, postgres.ErrTransfersNotFound)
+		m.On("GetBalanceHistory", mock.Anything, "user").Return([]models.BalanceEntry{
+			{Kind: models.BalanceCredit, Source: models.PointLotAccrual, Order: "79927398713", Sum: 500, CreatedAt: uploaded},
+		}, nil)
+	}
+
+	testCases := []struct {
+		name                string
+		query               string
+		setup               func(m *mocks.StorageProvider)
+		expectedCode        int
+		expectedContentType string
+	}{
+		{
+			name:                "выгрузка JSON",
+			setup:               userData,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "выгрузка архивом",
+			query:               "?format=zip",
+			setup:               userData,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/zip",
+		},
+		{
+			name:         "неизвестный формат",
+			query:        "?format=csv",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:  "ошибка получения заказов",
+			query: "?format=zip",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{Login: "user"}, nil)
+				m.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, nil)
+				m.On("GetUserOrders", mock.Anything, "user").Return(nil, errors.New("connection reset"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/export"+tc.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedContentType != "" {
+				assert.Contains(t, resp.Header.Get("Content-Type"), tc.expectedContentType)
+				assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+			}
+		})
+	}
+}
+
+func TestHandlerService_ExportUserData_Contents(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	providerMock := mocks.NewStorageProvider(t)
+	providerMock.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{Login: "user"}, nil)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, nil)
+	providerMock.On("GetUserOrders", mock.Anything, "user").Return(nil, postgres.ErrOrdersNotFound)
+	providerMock.On("GetUserWithdrawals", mock.Anything, "user").Return(nil, postgres.ErrWithdrawalsNotFound)
+	providerMock.On("GetUserTransfers", mock.Anything, "user").Return(nil, postgres.ErrTransfersNotFound)
+	providerMock.On("GetBalanceHistory", mock.Anything, "user").Return(nil, nil)
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	get := func(t *testing.T, query string) []byte {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/export"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	t.Run("пустые разделы выгружаются пустыми списками", func(t *testing.T) {
+		var export map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(get(t, ""), &export))
+
+		for _, section := range []string{"orders", "withdrawals", "transfers", "balance_history"} {
+			assert.JSONEq(t, "[]", string(export[section]), section)
+		}
+	})
+
+	t.Run("архив содержит файл на каждый раздел", func(t *testing.T) {
+		body := get(t, "?format=zip")
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+
+		var names []string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+		}
+		assert.Equal(t, []string{
+			"profile.json", "balance.json", "orders.json", "withdrawals.json", "transfers.json", "balance_history.json",
+		}, names)
+	})
+}
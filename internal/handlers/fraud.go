@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/auth/hash"
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// ConfirmPasswordHeader пароль аккаунта, которым пользователь подтверждает операцию по требованию антифрода
+const ConfirmPasswordHeader = "X-Confirm-Password"
+
+// fraudCheck проверяет операцию антифрод-правилами до обработчика. Заблокированная операция не выполняется;
+// операцию, требующую подтверждения, нужно повторить с паролем аккаунта в заголовке X-Confirm-Password.
+func (h *HandlerService) fraudCheck(op models.FraudOperation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := getUserFromRequest(r.Context())
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			verdict, err := h.fraud.Evaluate(r.Context(), op, userID, clientIP(r))
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			if verdict.Decision == models.FraudChallenge {
+				if password := r.Header.Get(ConfirmPasswordHeader); password != "" {
+					if !h.checkUserPassword(r, userID, password) {
+						writeError(w, r, ErrWrongCredentials)
+						return
+					}
+					verdict.Decision = models.FraudAllow
+					verdict.ChallengePassed = true
+				}
+			}
+
+			metrics.FraudDecisions.WithLabelValues(string(op), string(verdict.Decision)).Inc()
+			if len(verdict.Rules) > 0 {
+				logger.FromContext(r.Context()).Warn("сработали антифрод-правила",
+					zap.String("operation", string(op)),
+					zap.String("decision", string(verdict.Decision)),
+					zap.Strings("rules", verdict.Rules),
+					zap.Bool("flag", verdict.Flag),
+					zap.Bool("challenge_passed", verdict.ChallengePassed),
+					zap.String("ip", verdict.IP),
+				)
+				// решение уже принято, ошибка записи не должна его менять
+				if err := h.provider.RecordFraudDecision(r.Context(), verdict); err != nil {
+					logger.FromContext(r.Context()).Error("не удалось записать решение антифрода", zap.Error(err))
+				}
+			} else {
+				logger.FromContext(r.Context()).Debug("антифрод-проверка пройдена", zap.String("operation", string(op)))
+			}
+
+			switch verdict.Decision {
+			case models.FraudBlock:
+				writeError(w, r, ErrOperationBlocked)
+			case models.FraudChallenge:
+				writeError(w, r, ErrChallengeRequired)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func (h *HandlerService) checkUserPassword(r *http.Request, userID string, password string) bool {
+	passwordHash, err := h.provider.GetPasswordHash(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("не удалось получить пароль пользователя", zap.Error(err))
+		return false
+	}
+	return hash.CheckPassword(passwordHash, password)
+}
+
+// запоминает адрес, с которого пользователь зарегистрировался или вошёл; по нему антифрод узнаёт новые адреса.
+// Ошибка записи не мешает входу.
+func (h *HandlerService) recordUserIP(r *http.Request, userLogin string) {
+	if err := h.provider.RecordUserIP(r.Context(), userLogin, clientIP(r)); err != nil {
+		logger.FromContext(r.Context()).Error("не удалось записать адрес пользователя", zap.Error(err))
+	}
+}
+
+// адрес клиента из соединения; сервис принимает запросы от клиентов напрямую, без обратного прокси
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
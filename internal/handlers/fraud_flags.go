@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// аккаунты, которые антифрод отправил на проверку
+func (h *HandlerService) GetFlaggedUsers(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := h.provider.GetFlaggedUsers(r.Context())
+	if err != nil {
+		if errors.Is(err, postgres.ErrFlaggedNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, models.FlaggedUsers(users))
+}
+
+// снимает отметку после проверки аккаунта
+func (h *HandlerService) ClearFraudFlag(w http.ResponseWriter, r *http.Request) {
+
+	login := chi.URLParam(r, "login")
+	if err := h.provider.ClearFraudFlag(r.Context(), login); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("аккаунт проверен", zap.String("login", login))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/hash"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// антифрод пропускает операции: у пользователя нет истории
+func allowFraudChecks(m *mocks.StorageProvider) {
+	m.On("GetFraudSignals", mock.Anything, mock.Anything, mock.Anything).Return(models.FraudSignals{}, nil).Maybe()
+	m.On("RecordUserIP", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func TestHandlerService_FraudCheck(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.FraudOrderHourlyLimit = 10
+	cfg.FraudMinProcessedRatio = 0.1
+	cfg.FraudRatioMinOrders = 20
+	cfg.FraudNewIPWindow = time.Hour
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	passHash, _ := hash.HashPassword("password")
+	recentIP := time.Now().Add(-time.Minute)
+
+	testCases := []struct {
+		name            string
+		path            string
+		body            string
+		password        string
+		setup           func(m *mocks.StorageProvider)
+		expectedCode    int
+		expectedProblem models.ProblemCode
+	}{
+		{
+			name: "загрузка заказов сверх часового лимита",
+			path: "/api/user/orders",
+			body: "79927398713",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{OrdersLastHour: 10}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.MatchedBy(func(v models.FraudVerdict) bool {
+					return v.Decision == models.FraudBlock && v.Flag && v.Operation == models.FraudOrderUpload
+				})).Return(nil)
+			},
+			expectedCode:    http.StatusForbidden,
+			expectedProblem: "operation-blocked",
+		},
+		{
+			name: "мало обработанных заказов",
+			path: "/api/user/orders",
+			body: "79927398713",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).
+					Return(models.FraudSignals{FinishedOrders: 30, ProcessedOrders: 1}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode:    http.StatusForbidden,
+			expectedProblem: "operation-blocked",
+		},
+		{
+			name: "ошибка записи решения не меняет его",
+			path: "/api/user/orders",
+			body: "79927398713",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{OrdersLastHour: 11}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.Anything).Return(fmt.Errorf("connection reset"))
+			},
+			expectedCode:    http.StatusForbidden,
+			expectedProblem: "operation-blocked",
+		},
+		{
+			name: "списание с нового адреса",
+			path: "/api/user/balance/withdraw",
+			body: `{"order":"2377225624","sum":100}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).
+					Return(models.FraudSignals{IPFirstSeen: &recentIP, OtherIPs: 1}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.MatchedBy(func(v models.FraudVerdict) bool {
+					return v.Decision == models.FraudChallenge && !v.ChallengePassed
+				})).Return(nil)
+			},
+			expectedCode:    http.StatusPreconditionRequired,
+			expectedProblem: "challenge-required",
+		},
+		{
+			name:     "списание с нового адреса подтверждено паролем",
+			path:     "/api/user/balance/withdraw",
+			body:     `{"order":"2377225624","sum":100}`,
+			password: "password",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).
+					Return(models.FraudSignals{IPFirstSeen: &recentIP, OtherIPs: 1}, nil)
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.MatchedBy(func(v models.FraudVerdict) bool {
+					return v.Decision == models.FraudAllow && v.ChallengePassed
+				})).Return(nil)
+				m.On("Withdrow", mock.Anything, float64(100), "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:     "неверный пароль подтверждения",
+			path:     "/api/user/balance/withdraw",
+			body:     `{"order":"2377225624","sum":100}`,
+			password: "wrong",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{Flagged: true}, nil)
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "первый адрес пользователя не новый",
+			path: "/api/user/balance/withdraw",
+			body: `{"order":"2377225624","sum":100}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).
+					Return(models.FraudSignals{IPFirstSeen: &recentIP}, nil)
+				m.On("Withdrow", mock.Anything, float64(100), "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "ошибка получения сведений",
+			path: "/api/user/balance/withdraw",
+			body: `{"order":"2377225624","sum":100}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{}, fmt.Errorf("connection reset"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			tc.setup(providerMock)
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			if tc.password != "" {
+				req.Header.Set(ConfirmPasswordHeader, tc.password)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedProblem != "" {
+				var problem models.Problem
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+				assert.Equal(t, tc.expectedProblem, problem.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerService_FraudFlags(t *testing.T) {
+	cfg := GetMockConfig()
+	flaggedAt := time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		setup        func(m *mocks.StorageProvider)
+		expectedCode int
+	}{
+		{
+			name:   "список аккаунтов на проверке",
+			method: http.MethodGet,
+			path:   "/api/admin/fraud/flagged",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFlaggedUsers", mock.Anything).
+					Return([]models.FlaggedUser{{Login: "user", Reason: "order-upload-rate", FlaggedAt: flaggedAt}}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "нет аккаунтов на проверке",
+			method: http.MethodGet,
+			path:   "/api/admin/fraud/flagged",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFlaggedUsers", mock.Anything).Return(nil, postgres.ErrFlaggedNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "снятие отметки",
+			method: http.MethodDelete,
+			path:   "/api/admin/fraud/flagged/user",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ClearFraudFlag", mock.Anything, "user").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "аккаунт не на проверке",
+			method: http.MethodDelete,
+			path:   "/api/admin/fraud/flagged/nobody",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ClearFraudFlag", mock.Anything, "nobody").Return(postgres.ErrFlagNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			tc.setup(providerMock)
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestHandlerService_FraudChallengeWithIdempotencyKey(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.FraudNewIPWindow = time.Hour
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	passHash, _ := hash.HashPassword("password")
+	recentIP := time.Now().Add(-time.Minute)
+	body := `{"order":"2377225624","sum":100}`
+
+	providerMock := mocks.NewStorageProvider(t)
+	providerMock.On("ReserveIdempotencyKey", mock.Anything, "user", "key-1", mock.Anything, cfg.IdempotencyKeyTTL).Return(nil, nil).Twice()
+	providerMock.On("GetFraudSignals", mock.Anything, "user", mock.Anything).
+		Return(models.FraudSignals{IPFirstSeen: &recentIP, OtherIPs: 1}, nil)
+	providerMock.On("RecordFraudDecision", mock.Anything, mock.Anything).Return(nil)
+	// ответ 428 не сохраняется, иначе повтор с паролем получил бы его снова
+	providerMock.On("ReleaseIdempotencyKey", mock.Anything, "user", "key-1").Return(nil).Once()
+	providerMock.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+	providerMock.On("Withdrow", mock.Anything, float64(100), "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil).Once()
+	providerMock.On("CompleteIdempotencyKey", mock.Anything, "user", "key-1", mock.MatchedBy(func(r models.IdempotencyRecord) bool {
+		return r.StatusCode == http.StatusOK
+	})).Return(nil).Once()
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	send := func(password string) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/withdraw", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		if password != "" {
+			req.Header.Set(ConfirmPasswordHeader, password)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, send(""))
+	assert.Equal(t, http.StatusOK, send("password"))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// отчёт о расхождениях начислений, найденных при сверке с системой лояльности
+func (h *HandlerService) GetAccrualAdjustments(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	since := time.Now().Add(-h.cfg.ReconcileWindow)
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("%w: since must be RFC3339 date-time", ErrInvalidQuery))
+			return
+		}
+		since = parsed
+	}
+
+	adjustments, err := h.provider.GetAccrualAdjustments(r.Context(), since)
+	if err != nil {
+		if errors.Is(err, postgres.ErrAdjustmentsNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, models.AccrualAdjustments(adjustments))
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_GetAccrualAdjustments(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.ReconcileWindow = 24 * time.Hour
+
+	since, _ := time.Parse(time.RFC3339, "2024-02-01T00:00:00Z")
+	mockAdjustments := []models.AccrualAdjustment{
+		{Order: "79927398713", UserLogin: "user", PreviousAccrual: 500, NewAccrual: 300, Delta: -200, Applied: -200, Status: models.AdjustmentApplied},
+		{Order: "2377225624", UserLogin: "jack", PreviousAccrual: 100, NewAccrual: 0, Delta: -100, Applied: 0, Status: models.AdjustmentHeld},
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		since         any
+		expectedCode  int
+		expectedBody  []models.AccrualAdjustment
+		expectedError error
+	}{
+		{
+			name:         "с явной датой",
+			query:        "?since=2024-02-01T00:00:00Z",
+			since:        since,
+			expectedCode: http.StatusOK,
+			expectedBody: mockAdjustments,
+		},
+		{
+			name:  "по умолчанию берётся окно сверки",
+			query: "",
+			since: mock.MatchedBy(func(s time.Time) bool {
+				return time.Since(s) >= cfg.ReconcileWindow && time.Since(s) < cfg.ReconcileWindow+time.Minute
+			}),
+			expectedCode:  http.StatusNoContent,
+			expectedError: postgres.ErrAdjustmentsNotFound,
+		},
+		{
+			name:         "неверная дата",
+			query:        "?since=yesterday",
+			since:        mock.Anything,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			providerMock.On("GetAccrualAdjustments", mock.Anything, tc.since).Return(tc.expectedBody, tc.expectedError)
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/admin/accrual-adjustments"+tc.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedBody != nil {
+				var response []models.AccrualAdjustment
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+				assert.Len(t, response, len(tc.expectedBody))
+			}
+		})
+	}
+}
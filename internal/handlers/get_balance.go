@@ -2,11 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/render"
-	"go.uber.org/zap"
 
-	"github.com/zYoma/gophermart/internal/logger"
 	"github.com/zYoma/gophermart/internal/models"
 )
 
@@ -16,19 +15,25 @@ func (h *HandlerService) GetBalance(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := getUserFromRequest(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		render.JSON(w, r, models.Error("Unauthorized"))
+		writeError(w, r, err)
 		return
 	}
 
 	balance, err := h.provider.GetUserBalance(r.Context(), userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при запросе в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error get balance"))
+		writeError(w, r, err)
 		return
 	}
 
+	// пока баллы не сгорают, показывать в разбивке нечего
+	if h.cfg.PointsExpiryMonths > 0 {
+		balance.ExpiringSoon, err = h.provider.GetExpiringPoints(r.Context(), userID, time.Now().Add(h.cfg.PointsExpiringSoonWindow))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	render.JSON(w, r, models.Balance(balance))
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -25,6 +26,7 @@ func TestHandlerService_GetBalance(t *testing.T) {
 	// Настройка поведения моков
 	mockBalance := models.Balance{
 		Current:   400,
+		Held:      120,
 		Withdrawn: 43,
 	}
 
@@ -83,3 +85,39 @@ func TestHandlerService_GetBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerService_GetBalance_ExpiringSoon(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.PointsExpiryMonths = 12
+	cfg.PointsExpiringSoonWindow = 30 * 24 * time.Hour
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	expiring := []models.ExpiringPoints{
+		{Sum: 100, ExpiresAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{Sum: 25.5, ExpiresAt: time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)},
+	}
+
+	providerMock := new(mocks.StorageProvider)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{Current: 400, Withdrawn: 43}, nil)
+	providerMock.On("GetExpiringPoints", mock.Anything, "user", mock.MatchedBy(func(before time.Time) bool {
+		// окно отсчитывается от момента запроса
+		return time.Until(before) > 29*24*time.Hour && time.Until(before) <= cfg.PointsExpiringSoonWindow
+	})).Return(expiring, nil)
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/balance", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var response models.Balance
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, models.Balance{Current: 400, Withdrawn: 43, ExpiringSoon: expiring}, response)
+	providerMock.AssertExpectations(t)
+}
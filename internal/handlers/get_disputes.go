@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// споры о принадлежности заказов; без status — все споры
+func (h *HandlerService) GetDisputes(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	status := models.DisputeStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		writeError(w, r, fmt.Errorf("%w: status must be one of OPEN, TRANSFERRED, REJECTED", ErrInvalidQuery))
+		return
+	}
+
+	disputes, err := h.provider.GetDisputes(r.Context(), status)
+	if err != nil {
+		if errors.Is(err, postgres.ErrDisputesNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, models.Disputes(disputes))
+}
@@ -5,9 +5,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/render"
-	"go.uber.org/zap"
 
-	"github.com/zYoma/gophermart/internal/logger"
 	"github.com/zYoma/gophermart/internal/models"
 	"github.com/zYoma/gophermart/internal/storage/postgres"
 )
@@ -18,8 +16,7 @@ func (h *HandlerService) GetOrders(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := getUserFromRequest(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		render.JSON(w, r, models.Error("Unauthorized"))
+		writeError(w, r, err)
 		return
 	}
 
@@ -27,12 +24,9 @@ func (h *HandlerService) GetOrders(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, postgres.ErrOrdersNotFound) {
 			w.WriteHeader(http.StatusNoContent)
-			render.JSON(w, r, models.Error("orders not found"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при запросе в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error get orders"))
+		writeError(w, r, err)
 		return
 	}
 
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+func (h *HandlerService) GetProfile(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	profile, err := h.provider.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, profile)
+}
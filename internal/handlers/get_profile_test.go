@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_GetProfile(t *testing.T) {
+	cfg := GetMockConfig()
+
+	silver := models.Profile{
+		Login:        "user",
+		ReferralCode: "K5QXGZ3B",
+		ReferredBy:   "jack",
+		Tier: models.TierProgress{
+			Name:              "SILVER",
+			Multiplier:        1.05,
+			QualifyingAccrual: 1500,
+			Next:              &models.NextTier{Name: "GOLD", MinAccrual: 5000, Remaining: 3500},
+		},
+	}
+	// на максимальном уровне следующего нет
+	platinum := models.Profile{
+		Login:        "jack",
+		ReferralCode: "MFRGGZDF",
+		Tier:         models.TierProgress{Name: "PLATINUM", Multiplier: 1.2, QualifyingAccrual: 25000},
+	}
+
+	testCases := []struct {
+		name         string
+		user         string
+		profile      models.Profile
+		err          error
+		expectedCode int
+	}{
+		{name: "уровень с прогрессом до следующего", user: "user", profile: silver, expectedCode: http.StatusOK},
+		{name: "максимальный уровень", user: "jack", profile: platinum, expectedCode: http.StatusOK},
+		{name: "ошибка БД", user: "bob", err: errors.New("db is down"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			providerMock.On("GetUserProfile", mock.Anything, tc.user).Return(tc.profile, tc.err)
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			token, _ := jwt.BuildJWTString(tc.user, cfg.TokenSecret)
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/profile", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response models.Profile
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, tc.profile, response)
+		})
+	}
+}
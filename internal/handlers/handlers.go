@@ -1,35 +1,117 @@
 package handlers
 
 import (
+	"net/http"
+	"sync/atomic"
+
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zYoma/gophermart/internal/app/tasks"
 	"github.com/zYoma/gophermart/internal/config"
+	"github.com/zYoma/gophermart/internal/fraud"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/models"
 	"github.com/zYoma/gophermart/internal/storage"
 )
 
+// пределы тела запроса для маршрутов, которым заведомо не нужен общий MaxBodyBytes
+const (
+	// номер заказа текстом
+	orderBodyLimit = 1 << 10
+	// небольшие JSON: логин и пароль, списание, возврат
+	smallJSONBodyLimit = 4 << 10
+	// спор по заказу с описанием доказательств
+	disputeBodyLimit = 16 << 10
+	// список пользователей с отладочным логированием
+	logLevelBodyLimit = 64 << 10
+)
+
 type HandlerService struct {
 	provider storage.Provider
 	cfg      *config.Config
+	// выставляется в начале остановки сервера, /readyz после этого отвечает 503
+	draining atomic.Bool
+	// фоновая обработка загруженных заказов
+	workers *tasks.Workers
+	// антифрод-правила для загрузки заказов и списаний
+	fraud *fraud.Engine
+	// последняя проверка системы лояльности для /readyz
+	accrualCheck cachedCheck
 }
 
 func New(provider storage.Provider, cfg *config.Config) *HandlerService {
-	return &HandlerService{provider: provider, cfg: cfg}
+	return &HandlerService{
+		provider: provider,
+		cfg:      cfg,
+		workers:  tasks.NewWorkers(),
+		fraud:    fraud.New(provider, fraud.Rules(cfg)),
+	}
+}
+
+// Workers фоновая обработка заказов, запущенная обработчиками; её же использует опрос системы лояльности
+func (h *HandlerService) Workers() *tasks.Workers {
+	return h.workers
 }
 
 func (h *HandlerService) GetRouter() chi.Router {
 
 	r := chi.NewRouter()
 
+	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(requestLogger)
 	r.Use(handlerLogger)
+	r.Use(metricsMiddleware)
+	r.Use(recoverMiddleware)
+	r.Use(limitBody(int64(h.cfg.MaxBodyBytes)))
 	r.Use(h.jwtAuthMiddleware)
 
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, ErrNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, ErrMethodNotAllowed)
+	})
+
 	r.Route("/", func(r chi.Router) {
-		r.Post("/api/user/register", h.Registration)
-		r.Post("/api/user/login", h.Login)
-		r.Post("/api/user/orders", h.CreateOrder)
+		r.With(limitBody(smallJSONBodyLimit)).Post("/api/user/register", h.Registration)
+		r.With(limitBody(smallJSONBodyLimit)).Post("/api/user/login", h.Login)
+		r.With(limitBody(orderBodyLimit), h.idempotencyMiddleware, h.fraudCheck(models.FraudOrderUpload)).Post("/api/user/orders", h.CreateOrder)
 		r.Get("/api/user/orders", h.GetOrders)
+		r.With(limitBody(disputeBodyLimit), h.idempotencyMiddleware).Post("/api/user/orders/{number}/disputes", h.CreateDispute)
 		r.Get("/api/user/balance", h.GetBalance)
-		r.Post("/api/user/balance/withdraw", h.WithdrowPoints)
+		r.Get("/api/user/profile", h.GetProfile)
+		r.Get("/api/user/export", h.ExportUserData)
+		r.With(limitBody(smallJSONBodyLimit)).Post("/api/user/deletion", h.RequestAccountDeletion)
+		r.Delete("/api/user/deletion", h.CancelAccountDeletion)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware, h.fraudCheck(models.FraudWithdrawal)).Post("/api/user/balance/withdraw", h.WithdrowPoints)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware, h.fraudCheck(models.FraudWithdrawal)).Post("/api/user/balance/holds", h.CreateHold)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware).Post("/api/user/balance/holds/{id}/capture", h.CaptureHold)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware).Post("/api/user/balance/holds/{id}/release", h.ReleaseHold)
 		r.Get("/api/user/withdrawals", h.GetWithdrawals)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware).Post("/api/user/balance/transfer", h.TransferPoints)
+		r.Get("/api/user/transfers", h.GetTransfers)
+		r.With(limitBody(smallJSONBodyLimit), h.idempotencyMiddleware).Post("/api/user/promo", h.RedeemPromoCode)
+		r.Get("/api/openapi.json", h.OpenAPISpec)
+		r.Method(http.MethodGet, "/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+		r.Route("/api/admin", func(r chi.Router) {
+			r.Use(h.clientCertMiddleware)
+			r.Use(h.adminAuthMiddleware)
+			r.With(limitBody(smallJSONBodyLimit)).Post("/withdrawals/{order}/refund", h.RefundWithdrawal)
+			r.Get("/accrual-adjustments", h.GetAccrualAdjustments)
+			r.Get("/fraud/flagged", h.GetFlaggedUsers)
+			r.Delete("/fraud/flagged/{login}", h.ClearFraudFlag)
+			r.Get("/disputes", h.GetDisputes)
+			r.With(limitBody(disputeBodyLimit)).Post("/disputes/{id}/transfer", h.TransferDisputedOrder)
+			r.With(limitBody(disputeBodyLimit)).Post("/disputes/{id}/reject", h.RejectDispute)
+			r.With(limitBody(smallJSONBodyLimit)).Post("/promo-codes", h.CreatePromoCode)
+			r.With(limitBody(smallJSONBodyLimit)).Post("/campaigns", h.CreateCampaign)
+			r.Get("/log-level", h.GetLogLevel)
+			r.With(limitBody(logLevelBodyLimit)).Put("/log-level", h.SetLogLevel)
+		})
+		r.Get("/api/docs", h.Docs)
+		r.Get("/healthz", h.Healthz)
+		r.Get("/readyz", h.Readyz)
 	})
 
 	return r
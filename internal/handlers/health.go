@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/integrations/loyalty"
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+const (
+	// сколько ждём ответа всех зависимостей при проверке готовности
+	readinessTimeout = 3 * time.Second
+	// сколько держим результат проверки системы лояльности: пробы идут каждые несколько секунд,
+	// и не каждая из них должна превращаться в запрос к внешнему сервису
+	accrualCheckTTL = 15 * time.Second
+)
+
+var (
+	ErrShuttingDown  = errors.New("server is shutting down")
+	ErrAccrualPaused = errors.New("accrual requests are paused after 429")
+)
+
+// проверка одной зависимости для /readyz
+type readinessCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// cachedCheck запоминает результат проверки на ttl
+type cachedCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+func (c *cachedCheck) run(ctx context.Context, ttl time.Duration, check func(ctx context.Context) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < ttl {
+		return c.err
+	}
+	c.err = check(ctx)
+	c.checkedAt = time.Now()
+	return c.err
+}
+
+// StartDraining снимает готовность, чтобы балансировщик перестал слать запросы до остановки сервера
+func (h *HandlerService) StartDraining() {
+	h.draining.Store(true)
+}
+
+// liveness: процесс жив и способен отвечать, зависимости не проверяются
+func (h *HandlerService) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readiness: сервис может обслуживать запросы. Недоступность системы лояльности
+// отражается в ответе, но готовность не снимает: заказы примутся и обработаются позже.
+func (h *HandlerService) Readyz(w http.ResponseWriter, r *http.Request) {
+	readiness := models.Readiness{Ready: true, Checks: map[string]models.ReadinessCheck{}}
+
+	checks := []readinessCheck{
+		{name: "shutdown", critical: true, check: func(context.Context) error {
+			if h.draining.Load() {
+				return ErrShuttingDown
+			}
+			return nil
+		}},
+		{name: "postgres", critical: true, check: h.provider.Ping},
+		{name: "migrations", critical: true, check: h.provider.CheckMigrations},
+		{name: "accrual", critical: false, check: func(ctx context.Context) error {
+			return h.accrualCheck.run(ctx, accrualCheckTTL, func(ctx context.Context) error {
+				return loyalty.Ping(ctx, h.cfg.AcrualURL)
+			})
+		}},
+		{name: "accrual_rate_limit", critical: false, check: func(context.Context) error {
+			if loyalty.Paused() {
+				return ErrAccrualPaused
+			}
+			return nil
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	for _, c := range checks {
+		result := models.ReadinessCheck{Status: models.CheckOK, Critical: c.critical}
+		if err := c.check(ctx); err != nil {
+			// подробности только в лог: эндпоинт доступен без авторизации
+			logger.FromContext(r.Context()).Warn("проверка готовности не пройдена", zap.String("check", c.name), zap.Error(err))
+			result.Status = models.CheckFail
+			if c.critical {
+				readiness.Ready = false
+			}
+		}
+		readiness.Checks[c.name] = result
+	}
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	render.JSON(w, r, readiness)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_Healthz(t *testing.T) {
+	srv := httptest.NewServer(New(new(mocks.StorageProvider), GetMockConfig()).GetRouter())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandlerService_Readyz(t *testing.T) {
+	accrual := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer accrual.Close()
+
+	testCases := []struct {
+		name           string
+		accrualURL     string
+		draining       bool
+		pingErr        error
+		migrationsErr  error
+		expectedCode   int
+		expectedFailed []string
+	}{
+		{
+			name:         "все зависимости доступны",
+			accrualURL:   accrual.URL,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "postgres недоступен",
+			accrualURL:     accrual.URL,
+			pingErr:        errors.New("connection refused"),
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedFailed: []string{"postgres"},
+		},
+		{
+			name:           "миграции не применены",
+			accrualURL:     accrual.URL,
+			migrationsErr:  postgres.ErrMigrationsPending,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedFailed: []string{"migrations"},
+		},
+		{
+			name:           "система лояльности недоступна",
+			accrualURL:     "http://127.0.0.1:1",
+			expectedCode:   http.StatusOK,
+			expectedFailed: []string{"accrual"},
+		},
+		{
+			name:           "сервер останавливается",
+			accrualURL:     accrual.URL,
+			draining:       true,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedFailed: []string{"shutdown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := GetMockConfig()
+			cfg.AcrualURL = tc.accrualURL
+
+			providerMock := new(mocks.StorageProvider)
+			providerMock.On("Ping", mock.Anything).Return(tc.pingErr)
+			providerMock.On("CheckMigrations", mock.Anything).Return(tc.migrationsErr)
+
+			service := New(providerMock, cfg)
+			if tc.draining {
+				service.StartDraining()
+			}
+			srv := httptest.NewServer(service.GetRouter())
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/readyz")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+
+			var readiness models.Readiness
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&readiness))
+			assert.Equal(t, tc.expectedCode == http.StatusOK, readiness.Ready)
+
+			var failed []string
+			for name, check := range readiness.Checks {
+				if check.Status == models.CheckFail {
+					failed = append(failed, name)
+				}
+			}
+			assert.ElementsMatch(t, tc.expectedFailed, failed)
+		})
+	}
+}
+
+func TestHandlerService_ReadyzCachesAccrualCheck(t *testing.T) {
+	var requests atomic.Int32
+	accrual := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer accrual.Close()
+
+	cfg := GetMockConfig()
+	cfg.AcrualURL = accrual.URL
+	providerMock := new(mocks.StorageProvider)
+	providerMock.On("Ping", mock.Anything).Return(nil)
+	providerMock.On("CheckMigrations", mock.Anything).Return(nil)
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(srv.URL + "/readyz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// частые пробы не ходят в систему лояльности каждый раз
+	assert.Equal(t, int32(1), requests.Load())
+	providerMock.AssertNumberOfCalls(t, "CheckMigrations", 3)
+}
+
+func TestHandlerService_UnknownRoutes(t *testing.T) {
+	srv := httptest.NewServer(New(new(mocks.StorageProvider), GetMockConfig()).GetRouter())
+	defer srv.Close()
+
+	testCases := []struct {
+		name            string
+		method          string
+		path            string
+		expectedCode    int
+		expectedProblem models.ProblemCode
+	}{
+		{
+			name:            "неизвестный путь без токена",
+			method:          http.MethodGet,
+			path:            "/api/unknown",
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: models.ProblemNotFound,
+		},
+		{
+			name:            "неподдерживаемый метод",
+			method:          http.MethodDelete,
+			path:            "/api/user/orders",
+			expectedCode:    http.StatusMethodNotAllowed,
+			expectedProblem: models.ProblemMethodNotAllowed,
+		},
+		{
+			name:            "известный путь без токена",
+			method:          http.MethodGet,
+			path:            "/api/user/orders",
+			expectedCode:    http.StatusUnauthorized,
+			expectedProblem: models.ProblemUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, fmt.Sprintf("%s%s", srv.URL, tc.path), nil)
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			var problem models.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, tc.expectedProblem, problem.Code)
+		})
+	}
+}
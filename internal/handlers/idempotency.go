@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("idempotency key must be 1-255 characters long")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used with a different request")
+	ErrRequestInProgress     = errors.New("request with this idempotency key is still in progress")
+)
+
+// idempotencyMiddleware повторяет сохранённый ответ для запросов с уже использованным Idempotency-Key.
+// Запросы без заголовка обрабатываются как обычно.
+func (h *HandlerService) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, r, ErrInvalidIdempotencyKey)
+			return
+		}
+
+		userID, err := getUserFromRequest(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, bodyError(err, ErrMalformedBody))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		record, err := h.provider.ReserveIdempotencyKey(r.Context(), userID, key, fingerprint, h.cfg.IdempotencyKeyTTL)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if record != nil {
+			replayIdempotentResponse(w, r, record, fingerprint)
+			return
+		}
+
+		// ответ сохраняем даже если клиент уже отключился
+		ctx := context.WithoutCancel(r.Context())
+
+		// при панике обработчика ключ освобождается, чтобы повтор выполнил запрос заново;
+		// саму панику, не перехватывая, дальше обрабатывает recoverMiddleware
+		handled := false
+		defer func() {
+			if !handled {
+				h.releaseIdempotencyKey(ctx, userID, key)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		handled = true
+
+		if retryableStatus(recorder.statusCode()) {
+			h.releaseIdempotencyKey(ctx, userID, key)
+			return
+		}
+
+		err = h.provider.CompleteIdempotencyKey(ctx, userID, key, models.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  recorder.statusCode(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			logger.FromContext(r.Context()).Error("не удалось сохранить ответ для ключа идемпотентности", zap.Error(err))
+		}
+	})
+}
+
+func (h *HandlerService) releaseIdempotencyKey(ctx context.Context, userID string, key string) {
+	if err := h.provider.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+		logger.FromContext(ctx).Error("не удалось освободить ключ идемпотентности", zap.Error(err))
+	}
+}
+
+// ответы, после которых клиент может повторить запрос с тем же ключом: внутренняя ошибка, а также отказ
+// антифрода и неверный пароль подтверждения — операция не выполнялась, и повтор с паролем должен дойти до неё
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusPreconditionRequired:
+		return true
+	}
+	return code >= http.StatusInternalServerError
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, record *models.IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		writeError(w, r, ErrIdempotencyKeyReused)
+		return
+	}
+	if !record.Completed() {
+		writeError(w, r, ErrRequestInProgress)
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// отпечаток запроса, по которому определяется повторное использование ключа с другим телом
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder запоминает статус и тело ответа, продолжая писать их клиенту
+type bodyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bodyRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_Idempotency(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	body := `{"order":"2377225624","sum":100}`
+
+	// отпечаток, который получит запрос с телом body
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", nil)
+	fingerprint := requestFingerprint(req, []byte(body))
+
+	testCases := []struct {
+		name             string
+		key              string
+		setup            func(m *mocks.StorageProvider)
+		expectedCode     int
+		expectedProblem  models.ProblemCode
+		expectedReplayed bool
+	}{
+		{
+			name: "первый запрос выполняется и сохраняется",
+			key:  "key-1",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "user", "key-1", fingerprint, cfg.IdempotencyKeyTTL).Return(nil, nil)
+				m.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil).Once()
+				m.On("CompleteIdempotencyKey", mock.Anything, "user", "key-1", mock.MatchedBy(func(r models.IdempotencyRecord) bool {
+					return r.StatusCode == http.StatusOK && r.Fingerprint == fingerprint
+				})).Return(nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "повтор возвращает сохранённый ответ",
+			key:  "key-2",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "user", "key-2", fingerprint, cfg.IdempotencyKeyTTL).
+					Return(&models.IdempotencyRecord{Fingerprint: fingerprint, StatusCode: http.StatusOK}, nil)
+			},
+			expectedCode:     http.StatusOK,
+			expectedReplayed: true,
+		},
+		{
+			name: "ключ с другим телом",
+			key:  "key-3",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "user", "key-3", fingerprint, cfg.IdempotencyKeyTTL).
+					Return(&models.IdempotencyRecord{Fingerprint: "other", StatusCode: http.StatusOK}, nil)
+			},
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedProblem: models.ProblemIdempotencyReused,
+		},
+		{
+			name: "исходный запрос ещё выполняется",
+			key:  "key-4",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "user", "key-4", fingerprint, cfg.IdempotencyKeyTTL).
+					Return(&models.IdempotencyRecord{Fingerprint: fingerprint}, nil)
+			},
+			expectedCode:    http.StatusConflict,
+			expectedProblem: models.ProblemRequestInProgress,
+		},
+		{
+			name: "при внутренней ошибке ключ освобождается",
+			key:  "key-5",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "user", "key-5", fingerprint, cfg.IdempotencyKeyTTL).Return(nil, nil)
+				m.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(fmt.Errorf("db is down")).Once()
+				m.On("ReleaseIdempotencyKey", mock.Anything, "user", "key-5").Return(nil).Once()
+			},
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: models.ProblemInternal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			allowFraudChecks(providerMock)
+			tc.setup(providerMock)
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/withdraw", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set(IdempotencyKeyHeader, tc.key)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Equal(t, tc.expectedReplayed, resp.Header.Get(IdempotentReplayedHeader) == "true")
+			if tc.expectedProblem != "" {
+				var problem models.Problem
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+				assert.Equal(t, tc.expectedProblem, problem.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerService_IdempotencyAfterPanic(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	body := `{"order":"2377225624","sum":100}`
+
+	providerMock := mocks.NewStorageProvider(t)
+	allowFraudChecks(providerMock)
+	providerMock.On("ReserveIdempotencyKey", mock.Anything, "user", "key-panic", mock.Anything, cfg.IdempotencyKeyTTL).Return(nil, nil).Twice()
+	providerMock.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, models.WithdrawalLimits{}).
+		Run(func(mock.Arguments) { panic("boom") }).Return(nil).Once()
+	providerMock.On("ReleaseIdempotencyKey", mock.Anything, "user", "key-panic").Return(nil).Once()
+	providerMock.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil).Once()
+	providerMock.On("CompleteIdempotencyKey", mock.Anything, "user", "key-panic", mock.MatchedBy(func(r models.IdempotencyRecord) bool {
+		return r.StatusCode == http.StatusOK
+	})).Return(nil).Once()
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	withdraw := func() int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/withdraw", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set(IdempotencyKeyHeader, "key-panic")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// паника не оставляет ключ занятым, и повтор с тем же ключом выполняется
+	assert.Equal(t, http.StatusInternalServerError, withdraw())
+	assert.Equal(t, http.StatusOK, withdraw())
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// отдаёт текущий уровень логирования и пользователей с отладочными логами
+func (h *HandlerService) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, currentLogLevel())
+}
+
+// меняет уровень логирования без перезапуска; список пользователей заменяется целиком
+func (h *HandlerService) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+
+	var request models.LogLevel
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	// значение уже проверено валидатором
+	level, _ := zapcore.ParseLevel(request.Level)
+	previous := logger.Level.Level()
+	logger.Level.SetLevel(level)
+	logger.SetDebugUsers(request.DebugUsers)
+
+	// пишем на warn, чтобы смена уровня попала в лог при любом уровне, кроме error
+	logger.FromContext(r.Context()).Warn("уровень логирования изменён",
+		zap.Stringer("previous", previous),
+		zap.Stringer("level", level),
+		zap.Strings("debug_users", request.DebugUsers),
+	)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, currentLogLevel())
+}
+
+func currentLogLevel() models.LogLevel {
+	users := logger.DebugUsers()
+	sort.Strings(users)
+	return models.LogLevel{Level: logger.Level.String(), DebugUsers: users}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_LogLevel(t *testing.T) {
+	original := logger.Level.Level()
+	defer func() {
+		logger.Level.SetLevel(original)
+		logger.SetDebugUsers(nil)
+	}()
+
+	cfg := GetMockConfig()
+	srv := httptest.NewServer(New(new(mocks.StorageProvider), cfg).GetRouter())
+	defer srv.Close()
+
+	testCases := []struct {
+		name          string
+		body          string
+		expectedCode  int
+		expectedLevel zapcore.Level
+		expectedUsers []string
+	}{
+		{
+			name:          "повышение уровня и отладка пользователя",
+			body:          `{"level":"warn","debug_users":["bob","alice"]}`,
+			expectedCode:  http.StatusOK,
+			expectedLevel: zapcore.WarnLevel,
+			expectedUsers: []string{"alice", "bob"},
+		},
+		{
+			name:          "отладка отключается пустым списком",
+			body:          `{"level":"debug"}`,
+			expectedCode:  http.StatusOK,
+			expectedLevel: zapcore.DebugLevel,
+			expectedUsers: []string{},
+		},
+		{
+			name:          "неизвестный уровень не меняет настройки",
+			body:          `{"level":"verbose","debug_users":["alice"]}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedLevel: zapcore.DebugLevel,
+			expectedUsers: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, srv.URL+"/api/admin/log-level", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+
+			req, err = http.NewRequest(http.MethodGet, srv.URL+"/api/admin/log-level", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+
+			resp, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var current models.LogLevel
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&current))
+			assert.Equal(t, tc.expectedLevel.String(), current.Level)
+			assert.Equal(t, tc.expectedUsers, current.DebugUsers)
+			assert.Equal(t, tc.expectedLevel, logger.Level.Level())
+		})
+	}
+}
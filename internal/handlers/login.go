@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -10,7 +11,7 @@ import (
 	"github.com/zYoma/gophermart/internal/auth/jwt"
 	"github.com/zYoma/gophermart/internal/logger"
 	"github.com/zYoma/gophermart/internal/models"
-	"go.uber.org/zap"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
 )
 
 func (h *HandlerService) Login(w http.ResponseWriter, r *http.Request) {
@@ -19,32 +20,37 @@ func (h *HandlerService) Login(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := decodeAndValidateBody(w, r, &credentials); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	passwordHash, err := h.provider.GetPasswordHash(r.Context(), credentials.Login)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при запросе в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error find user"))
+		writeError(w, r, err)
 		return
 	}
 
 	if !hash.CheckPassword(passwordHash, credentials.Password) {
-		w.WriteHeader(http.StatusUnauthorized)
-		logger.Log.Error("неверная пара логин/пароль")
-		render.JSON(w, r, models.Error("wrong credentials"))
+		logger.FromContext(r.Context()).Error("неверная пара логин/пароль")
+		writeError(w, r, ErrWrongCredentials)
 		return
 	}
 
-	token, err := jwt.BuildJWTString(credentials.Login, h.cfg.TokenSecret)
+	// вход в период ожидания означает, что пользователь передумал удалять аккаунт
+	err = h.provider.CancelAccountDeletion(r.Context(), credentials.Login)
+	switch {
+	case err == nil:
+		logger.FromContext(r.Context()).Info("удаление аккаунта отменено входом")
+	case !errors.Is(err, postgres.ErrDeletionNotFound):
+		writeError(w, r, err)
+		return
+	}
+
+	token, err := jwt.BuildJWTString(credentials.Login, h.cfg.Secrets().TokenSecret)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка генерации токена", zap.Error(err))
-		render.JSON(w, r, models.Error("error create user"))
+		writeError(w, r, err)
 		return
 	}
+	h.recordUserIP(r, credentials.Login)
 	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	w.WriteHeader(http.StatusOK)
 	response := models.AccessToken{Token: token, TokenType: "Bearer"}
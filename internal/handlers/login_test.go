@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,12 +13,16 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/zYoma/gophermart/internal/mocks"
 	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
 )
 
 func TestHandlerService_Login(t *testing.T) {
 	cfg := GetMockConfig()
 
 	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("CancelAccountDeletion", mock.Anything, "user").Return(postgres.ErrDeletionNotFound)
 	service := New(providerMock, cfg)
 	r := service.GetRouter()
 	srv := httptest.NewServer(r)
@@ -92,3 +97,32 @@ func TestHandlerService_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerService_LoginDoesNotRevealUnknownUser(t *testing.T) {
+	providerMock := new(mocks.StorageProvider)
+	allowFraudChecks(providerMock)
+	providerMock.On("GetPasswordHash", mock.Anything, "ghost").Return("", postgres.ErrUserNotFound)
+	providerMock.On("GetPasswordHash", mock.Anything, "jack").Return("$2a$10$dheHgk3mKFTybDiYQ6RmfeLTeBZMOcrNTqA1DMU5uxNJi0dth34wm", nil)
+	srv := httptest.NewServer(New(providerMock, GetMockConfig()).GetRouter())
+	defer srv.Close()
+
+	login := func(user string) []byte {
+		body, err := json.Marshal(models.Credantials{Login: user, Password: "wrong"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/login", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(RequestIDHeader, "login-check")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return data
+	}
+
+	// неизвестный логин и неверный пароль неотличимы по ответу
+	assert.Equal(t, string(login("jack")), string(login("ghost")))
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_Metrics(t *testing.T) {
+	cfg := GetMockConfig()
+
+	providerMock := new(mocks.StorageProvider)
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	service := New(providerMock, cfg)
+	srv := httptest.NewServer(service.GetRouter())
+	defer srv.Close()
+
+	providerMock.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, nil)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/user/balance", ""},
+		{http.MethodPost, "/api/user/balance/withdraw", `{"order":"2377225624","sum":100}`},
+		{http.MethodGet, "/api/unknown", ""},
+	}
+	for _, rq := range requests {
+		req, err := http.NewRequest(rq.method, srv.URL+rq.path, strings.NewReader(rq.body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	metricsText := string(body)
+	assert.Contains(t, metricsText, `gophermart_http_request_duration_seconds_count{method="GET",route="/api/user/balance",status="200"}`)
+	assert.Contains(t, metricsText, `gophermart_http_request_duration_seconds_count{method="POST",route="/api/user/balance/withdraw",status="200"}`)
+	assert.Contains(t, metricsText, `route="unmatched"`)
+	assert.Contains(t, metricsText, "gophermart_points_withdrawn_total")
+}
@@ -2,13 +2,29 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/zYoma/gophermart/internal/auth/jwt"
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,12 +34,76 @@ const (
 	UserIDKey contextKey = "userID"
 )
 
+// RequestIDHeader заголовок с идентификатором запроса: принимается от клиента и возвращается в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// максимальная длина идентификатора запроса, принятого от клиента
+const maxRequestIDLength = 128
+
 var noAuthRequired = []string{
 	"/api/user/register",
 	"/api/user/login",
+	"/api/openapi.json",
+	"/api/docs",
+	"/metrics",
+	"/healthz",
+	"/readyz",
 }
 var ErrGetUserFromRequest = errors.New("faild get user")
 
+// пути административного и партнёрского API, которые авторизуются отдельным токеном
+const adminPathPrefix = "/api/admin/"
+
+// берёт идентификатор запроса из X-Request-ID или генерирует новый и возвращает его в ответе.
+// Идентификатор кладётся под ключ chi, поэтому middleware.GetReqID продолжает работать.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// идентификатор от клиента попадает в логи, поэтому допускаем только короткие печатные ASCII-строки
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand не возвращает ошибку на поддерживаемых платформах
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// кладёт в контекст логер с полями запроса; пользователь добавляется после авторизации
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := []zap.Field{zap.String("request_id", middleware.GetReqID(r.Context()))}
+		if route := matchedRoute(r); route != "" {
+			fields = append(fields, zap.String("route", route))
+		}
+		if id := traceID(r.Context()); id != "" {
+			fields = append(fields, zap.String("trace_id", id))
+		}
+
+		next.ServeHTTP(w, r.WithContext(logger.With(r.Context(), fields...)))
+	})
+}
+
 func handlerLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -35,7 +115,7 @@ func handlerLogger(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		logger.Log.Info("handlerLogger",
+		logger.FromContext(r.Context()).Info("handlerLogger",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Duration("duration", duration),
@@ -46,6 +126,105 @@ func handlerLogger(next http.Handler) http.Handler {
 	})
 }
 
+// перехватывает панику обработчика: пишет стек в лог и отвечает 500, не роняя соединение
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &responseRecorder{w, 0, 0}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// ErrAbortHandler — штатный способ прервать ответ, его обрабатывает net/http
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			logger.FromContext(r.Context()).Error("паника в обработчике",
+				zap.Any("panic", rec),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			// если ответ уже начат, исправить его нельзя
+			if recorder.status == 0 {
+				writeProblem(w, r, internalProblem, "", nil)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// ограничивает размер тела запроса; вложенные ограничения действуют вместе, срабатывает меньшее
+func limitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// заранее известный размер проверяем до чтения тела
+			if r.ContentLength > limit {
+				writeError(w, r, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// записывает длительность запроса с шаблоном маршрута chi, чтобы не плодить метки по номерам заказов
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{w, http.StatusOK, 0}
+
+		next.ServeHTTP(recorder, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// открывает серверный спан на каждый запрос, продолжая трассу из заголовка traceparent
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request_id", middleware.GetReqID(r.Context())),
+			),
+		)
+		defer span.End()
+
+		recorder := &responseRecorder{w, http.StatusOK, 0}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		// шаблон маршрута известен только после роутинга
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// идентификатор трассы для логов, пустой если запрос не трассируется
+func traceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
 type responseRecorder struct {
 	http.ResponseWriter
 	status int
@@ -67,33 +246,37 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 
 func (h *HandlerService) jwtAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		secret := h.cfg.TokenSecret
-
-		if !pathRequiresAuth(r.RequestURI) {
+		// неизвестные пути и методы уходят в NotFound/MethodNotAllowed роутера, а не в 401
+		if !pathRequiresAuth(r.RequestURI) || !routeExists(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+			writeError(w, r, fmt.Errorf("%w: authorization header is missing", ErrUnauthorized))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+			writeError(w, r, fmt.Errorf("%w: invalid authorization header format", ErrUnauthorized))
 			return
 		}
 
 		token := parts[1]
-		userID := jwt.GetUserID(token, secret)
+		secrets := h.cfg.Secrets()
+		userID := jwt.GetUserID(token, secrets.TokenSecret, secrets.PreviousTokenSecret)
 		if userID == "" {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			writeError(w, r, fmt.Errorf("%w: invalid or expired token", ErrUnauthorized))
 			return
 		}
-		// Передаем идентификатор пользователя в контекст запроса
+		// Передаем идентификатор пользователя в контекст запроса и в логер
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = logger.With(ctx, zap.String("user", userID))
+		if logger.IsDebugUser(userID) {
+			ctx = logger.WithDebug(ctx)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -106,8 +289,72 @@ func getUserFromRequest(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
+// проверяет токен административного API; при пустом токене в конфиге API отключено
+func (h *HandlerService) adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := h.cfg.Secrets().AdminToken
+		if adminToken == "" {
+			writeError(w, r, fmt.Errorf("%w: admin API is disabled", ErrForbidden))
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			writeError(w, r, fmt.Errorf("%w: invalid admin token", ErrUnauthorized))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// требует клиентский сертификат партнёра, если в конфиге задан CA для них;
+// сам сертификат уже проверен при TLS-рукопожатии
+func (h *HandlerService) clientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cfg.TLSClientCAFile == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			writeError(w, r, fmt.Errorf("%w: client certificate is required", ErrUnauthorized))
+			return
+		}
+
+		subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		ctx := logger.With(r.Context(), zap.String("client_cert", subject))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// проверяет, что для метода и пути запроса зарегистрирован обработчик
+func routeExists(r *http.Request) bool {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return true
+	}
+	return rctx.Routes.Match(chi.NewRouteContext(), r.Method, r.URL.Path)
+}
+
+// шаблон маршрута, который обработает запрос, до роутинга; пустая строка, если маршрута нет
+func matchedRoute(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	match := chi.NewRouteContext()
+	if !rctx.Routes.Match(match, r.Method, r.URL.Path) {
+		return ""
+	}
+	return match.RoutePattern()
+}
+
 // Функция проверки пути на наличие в списке исключений.
 func pathRequiresAuth(path string) bool {
+	if strings.HasPrefix(path, adminPathPrefix) {
+		return false
+	}
 	for _, p := range noAuthRequired {
 		if p == path {
 			return false
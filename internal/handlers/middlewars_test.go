@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	srv := httptest.NewServer(New(new(mocks.StorageProvider), GetMockConfig()).GetRouter())
+	defer srv.Close()
+
+	testCases := []struct {
+		name       string
+		requestID  string
+		expectEcho bool
+	}{
+		{name: "идентификатор клиента возвращается", requestID: "client-id-42", expectEcho: true},
+		{name: "без идентификатора генерируется новый", requestID: ""},
+		{name: "слишком длинный идентификатор заменяется", requestID: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "идентификатор с управляющими символами заменяется", requestID: "id\twith\ttabs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/orders", nil)
+			require.NoError(t, err)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			requestID := resp.Header.Get(RequestIDHeader)
+			require.NotEmpty(t, requestID)
+			if tc.expectEcho {
+				assert.Equal(t, tc.requestID, requestID)
+			} else {
+				assert.NotEqual(t, tc.requestID, requestID)
+			}
+
+			// тот же идентификатор в теле ошибки
+			var problem models.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, requestID, problem.RequestID)
+		})
+	}
+}
+
+func TestRequestLogger_CarriesRequestFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	original := logger.Log
+	logger.Log = zap.New(core)
+	defer func() { logger.Log = original }()
+
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, errors.New("db is down"))
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/balance", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set(RequestIDHeader, "req-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	entries := logs.FilterMessage("внутренняя ошибка").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, "user", fields["user"])
+	assert.Equal(t, "/api/user/balance", fields["route"])
+}
+
+func TestJWTAuthMiddleware_SecretRotation(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token_secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("old-secret\n"), 0o600))
+
+	cfg := GetMockConfig()
+	cfg.TokenSecret = "old-secret"
+	cfg.TokenSecretFile = secretFile
+	oldToken, _ := jwt.BuildJWTString("user", "old-secret")
+	newToken, _ := jwt.BuildJWTString("user", "new-secret")
+	foreignToken, _ := jwt.BuildJWTString("user", "foreign-secret")
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, nil)
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	status := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/balance", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status(oldToken))
+	assert.Equal(t, http.StatusUnauthorized, status(newToken))
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("new-secret\n"), 0o600))
+	changed, err := cfg.ReloadSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"token_secret"}, changed)
+
+	// выданные до ротации токены продолжают работать
+	assert.Equal(t, http.StatusOK, status(oldToken))
+	assert.Equal(t, http.StatusOK, status(newToken))
+	assert.Equal(t, http.StatusUnauthorized, status(foreignToken))
+}
+
+func TestClientCertMiddleware(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.TLSClientCAFile = "partners-ca.pem"
+	router := New(new(mocks.StorageProvider), cfg).GetRouter()
+
+	partner := &x509.Certificate{Subject: pkix.Name{CommonName: "partner"}}
+	testCases := []struct {
+		name           string
+		tls            *tls.ConnectionState
+		expectedStatus int
+	}{
+		{name: "без TLS", expectedStatus: http.StatusUnauthorized},
+		{name: "без клиентского сертификата", tls: &tls.ConnectionState{}, expectedStatus: http.StatusUnauthorized},
+		{
+			name:           "с проверенным сертификатом",
+			tls:            &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{partner}}},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/log-level", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+			req.TLS = tc.tls
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.MaxBodyBytes = 64 << 10
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	providerMock := new(mocks.StorageProvider)
+	allowFraudChecks(providerMock)
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		// тело без Content-Length: предел срабатывает только при чтении
+		chunked bool
+	}{
+		{name: "номер заказа больше предела маршрута", method: http.MethodPost, path: "/api/user/orders", body: strings.Repeat("1", orderBodyLimit+1)},
+		{name: "номер заказа потоком", method: http.MethodPost, path: "/api/user/orders", body: strings.Repeat("1", orderBodyLimit+1), chunked: true},
+		{name: "JSON больше предела маршрута", method: http.MethodPost, path: "/api/user/login", body: `{"login":"` + strings.Repeat("a", smallJSONBodyLimit) + `"}`, chunked: true},
+		{name: "тело больше общего предела", method: http.MethodPut, path: "/api/admin/log-level", body: strings.Repeat(" ", cfg.MaxBodyBytes+1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.chunked {
+				body = io.MultiReader(body)
+			}
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, body)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			if strings.HasPrefix(tc.path, adminPathPrefix) {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+			var problem models.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, models.ProblemPayloadTooLarge, problem.Code)
+		})
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	original := logger.Log
+	logger.Log = zap.New(core)
+	defer func() { logger.Log = original }()
+
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Run(func(mock.Arguments) {
+		panic("nil map")
+	})
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/balance", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set(RequestIDHeader, "req-panic")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	var problem models.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, models.ProblemInternal, problem.Code)
+	assert.Empty(t, problem.Detail)
+
+	entries := logs.FilterMessage("паника в обработчике").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "nil map", fields["panic"])
+	assert.Equal(t, "req-panic", fields["request_id"])
+	assert.Contains(t, fields["stack"], "GetBalance")
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/zYoma/gophermart/internal/openapi"
+)
+
+// отдает OpenAPI спецификацию сервиса
+func (h *HandlerService) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec)
+}
+
+// отдает страницу с документацией по API
+func (h *HandlerService) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.DocsPage)
+}
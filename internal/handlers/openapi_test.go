@@ -0,0 +1,1360 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/hash"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/openapi"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openapi.Spec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	return doc, router
+}
+
+func TestOpenAPI_AllRoutesDocumented(t *testing.T) {
+	doc, _ := loadSpec(t)
+
+	service := New(new(mocks.StorageProvider), GetMockConfig())
+	err := chi.Walk(service.GetRouter(), func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		path := doc.Paths.Find(route)
+		if path == nil {
+			return fmt.Errorf("route %s is not documented", route)
+		}
+		if path.GetOperation(method) == nil {
+			return fmt.Errorf("method %s %s is not documented", method, route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenAPI_HandlersMatchSpec(t *testing.T) {
+	cfg := GetMockConfig()
+	// со сгоранием баллов баланс отдаёт разбивку expiring_soon
+	cfg.PointsExpiryMonths = 12
+	cfg.PointsExpiringSoonWindow = 30 * 24 * time.Hour
+	cfg.FraudOrderHourlyLimit = 100
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	passHash, _ := hash.HashPassword("password")
+	accrual := 500.0
+	errDB := errors.New("db is down")
+
+	_, specRouter := loadSpec(t)
+
+	exportedAt := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	deleteAfter := exportedAt.Add(30 * 24 * time.Hour)
+	exportData := func(m *mocks.StorageProvider) {
+		m.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{
+			Login: "user", ReferralCode: "K5QXGZ3B", Tier: models.TierProgress{Name: "BASE", Multiplier: 1},
+		}, nil)
+		m.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{Current: 349.5, Withdrawn: 150.5}, nil)
+		m.On("GetUserOrders", mock.Anything, "user").Return([]models.Order{
+			{Number: "79927398713", Status: "PROCESSED", Accrual: &accrual, UploadedAt: exportedAt},
+		}, nil)
+		m.On("GetUserWithdrawals", mock.Anything, "user").Return(nil, postgres.ErrWithdrawalsNotFound)
+		m.On("GetUserTransfers", mock.Anything, "user").Return(nil, postgres.ErrTransfersNotFound)
+		m.On("GetBalanceHistory", mock.Anything, "user").Return([]models.BalanceEntry{
+			{Kind: models.BalanceWithdrawal, Order: "2377225624", Sum: -150.5, CreatedAt: exportedAt},
+			{Kind: models.BalanceCredit, Source: models.PointLotAccrual, Order: "79927398713", Sum: 500, CreatedAt: exportedAt},
+		}, nil)
+	}
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		contentType  string
+		body         string
+		auth         bool
+		adminAuth    bool
+		setup        func(m *mocks.StorageProvider)
+		expectedCode int
+		// запрос намеренно нарушает контракт, проверяем только ответ
+		invalidRequest bool
+	}{
+		{
+			name:        "регистрация",
+			method:      http.MethodPost,
+			path:        "/api/user/register",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateUser", mock.Anything, "user", mock.Anything, "").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "регистрация с пустым телом",
+			method:         http.MethodPost,
+			path:           "/api/user/register",
+			contentType:    "application/json",
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:           "регистрация без пароля",
+			method:         http.MethodPost,
+			path:           "/api/user/register",
+			contentType:    "application/json",
+			body:           `{"login":"user"}`,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "регистрация занятого логина",
+			method:      http.MethodPost,
+			path:        "/api/user/register",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateUser", mock.Anything, "user", mock.Anything, "").Return(postgres.ErrConflict)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "регистрация при ошибке БД",
+			method:      http.MethodPost,
+			path:        "/api/user/register",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateUser", mock.Anything, "user", mock.Anything, "").Return(errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "регистрация по реферальному коду",
+			method:      http.MethodPost,
+			path:        "/api/user/register",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password","referral_code":"k5qxgz3b"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateUser", mock.Anything, "user", mock.Anything, "K5QXGZ3B").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "регистрация с неизвестным реферальным кодом",
+			method:      http.MethodPost,
+			path:        "/api/user/register",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password","referral_code":"NOPE0000"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateUser", mock.Anything, "user", mock.Anything, "NOPE0000").Return(postgres.ErrReferralNotFound)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "вход",
+			method:      http.MethodPost,
+			path:        "/api/user/login",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(postgres.ErrDeletionNotFound)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "вход с битым телом",
+			method:         http.MethodPost,
+			path:           "/api/user/login",
+			contentType:    "application/json",
+			body:           `{"login":`,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "вход с неверным паролем",
+			method:      http.MethodPost,
+			path:        "/api/user/login",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"wrong"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "вход несуществующего пользователя",
+			method:      http.MethodPost,
+			path:        "/api/user/login",
+			contentType: "application/json",
+			body:        `{"login":"nobody","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "nobody").Return("", postgres.ErrUserNotFound)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "вход при ошибке БД",
+			method:      http.MethodPost,
+			path:        "/api/user/login",
+			contentType: "application/json",
+			body:        `{"login":"user","password":"password"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return("", errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "загрузка нового заказа",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateOrder", mock.Anything, "79927398713", "user").Return(nil)
+				m.On("UpdateOrderAndAccrualPoints", mock.Anything, mock.Anything).Return(0.0, nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:        "повторная загрузка заказа",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateOrder", mock.Anything, "79927398713", "user").Return(postgres.ErrOrderAlredyExist)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "загрузка заказа с пустым телом",
+			method:         http.MethodPost,
+			path:           "/api/user/orders",
+			contentType:    "text/plain",
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "загрузка заказа без токена",
+			method:       http.MethodPost,
+			path:         "/api/user/orders",
+			contentType:  "text/plain",
+			body:         "79927398713",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "загрузка чужого заказа",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateOrder", mock.Anything, "79927398713", "user").Return(postgres.ErrCreatedByOtherUser)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:           "загрузка заказа со слишком большим телом",
+			method:         http.MethodPost,
+			path:           "/api/user/orders",
+			contentType:    "text/plain",
+			body:           strings.Repeat("1", orderBodyLimit+1),
+			auth:           true,
+			expectedCode:   http.StatusRequestEntityTooLarge,
+			invalidRequest: true,
+		},
+		{
+			name:         "загрузка заказа с неверным номером",
+			method:       http.MethodPost,
+			path:         "/api/user/orders",
+			contentType:  "text/plain",
+			body:         "12345",
+			auth:         true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "загрузка заказа при ошибке БД",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateOrder", mock.Anything, "79927398713", "user").Return(errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "загрузка заказа сверх часового лимита",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{OrdersLastHour: 100}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:        "загрузка чужого заказа",
+			method:      http.MethodPost,
+			path:        "/api/user/orders",
+			contentType: "text/plain",
+			body:        "79927398713",
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateOrder", mock.Anything, "79927398713", "user").Return(postgres.ErrCreatedByOtherUser)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "спор по заказу",
+			method:      http.MethodPost,
+			path:        "/api/user/orders/79927398713/disputes",
+			contentType: "application/json",
+			body:        `{"evidence":"receipt 0042"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "79927398713", "user", "receipt 0042").Return(models.Dispute{
+					ID: 3, Order: "79927398713", Claimant: "user", Owner: "other", Evidence: "receipt 0042", Status: models.DisputeOpen, CreatedAt: time.Now(),
+				}, nil)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:           "спор без доказательств",
+			method:         http.MethodPost,
+			path:           "/api/user/orders/79927398713/disputes",
+			contentType:    "application/json",
+			body:           `{}`,
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "спор по незагруженному заказу",
+			method:      http.MethodPost,
+			path:        "/api/user/orders/79927398713/disputes",
+			contentType: "application/json",
+			body:        `{"evidence":"receipt 0042"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "79927398713", "user", "receipt 0042").Return(models.Dispute{}, postgres.ErrOrderNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "повторный спор",
+			method:      http.MethodPost,
+			path:        "/api/user/orders/79927398713/disputes",
+			contentType: "application/json",
+			body:        `{"evidence":"receipt 0042"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateDispute", mock.Anything, "79927398713", "user", "receipt 0042").Return(models.Dispute{}, postgres.ErrDisputeExists)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:   "список заказов",
+			method: http.MethodGet,
+			path:   "/api/user/orders",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserOrders", mock.Anything, "user").Return([]models.Order{
+					{Number: "79927398713", Status: "PROCESSED", Accrual: &accrual, UploadedAt: time.Now()},
+					{Number: "2377225624", Status: "NEW", UploadedAt: time.Now()},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "пустой список заказов",
+			method: http.MethodGet,
+			path:   "/api/user/orders",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserOrders", mock.Anything, "user").Return(nil, postgres.ErrOrdersNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "список заказов без токена",
+			method:       http.MethodGet,
+			path:         "/api/user/orders",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "список заказов при ошибке БД",
+			method: http.MethodGet,
+			path:   "/api/user/orders",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserOrders", mock.Anything, "user").Return(nil, errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "баланс",
+			method: http.MethodGet,
+			path:   "/api/user/balance",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{Current: 500.5, Withdrawn: 42}, nil)
+				m.On("GetExpiringPoints", mock.Anything, "user", mock.Anything).Return([]models.ExpiringPoints{
+					{Sum: 120, ExpiresAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "баланс без токена",
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "баланс при ошибке БД",
+			method: http.MethodGet,
+			path:   "/api/user/balance",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "профиль",
+			method: http.MethodGet,
+			path:   "/api/user/profile",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{
+					Login:        "user",
+					ReferralCode: "K5QXGZ3B",
+					ReferredBy:   "jack",
+					Tier: models.TierProgress{
+						Name:              "SILVER",
+						Multiplier:        1.05,
+						QualifyingAccrual: 1500,
+						Next:              &models.NextTier{Name: "GOLD", MinAccrual: 5000, Remaining: 3500},
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "профиль без токена",
+			method:       http.MethodGet,
+			path:         "/api/user/profile",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "профиль с запрошенным удалением",
+			method: http.MethodGet,
+			path:   "/api/user/profile",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserProfile", mock.Anything, "user").Return(models.Profile{
+					Login:               "user",
+					ReferralCode:        "K5QXGZ3B",
+					Tier:                models.TierProgress{Name: "BASE", Multiplier: 1},
+					DeletionScheduledAt: &deleteAfter,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "выгрузка данных",
+			method:       http.MethodGet,
+			path:         "/api/user/export",
+			auth:         true,
+			setup:        exportData,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "выгрузка данных архивом",
+			method:       http.MethodGet,
+			path:         "/api/user/export?format=zip",
+			auth:         true,
+			setup:        exportData,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "выгрузка в неизвестном формате",
+			method:         http.MethodGet,
+			path:           "/api/user/export?format=xml",
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "запрос удаления аккаунта",
+			method:      http.MethodPost,
+			path:        "/api/user/deletion",
+			contentType: "application/json",
+			body:        `{"password":"password"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+				m.On("RequestAccountDeletion", mock.Anything, "user", cfg.AccountDeletionDelay).
+					Return(models.AccountDeletion{RequestedAt: exportedAt, DeleteAfter: deleteAfter}, nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:        "запрос удаления с неверным паролем",
+			method:      http.MethodPost,
+			path:        "/api/user/deletion",
+			contentType: "application/json",
+			body:        `{"password":"wrong"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetPasswordHash", mock.Anything, "user").Return(passHash, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "отмена удаления аккаунта",
+			method: http.MethodDelete,
+			path:   "/api/user/deletion",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "отмена незапрошенного удаления",
+			method: http.MethodDelete,
+			path:   "/api/user/deletion",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CancelAccountDeletion", mock.Anything, "user").Return(postgres.ErrDeletionNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "списание",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 751.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "списание с битым телом",
+			method:         http.MethodPost,
+			path:           "/api/user/balance/withdraw",
+			contentType:    "application/json",
+			body:           `{"order":`,
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "списание без токена",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":"2377225624","sum":751}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "списание при нехватке баллов",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 751.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(postgres.ErrFewPoints)
+			},
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:         "списание с неверным номером",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":"12345","sum":751}`,
+			auth:         true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "списание с аккаунта на проверке",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFraudSignals", mock.Anything, "user", mock.Anything).Return(models.FraudSignals{Flagged: true}, nil)
+				m.On("RecordFraudDecision", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:        "списание при ошибке БД",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 751.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "частичное списание",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":100.5,"partial":true}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 100.5, "user", "2377225624", true, models.WithdrawalLimits{}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "списание с долями копеек",
+			method:         http.MethodPost,
+			path:           "/api/user/balance/withdraw",
+			contentType:    "application/json",
+			body:           `{"order":"2377225624","sum":100.555}`,
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "повторное списание по заказу",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 751.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(postgres.ErrOrderPaid)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "списание сверх суточного лимита",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/withdraw",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":751}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 751.0, "user", "2377225624", false, models.WithdrawalLimits{}).Return(postgres.ErrWithdrawalDaily)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "история списаний",
+			method: http.MethodGet,
+			path:   "/api/user/withdrawals",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserWithdrawals", mock.Anything, "user").Return([]models.Withdrawn{
+					{Order: "2377225624", Sum: 500, ProccesedAt: time.Now(), Status: models.WithdrawalPartiallyRefunded, Refunded: 100},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "пустая история списаний",
+			method: http.MethodGet,
+			path:   "/api/user/withdrawals",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserWithdrawals", mock.Anything, "user").Return(nil, postgres.ErrWithdrawalsNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "история списаний без токена",
+			method:       http.MethodGet,
+			path:         "/api/user/withdrawals",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "история списаний при ошибке БД",
+			method: http.MethodGet,
+			path:   "/api/user/withdrawals",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserWithdrawals", mock.Anything, "user").Return(nil, errDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "резерв баллов",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/holds",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":120}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateHold", mock.Anything, "user", "2377225624", 120.0, mock.Anything, mock.Anything).Return(models.Hold{
+					ID: 7, Order: "2377225624", Sum: 120, Status: models.HoldActive, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(15 * time.Minute),
+				}, nil)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:           "резерв с нулевой суммой",
+			method:         http.MethodPost,
+			path:           "/api/user/balance/holds",
+			contentType:    "application/json",
+			body:           `{"order":"2377225624","sum":0}`,
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "резерв по оплаченному заказу",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/holds",
+			contentType: "application/json",
+			body:        `{"order":"2377225624","sum":120}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreateHold", mock.Anything, "user", "2377225624", 120.0, mock.Anything, mock.Anything).Return(models.Hold{}, postgres.ErrOrderPaid)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "частичное списание резерва",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/holds/7/capture",
+			contentType: "application/json",
+			body:        `{"sum":80}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), mock.Anything).Return(models.Hold{
+					ID: 7, Order: "2377225624", Sum: 120, Captured: 80, Status: models.HoldCaptured, CreatedAt: time.Now(), ExpiresAt: time.Now(),
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "списание больше резерва",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/holds/7/capture",
+			contentType: "application/json",
+			body:        `{"sum":200}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), mock.Anything).Return(models.Hold{}, postgres.ErrCaptureExceedsHold)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "отмена резерва",
+			method: http.MethodPost,
+			path:   "/api/user/balance/holds/7/release",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReleaseHold", mock.Anything, "user", int64(7)).Return(models.Hold{
+					ID: 7, Order: "2377225624", Sum: 120, Status: models.HoldReleased, CreatedAt: time.Now(), ExpiresAt: time.Now(),
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "отмена закрытого резерва",
+			method: http.MethodPost,
+			path:   "/api/user/balance/holds/7/release",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReleaseHold", mock.Anything, "user", int64(7)).Return(models.Hold{}, postgres.ErrHoldNotActive)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "перевод",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/transfer",
+			contentType: "application/json",
+			body:        `{"recipient":"jack","sum":150,"comment":"на подарок"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Transfer", mock.Anything, "user", "jack", 150.0, "на подарок", mock.Anything).Return(models.Transfer{
+					ID: 1, Direction: models.TransferOutgoing, Counterparty: "jack", Sum: 150, Comment: "на подарок", CreatedAt: time.Now(),
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "перевод без получателя",
+			method:         http.MethodPost,
+			path:           "/api/user/balance/transfer",
+			contentType:    "application/json",
+			body:           `{"sum":150}`,
+			auth:           true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "перевод самому себе",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			contentType:  "application/json",
+			body:         `{"recipient":"user","sum":150}`,
+			auth:         true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "перевод при нехватке баллов",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/transfer",
+			contentType: "application/json",
+			body:        `{"recipient":"jack","sum":150}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Transfer", mock.Anything, "user", "jack", 150.0, "", mock.Anything).Return(models.Transfer{}, postgres.ErrFewPoints)
+			},
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:        "перевод сверх суточного лимита",
+			method:      http.MethodPost,
+			path:        "/api/user/balance/transfer",
+			contentType: "application/json",
+			body:        `{"recipient":"jack","sum":150}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Transfer", mock.Anything, "user", "jack", 150.0, "", mock.Anything).Return(models.Transfer{}, postgres.ErrTransferLimit)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "история переводов",
+			method: http.MethodGet,
+			path:   "/api/user/transfers",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserTransfers", mock.Anything, "user").Return([]models.Transfer{
+					{ID: 2, Direction: models.TransferIncoming, Counterparty: "jack", Sum: 50, CreatedAt: time.Now()},
+					{ID: 1, Direction: models.TransferOutgoing, Counterparty: "jack", Sum: 150, Comment: "на подарок", CreatedAt: time.Now()},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "пустая история переводов",
+			method: http.MethodGet,
+			path:   "/api/user/transfers",
+			auth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetUserTransfers", mock.Anything, "user").Return(nil, postgres.ErrTransfersNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:        "возврат списания",
+			method:      http.MethodPost,
+			path:        "/api/admin/withdrawals/2377225624/refund",
+			contentType: "application/json",
+			body:        `{"sum":100,"reason":"order cancelled"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RefundWithdrawal", mock.Anything, "2377225624", mock.Anything, "order cancelled").Return(models.Withdrawn{
+					Order: "2377225624", Sum: 500, ProccesedAt: time.Now(), Status: models.WithdrawalPartiallyRefunded, Refunded: 100,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "возврат без причины",
+			method:         http.MethodPost,
+			path:           "/api/admin/withdrawals/2377225624/refund",
+			contentType:    "application/json",
+			body:           `{"sum":100}`,
+			adminAuth:      true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "возврат с токеном пользователя",
+			method:       http.MethodPost,
+			path:         "/api/admin/withdrawals/2377225624/refund",
+			contentType:  "application/json",
+			body:         `{"reason":"order cancelled"}`,
+			auth:         true,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "возврат несуществующего списания",
+			method:      http.MethodPost,
+			path:        "/api/admin/withdrawals/2377225624/refund",
+			contentType: "application/json",
+			body:        `{"reason":"order cancelled"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RefundWithdrawal", mock.Anything, "2377225624", mock.Anything, "order cancelled").Return(models.Withdrawn{}, postgres.ErrWithdrawalNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:      "аккаунты на проверке",
+			method:    http.MethodGet,
+			path:      "/api/admin/fraud/flagged",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFlaggedUsers", mock.Anything).
+					Return([]models.FlaggedUser{{Login: "user", Reason: "order-upload-rate", FlaggedAt: time.Now()}}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:      "нет аккаунтов на проверке",
+			method:    http.MethodGet,
+			path:      "/api/admin/fraud/flagged",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetFlaggedUsers", mock.Anything).Return(nil, postgres.ErrFlaggedNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:      "снятие отметки антифрода",
+			method:    http.MethodDelete,
+			path:      "/api/admin/fraud/flagged/user",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ClearFraudFlag", mock.Anything, "user").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:      "снятие отметки с аккаунта не на проверке",
+			method:    http.MethodDelete,
+			path:      "/api/admin/fraud/flagged/user",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ClearFraudFlag", mock.Anything, "user").Return(postgres.ErrFlagNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:      "открытые споры",
+			method:    http.MethodGet,
+			path:      "/api/admin/disputes?status=OPEN",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetDisputes", mock.Anything, models.DisputeOpen).Return([]models.Dispute{{
+					ID: 3, Order: "79927398713", Claimant: "user", Owner: "other", Evidence: "receipt 0042", Status: models.DisputeOpen, CreatedAt: time.Now(),
+				}}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:      "споров нет",
+			method:    http.MethodGet,
+			path:      "/api/admin/disputes",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetDisputes", mock.Anything, models.DisputeStatus("")).Return(nil, postgres.ErrDisputesNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:           "споры с неизвестным статусом",
+			method:         http.MethodGet,
+			path:           "/api/admin/disputes?status=CLOSED",
+			adminAuth:      true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:        "передача заказа по спору",
+			method:      http.MethodPost,
+			path:        "/api/admin/disputes/3/transfer",
+			contentType: "application/json",
+			body:        `{"comment":"receipt verified"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				resolved := time.Now()
+				m.On("TransferDisputedOrder", mock.Anything, int64(3), "receipt verified").Return(models.Dispute{
+					ID: 3, Order: "79927398713", Claimant: "user", Owner: "other", Evidence: "receipt 0042", Status: models.DisputeTransferred,
+					Comment: "receipt verified", Reclaimed: 500, Credited: 500, CreatedAt: resolved.Add(-time.Hour), ResolvedAt: &resolved,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "передача заказа по закрытому спору",
+			method:      http.MethodPost,
+			path:        "/api/admin/disputes/3/transfer",
+			contentType: "application/json",
+			body:        `{"comment":"receipt verified"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("TransferDisputedOrder", mock.Anything, int64(3), "receipt verified").Return(models.Dispute{}, postgres.ErrDisputeNotOpen)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "отклонение спора",
+			method:      http.MethodPost,
+			path:        "/api/admin/disputes/3/reject",
+			contentType: "application/json",
+			body:        `{"comment":"receipt does not match"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				resolved := time.Now()
+				m.On("RejectDispute", mock.Anything, int64(3), "receipt does not match").Return(models.Dispute{
+					ID: 3, Order: "79927398713", Claimant: "user", Owner: "other", Evidence: "receipt 0042", Status: models.DisputeRejected,
+					Comment: "receipt does not match", CreatedAt: resolved.Add(-time.Hour), ResolvedAt: &resolved,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "отклонение несуществующего спора",
+			method:      http.MethodPost,
+			path:        "/api/admin/disputes/9/reject",
+			contentType: "application/json",
+			body:        `{"comment":"receipt does not match"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RejectDispute", mock.Anything, int64(9), "receipt does not match").Return(models.Dispute{}, postgres.ErrDisputeNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "повторный полный возврат",
+			method:      http.MethodPost,
+			path:        "/api/admin/withdrawals/2377225624/refund",
+			contentType: "application/json",
+			body:        `{"reason":"order cancelled"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RefundWithdrawal", mock.Anything, "2377225624", mock.Anything, "order cancelled").Return(models.Withdrawn{}, postgres.ErrAlreadyRefunded)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "возврат больше списанного",
+			method:      http.MethodPost,
+			path:        "/api/admin/withdrawals/2377225624/refund",
+			contentType: "application/json",
+			body:        `{"sum":1000,"reason":"order cancelled"}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RefundWithdrawal", mock.Anything, "2377225624", mock.Anything, "order cancelled").Return(models.Withdrawn{}, postgres.ErrRefundExceedsSum)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "активация промокода",
+			method:      http.MethodPost,
+			path:        "/api/user/promo",
+			contentType: "application/json",
+			body:        `{"code":"welcome2024"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RedeemPromoCode", mock.Anything, "user", "WELCOME2024").Return(models.PromoRedemption{
+					ID: 1, Code: "WELCOME2024", Amount: 100, RedeemedAt: time.Now(),
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "активация истёкшего промокода",
+			method:      http.MethodPost,
+			path:        "/api/user/promo",
+			contentType: "application/json",
+			body:        `{"code":"SPRING"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RedeemPromoCode", mock.Anything, "user", "SPRING").Return(models.PromoRedemption{}, postgres.ErrPromoExpired)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "повторная активация промокода",
+			method:      http.MethodPost,
+			path:        "/api/user/promo",
+			contentType: "application/json",
+			body:        `{"code":"SORRY"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RedeemPromoCode", mock.Anything, "user", "SORRY").Return(models.PromoRedemption{}, postgres.ErrPromoRedeemed)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:        "активация несуществующего промокода",
+			method:      http.MethodPost,
+			path:        "/api/user/promo",
+			contentType: "application/json",
+			body:        `{"code":"NOPE"}`,
+			auth:        true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RedeemPromoCode", mock.Anything, "user", "NOPE").Return(models.PromoRedemption{}, postgres.ErrPromoNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "создание промокода",
+			method:      http.MethodPost,
+			path:        "/api/admin/promo-codes",
+			contentType: "application/json",
+			body:        `{"code":"welcome2024","amount":100,"max_redemptions":1000}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreatePromoCode", mock.Anything, mock.Anything).Return(func(_ context.Context, promo models.PromoCode) (models.PromoCode, error) {
+					return promo, nil
+				})
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:        "создание существующего промокода",
+			method:      http.MethodPost,
+			path:        "/api/admin/promo-codes",
+			contentType: "application/json",
+			body:        `{"code":"WELCOME2024","amount":100}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CreatePromoCode", mock.Anything, mock.Anything).Return(models.PromoCode{}, postgres.ErrPromoExists)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "создание промокода без токена администратора",
+			method:       http.MethodPost,
+			path:         "/api/admin/promo-codes",
+			contentType:  "application/json",
+			body:         `{"code":"WELCOME2024","amount":100}`,
+			auth:         true,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:        "создание кампании",
+			method:      http.MethodPost,
+			path:        "/api/admin/campaigns",
+			contentType: "application/json",
+			body:        `{"name":"Приветственный бонус","event":"REGISTRATION","amount":50}`,
+			adminAuth:   true,
+			setup: func(m *mocks.StorageProvider) {
+				startsAt := time.Now()
+				m.On("CreateCampaign", mock.Anything, mock.Anything).Return(models.Campaign{
+					ID: 1, Name: "Приветственный бонус", Event: models.CampaignRegistration, Amount: 50, StartsAt: &startsAt,
+				}, nil)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:           "создание кампании с неизвестным событием",
+			method:         http.MethodPost,
+			path:           "/api/admin/campaigns",
+			contentType:    "application/json",
+			body:           `{"name":"Бонус","event":"BIRTHDAY","amount":50}`,
+			adminAuth:      true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:      "отчёт о расхождениях",
+			method:    http.MethodGet,
+			path:      "/api/admin/accrual-adjustments?since=2024-02-01T00:00:00Z",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetAccrualAdjustments", mock.Anything, mock.Anything).Return([]models.AccrualAdjustment{
+					{Order: "79927398713", UserLogin: "user", PreviousAccrual: 500, NewAccrual: 300, Delta: -200, Applied: -150, Status: models.AdjustmentClamped, CreatedAt: time.Now()},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:      "нет расхождений",
+			method:    http.MethodGet,
+			path:      "/api/admin/accrual-adjustments",
+			adminAuth: true,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetAccrualAdjustments", mock.Anything, mock.Anything).Return(nil, postgres.ErrAdjustmentsNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:           "отчёт с неверной датой",
+			method:         http.MethodGet,
+			path:           "/api/admin/accrual-adjustments?since=yesterday",
+			adminAuth:      true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "уровень логирования",
+			method:       http.MethodGet,
+			path:         "/api/admin/log-level",
+			adminAuth:    true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "смена уровня логирования",
+			method:       http.MethodPut,
+			path:         "/api/admin/log-level",
+			contentType:  "application/json",
+			body:         `{"level":"info","debug_users":["alice"]}`,
+			adminAuth:    true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "неизвестный уровень логирования",
+			method:         http.MethodPut,
+			path:           "/api/admin/log-level",
+			contentType:    "application/json",
+			body:           `{"level":"verbose"}`,
+			adminAuth:      true,
+			expectedCode:   http.StatusBadRequest,
+			invalidRequest: true,
+		},
+		{
+			name:         "метрики",
+			method:       http.MethodGet,
+			path:         "/metrics",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "спецификация",
+			method:       http.MethodGet,
+			path:         "/api/openapi.json",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "документация",
+			method:       http.MethodGet,
+			path:         "/api/docs",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "живость",
+			method:       http.MethodGet,
+			path:         "/healthz",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "готовность",
+			method: http.MethodGet,
+			path:   "/readyz",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Ping", mock.Anything).Return(nil)
+				m.On("CheckMigrations", mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "готовность без БД",
+			method: http.MethodGet,
+			path:   "/readyz",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Ping", mock.Anything).Return(errDB)
+				m.On("CheckMigrations", mock.Anything).Return(errDB)
+			},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+			allowFraudChecks(providerMock)
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.auth {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			}
+			if tc.adminAuth {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+			}
+
+			route, pathParams, err := specRouter.FindRoute(req)
+			require.NoError(t, err)
+
+			requestInput := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+				},
+			}
+			if !tc.invalidRequest {
+				require.NoError(t, openapi3filter.ValidateRequest(context.Background(), requestInput))
+			}
+
+			// валидатор вычитывает тело, поэтому восстанавливаем его перед отправкой
+			req.Body = io.NopCloser(strings.NewReader(tc.body))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			responseInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: requestInput,
+				Status:                 resp.StatusCode,
+				Header:                 resp.Header,
+				Body:                   io.NopCloser(bytes.NewReader(body)),
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+				},
+			}
+			assert.NoError(t, openapi3filter.ValidateResponse(context.Background(), responseInput))
+		})
+	}
+}
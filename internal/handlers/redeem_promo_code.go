@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// промокоды не зависят от регистра и случайных пробелов при вводе
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (h *HandlerService) RedeemPromoCode(w http.ResponseWriter, r *http.Request) {
+
+	var request models.PromoRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	redemption, err := h.provider.RedeemPromoCode(r.Context(), userID, normalizePromoCode(request.Code))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	metrics.PointsPromoRedeemed.Add(redemption.Amount)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, redemption)
+}
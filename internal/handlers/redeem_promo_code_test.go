@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_RedeemPromoCode(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	redemption := models.PromoRedemption{ID: 1, Code: "WELCOME2024", Amount: 100, RedeemedAt: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)}
+
+	testCases := []struct {
+		name         string
+		body         string
+		code         string
+		storageErr   error
+		expectedCode int
+	}{
+		{name: "код без учёта регистра и пробелов", body: `{"code":" welcome2024 "}`, code: "WELCOME2024", expectedCode: http.StatusOK},
+		{name: "пустой код", body: `{"code":""}`, expectedCode: http.StatusBadRequest},
+		{name: "код не найден", body: `{"code":"NOPE"}`, code: "NOPE", storageErr: postgres.ErrPromoNotFound, expectedCode: http.StatusNotFound},
+		{name: "код истёк", body: `{"code":"SPRING"}`, code: "SPRING", storageErr: postgres.ErrPromoExpired, expectedCode: http.StatusUnprocessableEntity},
+		{name: "лимит активаций исчерпан", body: `{"code":"FIRST100"}`, code: "FIRST100", storageErr: postgres.ErrPromoExhausted, expectedCode: http.StatusConflict},
+		{name: "уже активирован пользователем", body: `{"code":"SORRY"}`, code: "SORRY", storageErr: postgres.ErrPromoRedeemed, expectedCode: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			if tc.code != "" {
+				providerMock.On("RedeemPromoCode", mock.Anything, "user", tc.code).Return(redemption, tc.storageErr)
+			}
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/promo", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response models.PromoRedemption
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, redemption, response)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+// возвращает пользователю баллы за списание, например при отмене заказа в магазине
+func (h *HandlerService) RefundWithdrawal(w http.ResponseWriter, r *http.Request) {
+
+	var refund models.RefundRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &refund); err != nil {
+		return
+	}
+
+	order := chi.URLParam(r, "order")
+	withdraw, err := h.provider.RefundWithdrawal(r.Context(), order, refund.Sum, refund.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("возврат баллов за списание",
+		zap.String("order", order),
+		zap.Float64("refunded", withdraw.Refunded),
+		zap.String("reason", refund.Reason),
+	)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, withdraw)
+}
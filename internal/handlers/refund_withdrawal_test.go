@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func TestHandlerService_RefundWithdrawal(t *testing.T) {
+	partialSum := 100.0
+
+	testCases := []struct {
+		name         string
+		adminToken   string
+		body         string
+		expectedSum  *float64
+		expectedCode int
+		expectedBody models.Withdrawn
+	}{
+		{
+			name:         "частичный возврат",
+			adminToken:   "admin",
+			body:         `{"sum":100,"reason":"order cancelled"}`,
+			expectedSum:  &partialSum,
+			expectedCode: http.StatusOK,
+			expectedBody: models.Withdrawn{Order: "2377225624", Sum: 500, Status: models.WithdrawalPartiallyRefunded, Refunded: 100},
+		},
+		{
+			name:         "полный возврат",
+			adminToken:   "admin",
+			body:         `{"reason":"order cancelled"}`,
+			expectedSum:  nil,
+			expectedCode: http.StatusOK,
+			expectedBody: models.Withdrawn{Order: "2377225624", Sum: 500, Status: models.WithdrawalRefunded, Refunded: 500},
+		},
+		{
+			name:         "отрицательная сумма",
+			adminToken:   "admin",
+			body:         `{"sum":-1,"reason":"order cancelled"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "сумма с долями копеек",
+			adminToken:   "admin",
+			body:         `{"sum":0.001,"reason":"order cancelled"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "административное API отключено",
+			adminToken:   "",
+			body:         `{"reason":"order cancelled"}`,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := GetMockConfig()
+			cfg.AdminToken = tc.adminToken
+
+			providerMock := new(mocks.StorageProvider)
+			providerMock.On("RefundWithdrawal", mock.Anything, "2377225624", tc.expectedSum, "order cancelled").Return(tc.expectedBody, nil)
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/admin/withdrawals/2377225624/refund", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", "admin"))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedCode == http.StatusOK {
+				var response models.Withdrawn
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+				response.ProccesedAt = time.Time{}
+				assert.Equal(t, tc.expectedBody, response)
+			}
+		})
+	}
+}
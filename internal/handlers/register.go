@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
@@ -13,13 +16,14 @@ import (
 	"github.com/zYoma/gophermart/internal/auth/jwt"
 	"github.com/zYoma/gophermart/internal/logger"
 	"github.com/zYoma/gophermart/internal/models"
-	"github.com/zYoma/gophermart/internal/storage/postgres"
 	"go.uber.org/zap"
 )
 
+var validate = newValidator()
+
 func (h *HandlerService) Registration(w http.ResponseWriter, r *http.Request) {
 
-	var credentials models.Credantials
+	var credentials models.RegistrationRequest
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := decodeAndValidateBody(w, r, &credentials); err != nil {
@@ -28,32 +32,22 @@ func (h *HandlerService) Registration(w http.ResponseWriter, r *http.Request) {
 
 	passHash, err := hash.HashPassword(credentials.Password)
 	if err != nil {
-		logger.Log.Error("не удалось получить хеш пароля", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		render.JSON(w, r, models.Error("error create user"))
+		writeError(w, r, fmt.Errorf("hash password: %w", err))
 		return
 	}
 
-	err = h.provider.CreateUser(r.Context(), credentials.Login, passHash)
+	err = h.provider.CreateUser(r.Context(), credentials.Login, passHash, normalizeReferralCode(credentials.ReferralCode))
 	if err != nil {
-		if errors.Is(err, postgres.ErrConflict) {
-			w.WriteHeader(http.StatusConflict)
-			render.JSON(w, r, models.Error("user already exist"))
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при записи в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error create user"))
+		writeError(w, r, err)
 		return
 	}
 
-	token, err := jwt.BuildJWTString(credentials.Login, h.cfg.TokenSecret)
+	token, err := jwt.BuildJWTString(credentials.Login, h.cfg.Secrets().TokenSecret)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка генерации токена", zap.Error(err))
-		render.JSON(w, r, models.Error("error create user"))
+		writeError(w, r, err)
 		return
 	}
+	h.recordUserIP(r, credentials.Login)
 
 	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	w.WriteHeader(http.StatusOK)
@@ -63,35 +57,64 @@ func (h *HandlerService) Registration(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// реферальные коды выдаются в верхнем регистре, а пользователи вводят их как придётся
+func normalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// decodeAndValidateBody декодирует JSON тело запроса в dst и валидирует его.
+// При ошибке ответ клиенту уже записан, вызывающему нужно только выйти из обработчика.
 func decodeAndValidateBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	err := render.DecodeJSON(r.Body, dst)
 	if errors.Is(err, io.EOF) {
-		logger.Log.Error("request body is empty")
-		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, models.Error("empty request"))
+		logger.FromContext(r.Context()).Error("request body is empty")
+		writeError(w, r, ErrEmptyBody)
 		return err
 	}
 	if err != nil {
-		logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, models.Error("failed to decode request"))
+		logger.FromContext(r.Context()).Error("cannot decode request JSON body", zap.Error(err))
+		writeError(w, r, bodyError(err, ErrMalformedBody))
 		return err
 	}
 
-	validate := validator.New()
 	if err := validate.Struct(dst); err != nil {
 		validateErr, ok := err.(validator.ValidationErrors)
 		if !ok {
-			logger.Log.Error("unexpected error type", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
-			render.JSON(w, r, models.Error("internal server error"))
+			writeError(w, r, err)
 			return err
 		}
-		logger.Log.Error("request validate error", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, models.ValidationError(validateErr))
+		logger.FromContext(r.Context()).Error("request validate error", zap.Error(err))
+		writeValidationError(w, r, models.ValidationErrors(validateErr))
 		return err
 	}
 
 	return nil
 }
+
+// валидатор, который в ошибках использует имена полей из json тегов
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	// суммы в баллах хранятся с точностью до копеек
+	if err := v.RegisterValidation("decimals", validateDecimals); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// проверяет, что в дробной части числа не больше знаков, чем указано в параметре тега
+func validateDecimals(fl validator.FieldLevel) bool {
+	places, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	value := strconv.FormatFloat(fl.Field().Float(), 'f', -1, 64)
+	dot := strings.IndexByte(value, '.')
+	return dot < 0 || len(value)-dot-1 <= places
+}
@@ -20,6 +20,8 @@ func TestHandlerService_Registration(t *testing.T) {
 	cfg := GetMockConfig()
 
 	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
 	service := New(providerMock, cfg)
 	r := service.GetRouter()
 	srv := httptest.NewServer(r)
@@ -32,6 +34,7 @@ func TestHandlerService_Registration(t *testing.T) {
 		expectedCode  int
 		expectedBody  string
 		user          string
+		referral      string
 		expectedError error
 	}{
 		{
@@ -61,12 +64,22 @@ func TestHandlerService_Registration(t *testing.T) {
 			user:          "jack",
 			expectedError: postgres.ErrConflict,
 		},
+		{
+			name:          "неизвестный реферальный код",
+			method:        http.MethodPost,
+			body:          models.RegistrationRequest{Login: "kate", Password: "password", ReferralCode: " ab12cd34 "},
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedBody:  "",
+			user:          "kate",
+			referral:      "AB12CD34",
+			expectedError: postgres.ErrReferralNotFound,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Настройка поведения моков
-			providerMock.On("CreateUser", mock.Anything, tc.user, mock.Anything).Return(tc.expectedError)
+			providerMock.On("CreateUser", mock.Anything, tc.user, mock.Anything, tc.referral).Return(tc.expectedError)
 
 			// Подготовка тела запроса
 			var buf bytes.Buffer
@@ -98,9 +111,11 @@ func TestHandlerService_Registration(t *testing.T) {
 
 func GetMockConfig() *config.Config {
 	return &config.Config{
-		RunAddr:     ":8081",
-		AcrualURL:   "http://localhost:8080",
-		LogLevel:    "info",
-		TokenSecret: "test",
+		RunAddr:      ":8081",
+		AcrualURL:    "http://localhost:8080",
+		LogLevel:     "info",
+		TokenSecret:  "test",
+		AdminToken:   "admin",
+		MaxBodyBytes: 1 << 20,
 	}
 }
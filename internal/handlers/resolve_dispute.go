@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// передаёт заказ заявителю вместе с начислением, если заказ уже обработан
+func (h *HandlerService) TransferDisputedOrder(w http.ResponseWriter, r *http.Request) {
+
+	var resolution models.DisputeResolution
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &resolution); err != nil {
+		return
+	}
+
+	id, err := disputeID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	dispute, err := h.provider.TransferDisputedOrder(r.Context(), id, resolution.Comment)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("заказ передан по спору",
+		zap.Int64("dispute_id", dispute.ID),
+		zap.String("order", dispute.Order),
+		zap.String("from", dispute.Owner),
+		zap.String("to", dispute.Claimant),
+		zap.Float64("reclaimed", dispute.Reclaimed),
+		zap.Float64("credited", dispute.Credited),
+	)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, dispute)
+}
+
+// отклоняет спор, заказ остаётся у владельца
+func (h *HandlerService) RejectDispute(w http.ResponseWriter, r *http.Request) {
+
+	var resolution models.DisputeResolution
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &resolution); err != nil {
+		return
+	}
+
+	id, err := disputeID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	dispute, err := h.provider.RejectDispute(r.Context(), id, resolution.Comment)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, dispute)
+}
+
+// идентификатор спора из пути; нечисловой идентификатор не может принадлежать ни одному спору
+func disputeID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, postgres.ErrDisputeNotFound
+	}
+	return id, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_ResolveDispute(t *testing.T) {
+	cfg := GetMockConfig()
+	created := time.Date(2024, 3, 28, 9, 0, 0, 0, time.UTC)
+	resolved := created.Add(time.Hour)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		setup        func(m *mocks.StorageProvider)
+		expectedCode int
+	}{
+		{
+			name:   "открытые споры",
+			method: http.MethodGet,
+			path:   "/api/admin/disputes?status=OPEN",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetDisputes", mock.Anything, models.DisputeOpen).
+					Return([]models.Dispute{{ID: 3, Order: "2377225624", Claimant: "user", Owner: "other", Status: models.DisputeOpen, CreatedAt: created}}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "споров нет",
+			method: http.MethodGet,
+			path:   "/api/admin/disputes",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("GetDisputes", mock.Anything, models.DisputeStatus("")).Return(nil, postgres.ErrDisputesNotFound)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "неизвестный статус",
+			method:       http.MethodGet,
+			path:         "/api/admin/disputes?status=CLOSED",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:   "передача обработанного заказа",
+			method: http.MethodPost,
+			path:   "/api/admin/disputes/3/transfer",
+			body:   `{"comment":"receipt verified"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("TransferDisputedOrder", mock.Anything, int64(3), "receipt verified").Return(models.Dispute{
+					ID: 3, Order: "2377225624", Claimant: "user", Owner: "other", Status: models.DisputeTransferred,
+					Comment: "receipt verified", Reclaimed: 500, Credited: 550, CreatedAt: created, ResolvedAt: &resolved,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "передача без комментария",
+			method:       http.MethodPost,
+			path:         "/api/admin/disputes/3/transfer",
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "нечисловой идентификатор",
+			method:       http.MethodPost,
+			path:         "/api/admin/disputes/abc/transfer",
+			body:         `{"comment":"receipt verified"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "передача по закрытому спору",
+			method: http.MethodPost,
+			path:   "/api/admin/disputes/3/transfer",
+			body:   `{"comment":"receipt verified"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("TransferDisputedOrder", mock.Anything, int64(3), "receipt verified").Return(models.Dispute{}, postgres.ErrDisputeNotOpen)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:   "отклонение спора",
+			method: http.MethodPost,
+			path:   "/api/admin/disputes/3/reject",
+			body:   `{"comment":"receipt does not match"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RejectDispute", mock.Anything, int64(3), "receipt does not match").Return(models.Dispute{
+					ID: 3, Order: "2377225624", Claimant: "user", Owner: "other", Status: models.DisputeRejected,
+					Comment: "receipt does not match", CreatedAt: created, ResolvedAt: &resolved,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "отклонение несуществующего спора",
+			method: http.MethodPost,
+			path:   "/api/admin/disputes/9/reject",
+			body:   `{"comment":"receipt does not match"}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("RejectDispute", mock.Anything, int64(9), "receipt does not match").Return(models.Dispute{}, postgres.ErrDisputeNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := mocks.NewStorageProvider(t)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.AdminToken))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedCode == http.StatusOK && tc.method == http.MethodPost {
+				var dispute models.Dispute
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&dispute))
+				assert.Equal(t, int64(3), dispute.ID)
+				assert.NotNil(t, dispute.ResolvedAt)
+			}
+		})
+	}
+}
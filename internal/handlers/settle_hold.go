@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+// списывает зарезервированные баллы после успешной оплаты; списанное попадает в историю списаний
+func (h *HandlerService) CaptureHold(w http.ResponseWriter, r *http.Request) {
+
+	var request models.CaptureRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := holdID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	hold, err := h.provider.CaptureHold(r.Context(), userID, id, request.Sum)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	metrics.PointsWithdrawn.Add(hold.Captured)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, hold)
+}
+
+// отменяет резерв, например если оплата не прошла, и возвращает баллы на баланс
+func (h *HandlerService) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := holdID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	hold, err := h.provider.ReleaseHold(r.Context(), userID, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, hold)
+}
+
+// идентификатор резерва из пути; нечисловой идентификатор не может принадлежать ни одному резерву
+func holdID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, postgres.ErrHoldNotFound
+	}
+	return id, nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_SettleHold(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+	partial := 80.0
+	created := time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         string
+		setup        func(m *mocks.StorageProvider)
+		expectedCode int
+	}{
+		{
+			name: "полное списание",
+			path: "/api/user/balance/holds/7/capture",
+			body: `{}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), (*float64)(nil)).
+					Return(models.Hold{ID: 7, Order: "2377225624", Sum: 120, Captured: 120, Status: models.HoldCaptured, CreatedAt: created}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "частичное списание",
+			path: "/api/user/balance/holds/7/capture",
+			body: `{"sum":80}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), &partial).
+					Return(models.Hold{ID: 7, Order: "2377225624", Sum: 120, Captured: 80, Status: models.HoldCaptured, CreatedAt: created}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "списание больше резерва",
+			path: "/api/user/balance/holds/7/capture",
+			body: `{"sum":200}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), mock.Anything).
+					Return(models.Hold{}, fmt.Errorf("%w: 120.00 points held", postgres.ErrCaptureExceedsHold))
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "отрицательная сумма списания",
+			path:         "/api/user/balance/holds/7/capture",
+			body:         `{"sum":-1}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "списание закрытого резерва",
+			path: "/api/user/balance/holds/7/capture",
+			body: `{}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("CaptureHold", mock.Anything, "user", int64(7), (*float64)(nil)).Return(models.Hold{}, postgres.ErrHoldNotActive)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "нечисловой идентификатор",
+			path:         "/api/user/balance/holds/abc/capture",
+			body:         `{}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "отмена резерва",
+			path: "/api/user/balance/holds/7/release",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReleaseHold", mock.Anything, "user", int64(7)).
+					Return(models.Hold{ID: 7, Order: "2377225624", Sum: 120, Status: models.HoldReleased, CreatedAt: created}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "отмена чужого резерва",
+			path: "/api/user/balance/holds/8/release",
+			setup: func(m *mocks.StorageProvider) {
+				m.On("ReleaseHold", mock.Anything, "user", int64(8)).Return(models.Hold{}, postgres.ErrHoldNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// глобальный провайдер можно подменить только один раз, поэтому запись спанов общая для тестов пакета
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+// последний завершённый спан с именем name
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name() == name {
+			return spans[i]
+		}
+	}
+	return nil
+}
+
+func TestHandlerService_TracingContinuesIncomingTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("GetUserBalance", mock.Anything, "user").Return(models.Balance{}, nil)
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	parentTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/balance", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", parentTraceID))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	span := findSpan(recorder.Ended(), "GET /api/user/balance")
+	require.NotNil(t, span)
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, parentTraceID, span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+}
+
+func TestHandlerService_TracingLinksOrderProcessing(t *testing.T) {
+	recorder := recordSpans(t)
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	// система лояльности ещё не знает о заказе
+	traceparents := make(chan string, 1)
+	accrual := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer accrual.Close()
+	cfg.AcrualURL = accrual.URL
+
+	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
+	providerMock.On("CreateOrder", mock.Anything, "79927398713", "user").Return(nil)
+	providerMock.On("UpdateOrderAndAccrualPoints", mock.Anything, mock.Anything).Return(0.0, nil)
+
+	srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/orders", bytes.NewBufferString("79927398713"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	spansBefore := len(recorder.Ended())
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var processing sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		processing = findSpan(recorder.Ended()[spansBefore:], "OrderProccessed")
+		return processing != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	request := findSpan(recorder.Ended(), "POST /api/user/orders")
+	require.NotNil(t, request)
+
+	// фоновая обработка начинает свою трассу и ссылается на спан запроса
+	assert.False(t, processing.Parent().IsValid())
+	require.Len(t, processing.Links(), 1)
+	assert.Equal(t, request.SpanContext().SpanID(), processing.Links()[0].SpanContext.SpanID())
+
+	// запрос в систему лояльности продолжает трассу фоновой обработки
+	assert.Contains(t, <-traceparents, processing.SpanContext().TraceID().String())
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+func (h *HandlerService) TransferPoints(w http.ResponseWriter, r *http.Request) {
+
+	var request models.TransferRequest
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := decodeAndValidateBody(w, r, &request); err != nil {
+		return
+	}
+
+	userID, err := getUserFromRequest(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if request.Recipient == userID {
+		writeError(w, r, ErrTransferToSelf)
+		return
+	}
+
+	limits := models.TransferLimits{Sum: h.cfg.TransferDailyLimit, Count: h.cfg.TransferDailyCount}
+	transfer, err := h.provider.Transfer(r.Context(), userID, request.Recipient, request.Sum, request.Comment, limits)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	metrics.PointsTransferred.Add(request.Sum)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, transfer)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zYoma/gophermart/internal/auth/jwt"
+	"github.com/zYoma/gophermart/internal/mocks"
+	"github.com/zYoma/gophermart/internal/models"
+	"github.com/zYoma/gophermart/internal/storage/postgres"
+)
+
+func TestHandlerService_TransferPoints(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.TransferDailyLimit = 5000
+	cfg.TransferDailyCount = 10
+	limits := models.TransferLimits{Sum: 5000, Count: 10}
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	testCases := []struct {
+		name         string
+		body         models.TransferRequest
+		storageErr   error
+		callsStorage bool
+		expectedCode int
+	}{
+		{
+			name:         "успешный перевод",
+			body:         models.TransferRequest{Recipient: "jack", Sum: 150, Comment: "на подарок"},
+			callsStorage: true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "перевод самому себе",
+			body:         models.TransferRequest{Recipient: "user", Sum: 150},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "нулевая сумма",
+			body:         models.TransferRequest{Recipient: "jack"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "сумма с долями копеек",
+			body:         models.TransferRequest{Recipient: "jack", Sum: 10.001},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "получатель не найден",
+			body:         models.TransferRequest{Recipient: "nobody", Sum: 150},
+			storageErr:   postgres.ErrRecipientNotFound,
+			callsStorage: true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "недостаточно средств",
+			body:         models.TransferRequest{Recipient: "jack", Sum: 150},
+			storageErr:   postgres.ErrFewPoints,
+			callsStorage: true,
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:         "превышен суточный лимит",
+			body:         models.TransferRequest{Recipient: "jack", Sum: 150},
+			storageErr:   fmt.Errorf("%w: at most 10 transfers per day", postgres.ErrTransferLimit),
+			callsStorage: true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			transfer := models.Transfer{
+				ID:           1,
+				Direction:    models.TransferOutgoing,
+				Counterparty: tc.body.Recipient,
+				Sum:          tc.body.Sum,
+				Comment:      tc.body.Comment,
+				CreatedAt:    time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC),
+			}
+			if tc.callsStorage {
+				// лимиты из конфига передаются в хранилище, которое проверяет их под блокировкой баланса
+				providerMock.On("Transfer", mock.Anything, "user", tc.body.Recipient, tc.body.Sum, tc.body.Comment, limits).
+					Return(transfer, tc.storageErr)
+			}
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			var buf bytes.Buffer
+			require.NoError(t, json.NewEncoder(&buf).Encode(tc.body))
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/transfer", &buf)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response models.Transfer
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, transfer, response)
+		})
+	}
+}
+
+func TestHandlerService_GetTransfers(t *testing.T) {
+	cfg := GetMockConfig()
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	transfers := []models.Transfer{
+		{ID: 2, Direction: models.TransferIncoming, Counterparty: "jack", Sum: 50, CreatedAt: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)},
+		{ID: 1, Direction: models.TransferOutgoing, Counterparty: "jack", Sum: 150, Comment: "на подарок", CreatedAt: time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)},
+	}
+
+	testCases := []struct {
+		name         string
+		transfers    []models.Transfer
+		err          error
+		expectedCode int
+	}{
+		{name: "входящие и исходящие переводы", transfers: transfers, expectedCode: http.StatusOK},
+		{name: "нет переводов", err: postgres.ErrTransfersNotFound, expectedCode: http.StatusNoContent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			providerMock.On("GetUserTransfers", mock.Anything, "user").Return(tc.transfers, tc.err)
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/transfers", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response []models.Transfer
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, tc.transfers, response)
+		})
+	}
+}
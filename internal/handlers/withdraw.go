@@ -1,16 +1,12 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
 
-	"github.com/go-chi/render"
-
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
 	"github.com/zYoma/gophermart/internal/models"
-	"github.com/zYoma/gophermart/internal/storage/postgres"
 	"github.com/zYoma/gophermart/internal/utils"
-	"go.uber.org/zap"
 )
 
 func (h *HandlerService) WithdrowPoints(w http.ResponseWriter, r *http.Request) {
@@ -19,37 +15,33 @@ func (h *HandlerService) WithdrowPoints(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := decodeAndValidateBody(w, r, &orderSum); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	userID, err := getUserFromRequest(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		render.JSON(w, r, models.Error("Unauthorized"))
+		writeError(w, r, err)
 		return
 	}
 
 	if !utils.CheckLuhn(orderSum.Order) {
-		logger.Log.Error("номер заказа не валидный")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		render.JSON(w, r, models.Error("not valid order number"))
+		logger.FromContext(r.Context()).Error("номер заказа не валидный")
+		writeError(w, r, ErrInvalidOrderNumber)
 		return
 	}
 
-	err = h.provider.Withdrow(r.Context(), orderSum.Sum, userID, orderSum.Order)
+	err = h.provider.Withdrow(r.Context(), orderSum.Sum, userID, orderSum.Order, orderSum.Partial, h.withdrawalLimits())
 	if err != nil {
-		if errors.Is(err, postgres.ErrFewPoints) {
-			w.WriteHeader(http.StatusPaymentRequired)
-			render.JSON(w, r, models.Error("there are not enough points on balance"))
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Error("ошибка при запросе в БД", zap.Error(err))
-		render.JSON(w, r, models.Error("error create order"))
+		writeError(w, r, err)
 		return
 	}
+	metrics.PointsWithdrawn.Add(orderSum.Sum)
 
 	w.WriteHeader(http.StatusOK)
 
 }
+
+// ограничения на списания из конфига; проверяются хранилищем под блокировкой баланса
+func (h *HandlerService) withdrawalLimits() models.WithdrawalLimits {
+	return models.WithdrawalLimits{Max: h.cfg.WithdrawalMaxSum, Daily: h.cfg.WithdrawalDailyLimit}
+}
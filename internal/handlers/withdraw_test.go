@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,6 +22,8 @@ func TestHandlerService_Withdraw(t *testing.T) {
 	cfg := GetMockConfig()
 
 	providerMock := new(mocks.StorageProvider)
+
+	allowFraudChecks(providerMock)
 	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
 	service := New(providerMock, cfg)
 	r := service.GetRouter()
@@ -64,7 +67,7 @@ func TestHandlerService_Withdraw(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Настройка поведения моков
-			providerMock.On("Withdrow", mock.Anything, tc.sum, mock.Anything, mock.Anything).Return(tc.expectedError)
+			providerMock.On("Withdrow", mock.Anything, tc.sum, mock.Anything, mock.Anything, false, models.WithdrawalLimits{}).Return(tc.expectedError)
 
 			// Подготовка тела запроса
 			var buf bytes.Buffer
@@ -89,3 +92,113 @@ func TestHandlerService_Withdraw(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerService_WithdrawRules(t *testing.T) {
+	cfg := GetMockConfig()
+	cfg.WithdrawalMaxSum = 1000
+	cfg.WithdrawalDailyLimit = 3000
+	limits := models.WithdrawalLimits{Max: 1000, Daily: 3000}
+	token, _ := jwt.BuildJWTString("user", cfg.TokenSecret)
+
+	testCases := []struct {
+		name         string
+		body         string
+		setup        func(m *mocks.StorageProvider)
+		expectedCode int
+		problemCode  models.ProblemCode
+		fieldCode    string
+	}{
+		{
+			name: "частичная оплата заказа",
+			body: `{"order":"2377225624","sum":100,"partial":true}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", true, limits).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "нулевая сумма",
+			body:         `{"order":"2377225624","sum":0}`,
+			expectedCode: http.StatusBadRequest,
+			problemCode:  models.ProblemValidationFailed,
+			fieldCode:    "gt",
+		},
+		{
+			name:         "отрицательная сумма",
+			body:         `{"order":"2377225624","sum":-50}`,
+			expectedCode: http.StatusBadRequest,
+			problemCode:  models.ProblemValidationFailed,
+			fieldCode:    "gt",
+		},
+		{
+			name:         "сумма с долями копеек",
+			body:         `{"order":"2377225624","sum":10.001}`,
+			expectedCode: http.StatusBadRequest,
+			problemCode:  models.ProblemValidationFailed,
+			fieldCode:    "decimals",
+		},
+		{
+			name: "повторное списание по заказу",
+			body: `{"order":"2377225624","sum":100}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 100.0, "user", "2377225624", false, limits).Return(postgres.ErrOrderPaid)
+			},
+			expectedCode: http.StatusConflict,
+			problemCode:  models.ProblemOrderPaid,
+		},
+		{
+			name: "больше лимита одного списания",
+			body: `{"order":"2377225624","sum":1500}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 1500.0, "user", "2377225624", false, limits).
+					Return(fmt.Errorf("%w: at most 1000.00 points at once", postgres.ErrWithdrawalTooLarge))
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			problemCode:  models.ProblemWithdrawalTooLarge,
+		},
+		{
+			name: "превышен суточный лимит",
+			body: `{"order":"2377225624","sum":500}`,
+			setup: func(m *mocks.StorageProvider) {
+				m.On("Withdrow", mock.Anything, 500.0, "user", "2377225624", false, limits).
+					Return(fmt.Errorf("%w: 200.00 of 3000.00 points left for today", postgres.ErrWithdrawalDaily))
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			problemCode:  models.ProblemWithdrawalDaily,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerMock := new(mocks.StorageProvider)
+			allowFraudChecks(providerMock)
+			if tc.setup != nil {
+				tc.setup(providerMock)
+			}
+
+			srv := httptest.NewServer(New(providerMock, cfg).GetRouter())
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/user/balance/withdraw", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			providerMock.AssertExpectations(t)
+			if tc.problemCode == "" {
+				return
+			}
+			var problem models.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, tc.problemCode, problem.Code)
+			if tc.fieldCode != "" {
+				require.Len(t, problem.Errors, 1)
+				assert.Equal(t, tc.fieldCode, problem.Errors[0].Code)
+			}
+		})
+	}
+}
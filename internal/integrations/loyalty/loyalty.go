@@ -1,8 +1,10 @@
 package loyalty
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,6 +12,12 @@ import (
 	"time"
 
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -38,6 +46,9 @@ var (
 	pauseMutex    sync.Mutex
 	pauseCond     = sync.NewCond(&pauseMutex)
 	isPaused      bool // вообще мне кажется, нужно использовать распределенное хранилище типа редиса для этого, если у нас несколько подов с приложением, но допустим, что у нас один процесс
+
+	// клиент пробрасывает контекст трассировки в систему лояльности и пишет спан на каждую попытку
+	client = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
 )
 
 // isValid проверяет, является ли статус заказа допустимым.
@@ -50,32 +61,64 @@ func (s OrderStatus) isValid() bool {
 	}
 }
 
-func GetPointsByOrder(url string) (*OrderResponse, error) {
+func GetPointsByOrder(ctx context.Context, url string) (orderResp *OrderResponse, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "loyalty.GetPointsByOrder")
+	defer func() {
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		if orderResp != nil {
+			span.SetAttributes(attribute.String("loyalty.order.status", string(orderResp.Status)))
+		}
+		span.End()
+	}()
+
 	for {
 		pauseMutex.Lock()
 		for isPaused { // Ждём, пока флаг паузы активен
 			pauseCond.Wait()
 		}
 		pauseMutex.Unlock()
-		resp, err := http.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if err != nil {
-			logger.Log.Error("ошибка при выполнении запроса", zap.Error(err))
+			logger.FromContext(ctx).Error("ошибка при создании запроса", zap.Error(err))
+			metrics.AccrualErrors.WithLabelValues("request").Inc()
 			return nil, ErrRequest
 		}
+		resp, err := client.Do(req)
+		if err != nil {
+			logger.FromContext(ctx).Error("ошибка при выполнении запроса", zap.Error(err))
+			metrics.AccrualErrors.WithLabelValues("request").Inc()
+			return nil, ErrRequest
+		}
+		metrics.AccrualRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 		// повторяем запрос при статусе 429
 		if resp.StatusCode == http.StatusTooManyRequests {
+			metrics.AccrualRateLimited.Inc()
 			retryAfter := resp.Header.Get("Retry-After")
 			delaySeconds, err := strconv.Atoi(retryAfter)
 			if err != nil {
-				logger.Log.Sugar().Infof("ошибка при чтении заголовка Retry-After", err)
+				logger.FromContext(ctx).Sugar().Infof("ошибка при чтении заголовка Retry-After", err)
+				metrics.AccrualErrors.WithLabelValues("retry_after").Inc()
 				resp.Body.Close()
 				return nil, ErrStatusCode
 			}
-			logger.Log.Sugar().Infof("Получен статус 429, повтор запроса через %d секунд\n", delaySeconds)
+			logger.FromContext(ctx).Sugar().Infof("Получен статус 429, повтор запроса через %d секунд\n", delaySeconds)
+			span.AddEvent("rate limited", trace.WithAttributes(attribute.Int("retry_after", delaySeconds)))
 			resp.Body.Close()
-			activatePause()
-			time.Sleep(time.Duration(delaySeconds) * time.Second)
+			activatePause(delaySeconds)
+			timer := time.NewTimer(time.Duration(delaySeconds) * time.Second)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				// при остановке сервиса не досиживаем паузу
+				timer.Stop()
+			}
 			deactivatePause()
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%w: %w", ErrRequest, ctx.Err())
+			}
 			continue
 		}
 
@@ -84,43 +127,70 @@ func GetPointsByOrder(url string) (*OrderResponse, error) {
 		if resp.StatusCode != http.StatusOK {
 			// если заказ не найден
 			if resp.StatusCode == http.StatusNoContent {
-				logger.Log.Sugar().Infof("заказ не найден, url: %s", url)
+				logger.FromContext(ctx).Sugar().Infof("заказ не найден, url: %s", url)
 				return nil, ErrNotFound
 			}
-			logger.Log.Sugar().Infof("сервер вернул статус-код: %d, url: %s", resp.StatusCode, url)
+			logger.FromContext(ctx).Sugar().Infof("сервер вернул статус-код: %d, url: %s", resp.StatusCode, url)
+			metrics.AccrualErrors.WithLabelValues("status_code").Inc()
 			return nil, ErrStatusCode
 		}
 
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			logger.Log.Error("ошибка при чтении тела ответа", zap.Error(err))
+			logger.FromContext(ctx).Error("ошибка при чтении тела ответа", zap.Error(err))
+			metrics.AccrualErrors.WithLabelValues("read_body").Inc()
 			return nil, ErrReadBody
 		}
 
-		var orderResp OrderResponse
-		if err := json.Unmarshal(body, &orderResp); err != nil {
-			logger.Log.Error("ошибка при десериализации ответа", zap.Error(err))
+		// тело null оставляет значение пустым, и такой ответ отсекается проверкой статуса
+		var order OrderResponse
+		if err := json.Unmarshal(body, &order); err != nil {
+			logger.FromContext(ctx).Error("ошибка при десериализации ответа", zap.Error(err))
+			metrics.AccrualErrors.WithLabelValues("unmarshal").Inc()
 			return nil, ErrUnmarshal
 		}
 
-		if !orderResp.Status.isValid() {
-			logger.Log.Sugar().Infof("недопустимый статус заказа: %s, url: %s", orderResp.Status, url)
+		if !order.Status.isValid() {
+			logger.FromContext(ctx).Sugar().Infof("недопустимый статус заказа: %s, url: %s", order.Status, url)
+			metrics.AccrualErrors.WithLabelValues("status").Inc()
 			return nil, ErrStatus
 		}
 
-		return &orderResp, nil
+		return &order, nil
 	}
 }
 
-func activatePause() {
+func activatePause(delaySeconds int) {
 	pauseMutex.Lock()
 	isPaused = true
+	metrics.AccrualPauseSeconds.Set(float64(delaySeconds))
 	pauseMutex.Unlock()
 }
 
 func deactivatePause() {
 	pauseMutex.Lock()
 	isPaused = false
+	metrics.AccrualPauseSeconds.Set(0)
 	pauseCond.Broadcast() // Оповещаем все ожидающие горутины
 	pauseMutex.Unlock()
 }
+
+// Paused сообщает, приостановлены ли запросы к системе лояльности после ответа 429
+func Paused() bool {
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	return isPaused
+}
+
+// Ping проверяет, что система лояльности отвечает; любой HTTP-ответ считается доступностью
+func Ping(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,43 @@
+package loyalty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPointsByOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{name: "начисление", body: `{"order":"12345678903","status":"PROCESSED","accrual":500}`},
+		{name: "тело null", body: `null`, wantErr: ErrStatus},
+		{name: "неизвестный статус", body: `{"order":"12345678903","status":"DONE"}`, wantErr: ErrStatus},
+		{name: "битый JSON", body: `{`, wantErr: ErrUnmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			order, err := GetPointsByOrder(context.Background(), srv.URL)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, order)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, StatusProcessed, order.Status)
+		})
+	}
+}
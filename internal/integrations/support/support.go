@@ -0,0 +1,54 @@
+// Package support отправляет поддержке уведомления, которые требуют ручной проверки.
+package support
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/zYoma/gophermart/internal/models"
+)
+
+var (
+	ErrRequest    = errors.New("request to support webhook")
+	ErrStatusCode = errors.New("not success status")
+
+	client = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 10 * time.Second}
+)
+
+// DisputeNotification тело уведомления о новом споре по заказу
+type DisputeNotification struct {
+	Event   string         `json:"event"`
+	Dispute models.Dispute `json:"dispute"`
+}
+
+// NotifyDispute отправляет спор на вебхук поддержки; успехом считается любой ответ 2xx
+func NotifyDispute(ctx context.Context, webhookURL string, dispute models.Dispute) error {
+	body, err := json.Marshal(DisputeNotification{Event: "order_dispute_opened", Dispute: dispute})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %d", ErrStatusCode, resp.StatusCode)
+	}
+	return nil
+}
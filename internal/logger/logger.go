@@ -1,13 +1,30 @@
 package logger
 
 import (
+	"context"
+	"sync"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var Log *zap.Logger = zap.NewNop()
 
+// Level текущий уровень логирования, меняется на лету через административное API
+var Level = zap.NewAtomicLevel()
+
+// логер без фильтра по уровню, им пишут запросы пользователей с включённой отладкой
+var debugLog *zap.Logger = zap.NewNop()
+
+// Sampling ограничивает поток одинаковых сообщений: в секунду пишутся первые Initial записей,
+// затем каждая Thereafter-я. Initial = 0 отключает сэмплирование.
+type Sampling struct {
+	Initial    int
+	Thereafter int
+}
+
 // Initialize инициализирует синглтон логера с необходимым уровнем логирования.
-func Initialize(level string) error {
+func Initialize(level string, sampling Sampling) error {
 	// преобразуем текстовый уровень логирования в zap.AtomicLevel
 	lvl, err := zap.ParseAtomicLevel(level)
 	if err != nil {
@@ -15,14 +32,111 @@ func Initialize(level string) error {
 	}
 	// создаём новую конфигурацию логера
 	cfg := zap.NewProductionConfig()
-	// устанавливаем уровень
-	cfg.Level = lvl
+	// базовый логер пишет всё, уровень применяется обёрткой ниже
+	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	cfg.Sampling = nil
+	if sampling.Initial > 0 {
+		cfg.Sampling = &zap.SamplingConfig{Initial: sampling.Initial, Thereafter: sampling.Thereafter}
+	}
 	// создаём логер на основе конфигурации
 	zl, err := cfg.Build()
 	if err != nil {
 		return err
 	}
 	// устанавливаем синглтон
-	Log = zl
+	Level.SetLevel(lvl.Level())
+	setBase(zl)
 	return nil
 }
+
+// base пишет записи любого уровня; глобальный Log фильтрует их по Level
+func setBase(base *zap.Logger) {
+	Log = base.WithOptions(zap.IncreaseLevel(Level))
+	debugLog = base
+}
+
+// пользователи, для запросов которых пишутся отладочные логи независимо от уровня
+var debugUsers = struct {
+	sync.RWMutex
+	logins map[string]struct{}
+}{logins: map[string]struct{}{}}
+
+// SetDebugUsers заменяет список пользователей с отладочным логированием
+func SetDebugUsers(logins []string) {
+	set := make(map[string]struct{}, len(logins))
+	for _, login := range logins {
+		set[login] = struct{}{}
+	}
+	debugUsers.Lock()
+	debugUsers.logins = set
+	debugUsers.Unlock()
+}
+
+// DebugUsers возвращает пользователей с отладочным логированием
+func DebugUsers() []string {
+	debugUsers.RLock()
+	defer debugUsers.RUnlock()
+	logins := make([]string, 0, len(debugUsers.logins))
+	for login := range debugUsers.logins {
+		logins = append(logins, login)
+	}
+	return logins
+}
+
+// IsDebugUser сообщает, включено ли отладочное логирование для пользователя
+func IsDebugUser(login string) bool {
+	debugUsers.RLock()
+	defer debugUsers.RUnlock()
+	_, ok := debugUsers.logins[login]
+	return ok
+}
+
+type ctxKey struct{}
+
+// логер в контексте вместе с накопленными полями, чтобы его можно было пересобрать на другом уровне
+type ctxLogger struct {
+	logger *zap.Logger
+	fields []zap.Field
+	debug  bool
+}
+
+func loggerFromContext(ctx context.Context) ctxLogger {
+	if l, ok := ctx.Value(ctxKey{}).(ctxLogger); ok {
+		return l
+	}
+	return ctxLogger{logger: Log}
+}
+
+// With добавляет поля к логеру из контекста и возвращает новый контекст;
+// так поля запроса (request_id, пользователь, маршрут) доходят до хранилища и фоновых задач.
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	l := loggerFromContext(ctx)
+	l.fields = append(l.fields[:len(l.fields):len(l.fields)], fields...)
+	l.logger = l.logger.With(fields...)
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// WithDebug включает в контексте отладочное логирование независимо от текущего уровня
+func WithDebug(ctx context.Context) context.Context {
+	l := loggerFromContext(ctx)
+	if l.debug {
+		return ctx
+	}
+	l.debug = true
+	l.logger = debugLog.With(l.fields...)
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// Inherit переносит логер из from в ctx: фоновая работа продолжает логировать с полями запроса,
+// но живёт по своему контексту.
+func Inherit(ctx context.Context, from context.Context) context.Context {
+	if l, ok := from.Value(ctxKey{}).(ctxLogger); ok {
+		return context.WithValue(ctx, ctxKey{}, l)
+	}
+	return ctx
+}
+
+// FromContext возвращает логер из контекста или глобальный Log, если его там нет.
+func FromContext(ctx context.Context) *zap.Logger {
+	return loggerFromContext(ctx).logger
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func observe(t *testing.T, level zapcore.Level) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	originalLog, originalDebug, originalLevel := Log, debugLog, Level.Level()
+	Level.SetLevel(level)
+	setBase(zap.New(core))
+	t.Cleanup(func() {
+		Log, debugLog = originalLog, originalDebug
+		Level.SetLevel(originalLevel)
+		SetDebugUsers(nil)
+	})
+	return logs
+}
+
+func TestLevel_ChangesAtRuntime(t *testing.T) {
+	logs := observe(t, zapcore.InfoLevel)
+	ctx := With(context.Background(), zap.String("request_id", "req-1"))
+
+	FromContext(ctx).Debug("скрыто")
+	Level.SetLevel(zapcore.DebugLevel)
+	FromContext(ctx).Debug("видно")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "видно", entries[0].Message)
+		assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+	}
+}
+
+func TestWithDebug_KeepsFieldsAndIgnoresLevel(t *testing.T) {
+	logs := observe(t, zapcore.ErrorLevel)
+	ctx := With(context.Background(), zap.String("request_id", "req-1"))
+	ctx = With(ctx, zap.String("user", "alice"))
+
+	FromContext(WithDebug(ctx)).Debug("отладка пользователя")
+	FromContext(ctx).Debug("отладка без флага")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "отладка пользователя", entries[0].Message)
+		assert.Equal(t, map[string]interface{}{"request_id": "req-1", "user": "alice"}, entries[0].ContextMap())
+	}
+}
+
+func TestWith_DoesNotShareFieldsBetweenBranches(t *testing.T) {
+	logs := observe(t, zapcore.InfoLevel)
+	parent := With(context.Background(), zap.String("task", "poll"))
+	first := With(parent, zap.String("order", "1"))
+	second := With(parent, zap.String("order", "2"))
+
+	FromContext(WithDebug(first)).Info("first")
+	FromContext(WithDebug(second)).Info("second")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "1", entries[0].ContextMap()["order"])
+		assert.Equal(t, "2", entries[1].ContextMap()["order"])
+	}
+}
+
+func TestInherit_CarriesLoggerToAnotherContext(t *testing.T) {
+	logs := observe(t, zapcore.InfoLevel)
+	request := With(context.Background(), zap.String("request_id", "req-1"))
+
+	background := Inherit(context.Background(), request)
+	FromContext(background).Info("фон")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+	}
+}
+
+func TestDebugUsers(t *testing.T) {
+	observe(t, zapcore.InfoLevel)
+
+	SetDebugUsers([]string{"alice"})
+	assert.True(t, IsDebugUser("alice"))
+	assert.False(t, IsDebugUser("bob"))
+
+	SetDebugUsers(nil)
+	assert.False(t, IsDebugUser("alice"))
+	assert.Empty(t, DebugUsers())
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.uber.org/zap"
+
+	"github.com/zYoma/gophermart/internal/logger"
+)
+
+const namespace = "gophermart"
+
+// Registry реестр метрик приложения, отдаётся на /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	AccrualRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_requests_total",
+		Help:      "Requests to the accrual system by response status code.",
+	}, []string{"code"})
+
+	AccrualRateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_rate_limited_total",
+		Help:      "Responses with status 429 from the accrual system.",
+	})
+
+	AccrualErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_errors_total",
+		Help:      "Failed requests to the accrual system by reason.",
+	}, []string{"reason"})
+
+	AccrualPauseSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_pause_seconds",
+		Help:      "Current pause of accrual polling requested via Retry-After, 0 when not paused.",
+	})
+
+	AccrualWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_workers_busy",
+		Help:      "Goroutines currently processing orders against the accrual system.",
+	})
+
+	PointsAccrued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_accrued_total",
+		Help:      "Loyalty points credited to users for processed orders, including tier multipliers.",
+	})
+
+	PointsWithdrawn = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_withdrawn_total",
+		Help:      "Loyalty points withdrawn by users.",
+	})
+
+	PointsExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_expired_total",
+		Help:      "Loyalty points burned after their expiry date.",
+	})
+
+	PointsTransferred = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_transferred_total",
+		Help:      "Loyalty points transferred between users.",
+	})
+
+	PointsPromoRedeemed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_promo_redeemed_total",
+		Help:      "Loyalty points credited by redeemed promo codes.",
+	})
+
+	PointLotShortfalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "point_lot_shortfalls_total",
+		Help:      "Operations rolled back because point lots did not cover the sum taken from the balance, by operation.",
+	}, []string{"operation"})
+
+	FraudDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fraud_decisions_total",
+		Help:      "Operations checked by fraud rules by operation and decision.",
+	}, []string{"operation", "decision"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		AccrualRequests,
+		AccrualRateLimited,
+		AccrualErrors,
+		AccrualPauseSeconds,
+		AccrualWorkersBusy,
+		PointsAccrued,
+		PointsWithdrawn,
+		PointsExpired,
+		PointsTransferred,
+		PointsPromoRedeemed,
+		PointLotShortfalls,
+		FraudDecisions,
+	)
+}
+
+// OrderCounter возвращает количество заказов в каждом из не конечных статусов
+type OrderCounter func(ctx context.Context) (map[string]int64, error)
+
+// pendingOrdersCollector при каждом сборе метрик считает заказы, ожидающие начисления
+type pendingOrdersCollector struct {
+	count OrderCounter
+	desc  *prometheus.Desc
+}
+
+// RegisterPendingOrders регистрирует метрику с количеством необработанных заказов по статусам
+func RegisterPendingOrders(count OrderCounter) {
+	Registry.MustRegister(&pendingOrdersCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pending_orders"),
+			"Orders waiting for accrual by status.",
+			[]string{"status"}, nil,
+		),
+	})
+}
+
+func (c *pendingOrdersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *pendingOrdersCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		logger.Log.Error("не удалось посчитать необработанные заказы", zap.Error(err))
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
+
+// pgxPoolCollector отдаёт статистику пула соединений с БД
+type pgxPoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquireCount      *prometheus.Desc
+	emptyAcquireCount *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+}
+
+// RegisterPgxPool регистрирует метрики пула соединений pgx
+func RegisterPgxPool(stat func() *pgxpool.Stat) {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	Registry.MustRegister(&pgxPoolCollector{
+		stat:              stat,
+		acquiredConns:     desc("acquired_conns", "Connections currently acquired from the pool."),
+		idleConns:         desc("idle_conns", "Idle connections in the pool."),
+		totalConns:        desc("total_conns", "Total connections in the pool."),
+		maxConns:          desc("max_conns", "Maximum size of the pool."),
+		acquireCount:      desc("acquire_total", "Successful acquires from the pool."),
+		emptyAcquireCount: desc("empty_acquire_total", "Acquires that had to wait for a connection."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Total time spent waiting for connections."),
+	})
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.emptyAcquireCount
+	ch <- c.acquireDuration
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
@@ -0,0 +1,57 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	models "github.com/zYoma/gophermart/internal/models"
+)
+
+// Source is an autogenerated mock type for the Source type
+type Source struct {
+	mock.Mock
+}
+
+// GetFraudSignals provides a mock function with given fields: ctx, userLogin, ip
+func (_m *Source) GetFraudSignals(ctx context.Context, userLogin string, ip string) (models.FraudSignals, error) {
+	ret := _m.Called(ctx, userLogin, ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetFraudSignals")
+	}
+
+	var r0 models.FraudSignals
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (models.FraudSignals, error)); ok {
+		return rf(ctx, userLogin, ip)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) models.FraudSignals); ok {
+		r0 = rf(ctx, userLogin, ip)
+	} else {
+		r0 = ret.Get(0).(models.FraudSignals)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userLogin, ip)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewSource creates a new instance of Source. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSource(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Source {
+	mock := &Source{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.39.1. DO NOT EDIT.
+// Code generated by mockery v2.53.3. DO NOT EDIT.
 
 package mocks
 
@@ -7,8 +7,9 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 	loyalty "github.com/zYoma/gophermart/internal/integrations/loyalty"
-
 	models "github.com/zYoma/gophermart/internal/models"
+
+	time "time"
 )
 
 // StorageProvider is an autogenerated mock type for the StorageProvider type
@@ -16,35 +17,75 @@ type StorageProvider struct {
 	mock.Mock
 }
 
-// CreateOrder provides a mock function with given fields: ctx, number, login
-func (_m *StorageProvider) CreateOrder(ctx context.Context, number string, login string) error {
-	ret := _m.Called(ctx, number, login)
+// AdjustOrderAccrual provides a mock function with given fields: ctx, order, newAccrual, policy
+func (_m *StorageProvider) AdjustOrderAccrual(ctx context.Context, order string, newAccrual float64, policy models.NegativeBalancePolicy) (*models.AccrualAdjustment, error) {
+	ret := _m.Called(ctx, order, newAccrual, policy)
 
 	if len(ret) == 0 {
-		panic("no return value specified for CreateOrder")
+		panic("no return value specified for AdjustOrderAccrual")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, number, login)
+	var r0 *models.AccrualAdjustment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, float64, models.NegativeBalancePolicy) (*models.AccrualAdjustment, error)); ok {
+		return rf(ctx, order, newAccrual, policy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, float64, models.NegativeBalancePolicy) *models.AccrualAdjustment); ok {
+		r0 = rf(ctx, order, newAccrual, policy)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.AccrualAdjustment)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string, float64, models.NegativeBalancePolicy) error); ok {
+		r1 = rf(ctx, order, newAccrual, policy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// CreateUser provides a mock function with given fields: ctx, login, password
-func (_m *StorageProvider) CreateUser(ctx context.Context, login string, password string) error {
-	ret := _m.Called(ctx, login, password)
+// AnonymizeDueAccounts provides a mock function with given fields: ctx
+func (_m *StorageProvider) AnonymizeDueAccounts(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for CreateUser")
+		panic("no return value specified for AnonymizeDueAccounts")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelAccountDeletion provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) CancelAccountDeletion(ctx context.Context, userLogin string) error {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelAccountDeletion")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, login, password)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userLogin)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -52,27 +93,27 @@ func (_m *StorageProvider) CreateUser(ctx context.Context, login string, passwor
 	return r0
 }
 
-// GetPasswordHash provides a mock function with given fields: ctx, login
-func (_m *StorageProvider) GetPasswordHash(ctx context.Context, login string) (string, error) {
-	ret := _m.Called(ctx, login)
+// CaptureHold provides a mock function with given fields: ctx, userLogin, id, sum
+func (_m *StorageProvider) CaptureHold(ctx context.Context, userLogin string, id int64, sum *float64) (models.Hold, error) {
+	ret := _m.Called(ctx, userLogin, id, sum)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetPasswordHash")
+		panic("no return value specified for CaptureHold")
 	}
 
-	var r0 string
+	var r0 models.Hold
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, login)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, *float64) (models.Hold, error)); ok {
+		return rf(ctx, userLogin, id, sum)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, login)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, *float64) models.Hold); ok {
+		r0 = rf(ctx, userLogin, id, sum)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.Hold)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, login)
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64, *float64) error); ok {
+		r1 = rf(ctx, userLogin, id, sum)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -80,24 +121,96 @@ func (_m *StorageProvider) GetPasswordHash(ctx context.Context, login string) (s
 	return r0, r1
 }
 
-// GetRegisteresOrders provides a mock function with given fields: ctx
-func (_m *StorageProvider) GetRegisteresOrders(ctx context.Context) ([]string, error) {
+// CheckMigrations provides a mock function with given fields: ctx
+func (_m *StorageProvider) CheckMigrations(ctx context.Context) error {
 	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetRegisteresOrders")
+		panic("no return value specified for CheckMigrations")
 	}
 
-	var r0 []string
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClearFraudFlag provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) ClearFraudFlag(ctx context.Context, userLogin string) error {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClearFraudFlag")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Close provides a mock function with no fields
+func (_m *StorageProvider) Close() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CompleteIdempotencyKey provides a mock function with given fields: ctx, userLogin, key, record
+func (_m *StorageProvider) CompleteIdempotencyKey(ctx context.Context, userLogin string, key string, record models.IdempotencyRecord) error {
+	ret := _m.Called(ctx, userLogin, key, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteIdempotencyKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, models.IdempotencyRecord) error); ok {
+		r0 = rf(ctx, userLogin, key, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountPendingOrders provides a mock function with given fields: ctx
+func (_m *StorageProvider) CountPendingOrders(ctx context.Context) (map[string]int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPendingOrders")
+	}
+
+	var r0 map[string]int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]string, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]int64, error)); ok {
 		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]int64); ok {
 		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).(map[string]int64)
 		}
 	}
 
@@ -110,27 +223,27 @@ func (_m *StorageProvider) GetRegisteresOrders(ctx context.Context) ([]string, e
 	return r0, r1
 }
 
-// GetUserBalance provides a mock function with given fields: ctx, userLogin
-func (_m *StorageProvider) GetUserBalance(ctx context.Context, userLogin string) (models.Balance, error) {
-	ret := _m.Called(ctx, userLogin)
+// CreateCampaign provides a mock function with given fields: ctx, campaign
+func (_m *StorageProvider) CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	ret := _m.Called(ctx, campaign)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetUserBalance")
+		panic("no return value specified for CreateCampaign")
 	}
 
-	var r0 models.Balance
+	var r0 models.Campaign
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Balance, error)); ok {
-		return rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Campaign) (models.Campaign, error)); ok {
+		return rf(ctx, campaign)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) models.Balance); ok {
-		r0 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Campaign) models.Campaign); ok {
+		r0 = rf(ctx, campaign)
 	} else {
-		r0 = ret.Get(0).(models.Balance)
+		r0 = ret.Get(0).(models.Campaign)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(1).(func(context.Context, models.Campaign) error); ok {
+		r1 = rf(ctx, campaign)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -138,29 +251,27 @@ func (_m *StorageProvider) GetUserBalance(ctx context.Context, userLogin string)
 	return r0, r1
 }
 
-// GetUserOrders provides a mock function with given fields: ctx, userLogin
-func (_m *StorageProvider) GetUserOrders(ctx context.Context, userLogin string) ([]models.Order, error) {
-	ret := _m.Called(ctx, userLogin)
+// CreateDispute provides a mock function with given fields: ctx, order, claimant, evidence
+func (_m *StorageProvider) CreateDispute(ctx context.Context, order string, claimant string, evidence string) (models.Dispute, error) {
+	ret := _m.Called(ctx, order, claimant, evidence)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetUserOrders")
+		panic("no return value specified for CreateDispute")
 	}
 
-	var r0 []models.Order
+	var r0 models.Dispute
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Order, error)); ok {
-		return rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (models.Dispute, error)); ok {
+		return rf(ctx, order, claimant, evidence)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Order); ok {
-		r0 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) models.Dispute); ok {
+		r0 = rf(ctx, order, claimant, evidence)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Order)
-		}
+		r0 = ret.Get(0).(models.Dispute)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, order, claimant, evidence)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -168,29 +279,27 @@ func (_m *StorageProvider) GetUserOrders(ctx context.Context, userLogin string)
 	return r0, r1
 }
 
-// GetUserWithdrawals provides a mock function with given fields: ctx, userLogin
-func (_m *StorageProvider) GetUserWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawn, error) {
-	ret := _m.Called(ctx, userLogin)
+// CreateHold provides a mock function with given fields: ctx, userLogin, order, sum, ttl, limits
+func (_m *StorageProvider) CreateHold(ctx context.Context, userLogin string, order string, sum float64, ttl time.Duration, limits models.WithdrawalLimits) (models.Hold, error) {
+	ret := _m.Called(ctx, userLogin, order, sum, ttl, limits)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetUserWithdrawals")
+		panic("no return value specified for CreateHold")
 	}
 
-	var r0 []models.Withdrawn
+	var r0 models.Hold
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Withdrawn, error)); ok {
-		return rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, float64, time.Duration, models.WithdrawalLimits) (models.Hold, error)); ok {
+		return rf(ctx, userLogin, order, sum, ttl, limits)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Withdrawn); ok {
-		r0 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, float64, time.Duration, models.WithdrawalLimits) models.Hold); ok {
+		r0 = rf(ctx, userLogin, order, sum, ttl, limits)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Withdrawn)
-		}
+		r0 = ret.Get(0).(models.Hold)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userLogin)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, float64, time.Duration, models.WithdrawalLimits) error); ok {
+		r1 = rf(ctx, userLogin, order, sum, ttl, limits)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -198,17 +307,17 @@ func (_m *StorageProvider) GetUserWithdrawals(ctx context.Context, userLogin str
 	return r0, r1
 }
 
-// Init provides a mock function with given fields:
-func (_m *StorageProvider) Init() error {
-	ret := _m.Called()
+// CreateOrder provides a mock function with given fields: ctx, number, login
+func (_m *StorageProvider) CreateOrder(ctx context.Context, number string, login string) error {
+	ret := _m.Called(ctx, number, login)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Init")
+		panic("no return value specified for CreateOrder")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func() error); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, number, login)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -216,17 +325,45 @@ func (_m *StorageProvider) Init() error {
 	return r0
 }
 
-// UpdateOrderAndAccrualPoints provides a mock function with given fields: ctx, orderData
-func (_m *StorageProvider) UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) error {
-	ret := _m.Called(ctx, orderData)
+// CreatePromoCode provides a mock function with given fields: ctx, promo
+func (_m *StorageProvider) CreatePromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	ret := _m.Called(ctx, promo)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateOrderAndAccrualPoints")
+		panic("no return value specified for CreatePromoCode")
+	}
+
+	var r0 models.PromoCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.PromoCode) (models.PromoCode, error)); ok {
+		return rf(ctx, promo)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.PromoCode) models.PromoCode); ok {
+		r0 = rf(ctx, promo)
+	} else {
+		r0 = ret.Get(0).(models.PromoCode)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.PromoCode) error); ok {
+		r1 = rf(ctx, promo)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateUser provides a mock function with given fields: ctx, login, password, referralCode
+func (_m *StorageProvider) CreateUser(ctx context.Context, login string, password string, referralCode string) error {
+	ret := _m.Called(ctx, login, password, referralCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *loyalty.OrderResponse) error); ok {
-		r0 = rf(ctx, orderData)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, login, password, referralCode)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -234,17 +371,885 @@ func (_m *StorageProvider) UpdateOrderAndAccrualPoints(ctx context.Context, orde
 	return r0
 }
 
-// Withdrow provides a mock function with given fields: ctx, sum, userLogin, order
-func (_m *StorageProvider) Withdrow(ctx context.Context, sum float64, userLogin string, order string) error {
-	ret := _m.Called(ctx, sum, userLogin, order)
+// DeleteExpiredIdempotencyKeys provides a mock function with given fields: ctx
+func (_m *StorageProvider) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredIdempotencyKeys")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExpirePoints provides a mock function with given fields: ctx
+func (_m *StorageProvider) ExpirePoints(ctx context.Context) (models.ExpirationReport, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpirePoints")
+	}
+
+	var r0 models.ExpirationReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (models.ExpirationReport, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) models.ExpirationReport); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(models.ExpirationReport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccrualAdjustments provides a mock function with given fields: ctx, since
+func (_m *StorageProvider) GetAccrualAdjustments(ctx context.Context, since time.Time) ([]models.AccrualAdjustment, error) {
+	ret := _m.Called(ctx, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAccrualAdjustments")
+	}
+
+	var r0 []models.AccrualAdjustment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]models.AccrualAdjustment, error)); ok {
+		return rf(ctx, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []models.AccrualAdjustment); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.AccrualAdjustment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalanceHistory provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetBalanceHistory(ctx context.Context, userLogin string) ([]models.BalanceEntry, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalanceHistory")
+	}
+
+	var r0 []models.BalanceEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.BalanceEntry, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.BalanceEntry); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.BalanceEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDisputes provides a mock function with given fields: ctx, status
+func (_m *StorageProvider) GetDisputes(ctx context.Context, status models.DisputeStatus) ([]models.Dispute, error) {
+	ret := _m.Called(ctx, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDisputes")
+	}
+
+	var r0 []models.Dispute
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.DisputeStatus) ([]models.Dispute, error)); ok {
+		return rf(ctx, status)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.DisputeStatus) []models.Dispute); ok {
+		r0 = rf(ctx, status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Dispute)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.DisputeStatus) error); ok {
+		r1 = rf(ctx, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExpiringPoints provides a mock function with given fields: ctx, userLogin, before
+func (_m *StorageProvider) GetExpiringPoints(ctx context.Context, userLogin string, before time.Time) ([]models.ExpiringPoints, error) {
+	ret := _m.Called(ctx, userLogin, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiringPoints")
+	}
+
+	var r0 []models.ExpiringPoints
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) ([]models.ExpiringPoints, error)); ok {
+		return rf(ctx, userLogin, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) []models.ExpiringPoints); ok {
+		r0 = rf(ctx, userLogin, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ExpiringPoints)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, userLogin, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFlaggedUsers provides a mock function with given fields: ctx
+func (_m *StorageProvider) GetFlaggedUsers(ctx context.Context) ([]models.FlaggedUser, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetFlaggedUsers")
+	}
+
+	var r0 []models.FlaggedUser
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.FlaggedUser, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.FlaggedUser); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.FlaggedUser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFraudSignals provides a mock function with given fields: ctx, userLogin, ip
+func (_m *StorageProvider) GetFraudSignals(ctx context.Context, userLogin string, ip string) (models.FraudSignals, error) {
+	ret := _m.Called(ctx, userLogin, ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetFraudSignals")
+	}
+
+	var r0 models.FraudSignals
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (models.FraudSignals, error)); ok {
+		return rf(ctx, userLogin, ip)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) models.FraudSignals); ok {
+		r0 = rf(ctx, userLogin, ip)
+	} else {
+		r0 = ret.Get(0).(models.FraudSignals)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userLogin, ip)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPasswordHash provides a mock function with given fields: ctx, login
+func (_m *StorageProvider) GetPasswordHash(ctx context.Context, login string) (string, error) {
+	ret := _m.Called(ctx, login)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPasswordHash")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, login)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, login)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, login)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProcessedOrdersSince provides a mock function with given fields: ctx, since
+func (_m *StorageProvider) GetProcessedOrdersSince(ctx context.Context, since time.Time) ([]models.ProcessedOrder, error) {
+	ret := _m.Called(ctx, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetProcessedOrdersSince")
+	}
+
+	var r0 []models.ProcessedOrder
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]models.ProcessedOrder, error)); ok {
+		return rf(ctx, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []models.ProcessedOrder); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ProcessedOrder)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRegisteresOrders provides a mock function with given fields: ctx
+func (_m *StorageProvider) GetRegisteresOrders(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRegisteresOrders")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserBalance provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetUserBalance(ctx context.Context, userLogin string) (models.Balance, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserBalance")
+	}
+
+	var r0 models.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Balance, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Balance); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		r0 = ret.Get(0).(models.Balance)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserOrders provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetUserOrders(ctx context.Context, userLogin string) ([]models.Order, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserOrders")
+	}
+
+	var r0 []models.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Order, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Order); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserProfile provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetUserProfile(ctx context.Context, userLogin string) (models.Profile, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserProfile")
+	}
+
+	var r0 models.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Profile, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Profile); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		r0 = ret.Get(0).(models.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserTransfers provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetUserTransfers(ctx context.Context, userLogin string) ([]models.Transfer, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserTransfers")
+	}
+
+	var r0 []models.Transfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Transfer, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Transfer); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserWithdrawals provides a mock function with given fields: ctx, userLogin
+func (_m *StorageProvider) GetUserWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawn, error) {
+	ret := _m.Called(ctx, userLogin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserWithdrawals")
+	}
+
+	var r0 []models.Withdrawn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Withdrawn, error)); ok {
+		return rf(ctx, userLogin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Withdrawn); ok {
+		r0 = rf(ctx, userLogin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Withdrawn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userLogin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Init provides a mock function with no fields
+func (_m *StorageProvider) Init() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Init")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *StorageProvider) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecomputeTiers provides a mock function with given fields: ctx
+func (_m *StorageProvider) RecomputeTiers(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecomputeTiers")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordFraudDecision provides a mock function with given fields: ctx, verdict
+func (_m *StorageProvider) RecordFraudDecision(ctx context.Context, verdict models.FraudVerdict) error {
+	ret := _m.Called(ctx, verdict)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordFraudDecision")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.FraudVerdict) error); ok {
+		r0 = rf(ctx, verdict)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordUserIP provides a mock function with given fields: ctx, userLogin, ip
+func (_m *StorageProvider) RecordUserIP(ctx context.Context, userLogin string, ip string) error {
+	ret := _m.Called(ctx, userLogin, ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordUserIP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userLogin, ip)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RedeemPromoCode provides a mock function with given fields: ctx, userLogin, code
+func (_m *StorageProvider) RedeemPromoCode(ctx context.Context, userLogin string, code string) (models.PromoRedemption, error) {
+	ret := _m.Called(ctx, userLogin, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RedeemPromoCode")
+	}
+
+	var r0 models.PromoRedemption
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (models.PromoRedemption, error)); ok {
+		return rf(ctx, userLogin, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) models.PromoRedemption); ok {
+		r0 = rf(ctx, userLogin, code)
+	} else {
+		r0 = ret.Get(0).(models.PromoRedemption)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userLogin, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RefundWithdrawal provides a mock function with given fields: ctx, order, sum, reason
+func (_m *StorageProvider) RefundWithdrawal(ctx context.Context, order string, sum *float64, reason string) (models.Withdrawn, error) {
+	ret := _m.Called(ctx, order, sum, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefundWithdrawal")
+	}
+
+	var r0 models.Withdrawn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *float64, string) (models.Withdrawn, error)); ok {
+		return rf(ctx, order, sum, reason)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *float64, string) models.Withdrawn); ok {
+		r0 = rf(ctx, order, sum, reason)
+	} else {
+		r0 = ret.Get(0).(models.Withdrawn)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *float64, string) error); ok {
+		r1 = rf(ctx, order, sum, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RejectDispute provides a mock function with given fields: ctx, id, comment
+func (_m *StorageProvider) RejectDispute(ctx context.Context, id int64, comment string) (models.Dispute, error) {
+	ret := _m.Called(ctx, id, comment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RejectDispute")
+	}
+
+	var r0 models.Dispute
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (models.Dispute, error)); ok {
+		return rf(ctx, id, comment)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) models.Dispute); ok {
+		r0 = rf(ctx, id, comment)
+	} else {
+		r0 = ret.Get(0).(models.Dispute)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, id, comment)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseExpiredHolds provides a mock function with given fields: ctx
+func (_m *StorageProvider) ReleaseExpiredHolds(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseExpiredHolds")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseHold provides a mock function with given fields: ctx, userLogin, id
+func (_m *StorageProvider) ReleaseHold(ctx context.Context, userLogin string, id int64) (models.Hold, error) {
+	ret := _m.Called(ctx, userLogin, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseHold")
+	}
+
+	var r0 models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) (models.Hold, error)); ok {
+		return rf(ctx, userLogin, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) models.Hold); ok {
+		r0 = rf(ctx, userLogin, id)
+	} else {
+		r0 = ret.Get(0).(models.Hold)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = rf(ctx, userLogin, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseIdempotencyKey provides a mock function with given fields: ctx, userLogin, key
+func (_m *StorageProvider) ReleaseIdempotencyKey(ctx context.Context, userLogin string, key string) error {
+	ret := _m.Called(ctx, userLogin, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseIdempotencyKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userLogin, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RequestAccountDeletion provides a mock function with given fields: ctx, userLogin, delay
+func (_m *StorageProvider) RequestAccountDeletion(ctx context.Context, userLogin string, delay time.Duration) (models.AccountDeletion, error) {
+	ret := _m.Called(ctx, userLogin, delay)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequestAccountDeletion")
+	}
+
+	var r0 models.AccountDeletion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (models.AccountDeletion, error)); ok {
+		return rf(ctx, userLogin, delay)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) models.AccountDeletion); ok {
+		r0 = rf(ctx, userLogin, delay)
+	} else {
+		r0 = ret.Get(0).(models.AccountDeletion)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, userLogin, delay)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReserveIdempotencyKey provides a mock function with given fields: ctx, userLogin, key, fingerprint, ttl
+func (_m *StorageProvider) ReserveIdempotencyKey(ctx context.Context, userLogin string, key string, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	ret := _m.Called(ctx, userLogin, key, fingerprint, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveIdempotencyKey")
+	}
+
+	var r0 *models.IdempotencyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, time.Duration) (*models.IdempotencyRecord, error)); ok {
+		return rf(ctx, userLogin, key, fingerprint, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, time.Duration) *models.IdempotencyRecord); ok {
+		r0 = rf(ctx, userLogin, key, fingerprint, ttl)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.IdempotencyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, time.Duration) error); ok {
+		r1 = rf(ctx, userLogin, key, fingerprint, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transfer provides a mock function with given fields: ctx, sender, recipient, sum, comment, limits
+func (_m *StorageProvider) Transfer(ctx context.Context, sender string, recipient string, sum float64, comment string, limits models.TransferLimits) (models.Transfer, error) {
+	ret := _m.Called(ctx, sender, recipient, sum, comment, limits)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transfer")
+	}
+
+	var r0 models.Transfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, float64, string, models.TransferLimits) (models.Transfer, error)); ok {
+		return rf(ctx, sender, recipient, sum, comment, limits)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, float64, string, models.TransferLimits) models.Transfer); ok {
+		r0 = rf(ctx, sender, recipient, sum, comment, limits)
+	} else {
+		r0 = ret.Get(0).(models.Transfer)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, float64, string, models.TransferLimits) error); ok {
+		r1 = rf(ctx, sender, recipient, sum, comment, limits)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TransferDisputedOrder provides a mock function with given fields: ctx, id, comment
+func (_m *StorageProvider) TransferDisputedOrder(ctx context.Context, id int64, comment string) (models.Dispute, error) {
+	ret := _m.Called(ctx, id, comment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TransferDisputedOrder")
+	}
+
+	var r0 models.Dispute
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (models.Dispute, error)); ok {
+		return rf(ctx, id, comment)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) models.Dispute); ok {
+		r0 = rf(ctx, id, comment)
+	} else {
+		r0 = ret.Get(0).(models.Dispute)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, id, comment)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateOrderAndAccrualPoints provides a mock function with given fields: ctx, orderData
+func (_m *StorageProvider) UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) (float64, error) {
+	ret := _m.Called(ctx, orderData)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOrderAndAccrualPoints")
+	}
+
+	var r0 float64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *loyalty.OrderResponse) (float64, error)); ok {
+		return rf(ctx, orderData)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *loyalty.OrderResponse) float64); ok {
+		r0 = rf(ctx, orderData)
+	} else {
+		r0 = ret.Get(0).(float64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *loyalty.OrderResponse) error); ok {
+		r1 = rf(ctx, orderData)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Withdrow provides a mock function with given fields: ctx, sum, userLogin, order, partial, limits
+func (_m *StorageProvider) Withdrow(ctx context.Context, sum float64, userLogin string, order string, partial bool, limits models.WithdrawalLimits) error {
+	ret := _m.Called(ctx, sum, userLogin, order, partial, limits)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Withdrow")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, float64, string, string) error); ok {
-		r0 = rf(ctx, sum, userLogin, order)
+	if rf, ok := ret.Get(0).(func(context.Context, float64, string, string, bool, models.WithdrawalLimits) error); ok {
+		r0 = rf(ctx, sum, userLogin, order, partial, limits)
 	} else {
 		r0 = ret.Error(0)
 	}
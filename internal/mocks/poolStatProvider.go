@@ -0,0 +1,47 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// poolStatProvider is an autogenerated mock type for the poolStatProvider type
+type poolStatProvider struct {
+	mock.Mock
+}
+
+// PoolStat provides a mock function with no fields
+func (_m *poolStatProvider) PoolStat() *pgxpool.Stat {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for PoolStat")
+	}
+
+	var r0 *pgxpool.Stat
+	if rf, ok := ret.Get(0).(func() *pgxpool.Stat); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*pgxpool.Stat)
+		}
+	}
+
+	return r0
+}
+
+// newPoolStatProvider creates a new instance of poolStatProvider. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func newPoolStatProvider(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *poolStatProvider {
+	mock := &poolStatProvider{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
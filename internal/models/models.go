@@ -2,46 +2,111 @@ package models
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
-type ErrorResponse struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+// Problem описывает ошибку в формате RFC 7807 (application/problem+json).
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ProblemCode  `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
 }
 
+// ProblemCode стабильный машиночитаемый код ошибки, на который может опираться клиент.
+type ProblemCode string
+
 const (
-	StatusError = "Error"
+	ProblemEmptyBody          ProblemCode = "empty-body"
+	ProblemMalformedBody      ProblemCode = "malformed-body"
+	ProblemValidationFailed   ProblemCode = "validation-failed"
+	ProblemInvalidQuery       ProblemCode = "invalid-query"
+	ProblemUnauthorized       ProblemCode = "unauthorized"
+	ProblemInvalidCredentials ProblemCode = "invalid-credentials"
+	ProblemUserExists         ProblemCode = "user-exists"
+	ProblemInvalidOrderNumber ProblemCode = "invalid-order-number"
+	ProblemOrderOwnedByOther  ProblemCode = "order-owned-by-other-user"
+	ProblemInsufficientPoints ProblemCode = "insufficient-points"
+	ProblemInvalidIdempotency ProblemCode = "invalid-idempotency-key"
+	ProblemIdempotencyReused  ProblemCode = "idempotency-key-reused"
+	ProblemRequestInProgress  ProblemCode = "request-in-progress"
+	ProblemForbidden          ProblemCode = "forbidden"
+	ProblemWithdrawalNotFound ProblemCode = "withdrawal-not-found"
+	ProblemAlreadyRefunded    ProblemCode = "withdrawal-already-refunded"
+	ProblemRefundExceedsSum   ProblemCode = "refund-exceeds-withdrawal"
+	ProblemNotFound           ProblemCode = "not-found"
+	ProblemMethodNotAllowed   ProblemCode = "method-not-allowed"
+	ProblemPayloadTooLarge    ProblemCode = "payload-too-large"
+	ProblemTransferToSelf     ProblemCode = "transfer-to-self"
+	ProblemRecipientNotFound  ProblemCode = "recipient-not-found"
+	ProblemTransferLimit      ProblemCode = "transfer-limit-exceeded"
+	ProblemPromoNotFound      ProblemCode = "promo-code-not-found"
+	ProblemPromoExpired       ProblemCode = "promo-code-expired"
+	ProblemPromoExhausted     ProblemCode = "promo-code-exhausted"
+	ProblemPromoRedeemed      ProblemCode = "promo-code-already-redeemed"
+	ProblemPromoExists        ProblemCode = "promo-code-exists"
+	ProblemReferralNotFound   ProblemCode = "referral-code-not-found"
+	ProblemOrderPaid          ProblemCode = "order-already-paid"
+	ProblemWithdrawalTooLarge ProblemCode = "withdrawal-limit-exceeded"
+	ProblemWithdrawalDaily    ProblemCode = "daily-withdrawal-limit-exceeded"
+	ProblemHoldNotFound       ProblemCode = "hold-not-found"
+	ProblemHoldNotActive      ProblemCode = "hold-not-active"
+	ProblemCaptureExceedsHold ProblemCode = "capture-exceeds-hold"
+	ProblemOperationBlocked   ProblemCode = "operation-blocked"
+	ProblemChallengeRequired  ProblemCode = "challenge-required"
+	ProblemFlagNotFound       ProblemCode = "flagged-user-not-found"
+	ProblemOrderNotFound      ProblemCode = "order-not-found"
+	ProblemOrderAlreadyYours  ProblemCode = "order-already-yours"
+	ProblemDisputeExists      ProblemCode = "dispute-already-open"
+	ProblemDisputeNotFound    ProblemCode = "dispute-not-found"
+	ProblemDisputeNotOpen     ProblemCode = "dispute-not-open"
+	ProblemDeletionNotFound   ProblemCode = "deletion-not-requested"
+	ProblemInternal           ProblemCode = "internal"
 )
 
-func Error(msg string) ErrorResponse {
-	return ErrorResponse{
-		Status: StatusError,
-		Error:  msg,
-	}
+const ProblemTypePrefix = "urn:gophermart:problem:"
+
+// Type возвращает URI типа ошибки для поля type.
+func (c ProblemCode) Type() string {
+	return ProblemTypePrefix + string(c)
+}
+
+// FieldError описывает ошибку валидации конкретного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
-func ValidationError(errs validator.ValidationErrors) ErrorResponse {
-	var errMsgs []string
+func ValidationErrors(errs validator.ValidationErrors) []FieldError {
+	var fieldErrs []FieldError
 
 	for _, err := range errs {
+		var msg string
 		switch err.ActualTag() {
 		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
+			msg = fmt.Sprintf("field %s is a required field", err.Field())
 		case "url":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid URL", err.Field()))
+			msg = fmt.Sprintf("field %s is not a valid URL", err.Field())
+		case "decimals":
+			msg = fmt.Sprintf("field %s must have at most %s decimal places", err.Field(), err.Param())
 		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
+			msg = fmt.Sprintf("field %s is not valid", err.Field())
 		}
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   err.Field(),
+			Code:    err.ActualTag(),
+			Message: msg,
+		})
 	}
 
-	return ErrorResponse{
-		Status: StatusError,
-		Error:  strings.Join(errMsgs, ", "),
-	}
+	return fieldErrs
 }
 
 type Credantials struct {
@@ -49,6 +114,13 @@ type Credantials struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// RegistrationRequest данные регистрации; ReferralCode — реферальный код пригласившего пользователя
+type RegistrationRequest struct {
+	Login        string `json:"login" validate:"required"`
+	Password     string `json:"password" validate:"required"`
+	ReferralCode string `json:"referral_code,omitempty" validate:"omitempty,max=16"`
+}
+
 type AccessToken struct {
 	Token     string `json:"token" validate:"required"`
 	TokenType string `json:"token_type" validate:"required"`
@@ -63,20 +135,474 @@ type Order struct {
 
 type Orders []Order
 
+// Balance баланс пользователя: Current доступен для списания, Held зарезервирован под незавершённые оплаты
 type Balance struct {
 	Current   float64 `json:"current"`
+	Held      float64 `json:"held"`
 	Withdrawn float64 `json:"withdrawn"`
+	// ExpiringSoon баллы, которые сгорят в ближайшее время, по дням сгорания
+	ExpiringSoon []ExpiringPoints `json:"expiring_soon,omitempty"`
+}
+
+// ExpiringPoints баллы, сгорающие в один день; ExpiresAt — момент сгорания первой из партий этого дня
+type ExpiringPoints struct {
+	Sum       float64   `json:"sum"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PointLotSource откуда в балансе появилась партия баллов
+type PointLotSource string
+
+const (
+	PointLotAccrual        PointLotSource = "ACCRUAL"
+	PointLotRefund         PointLotSource = "REFUND"
+	PointLotAdjustment     PointLotSource = "ADJUSTMENT"
+	PointLotOpeningBalance PointLotSource = "OPENING_BALANCE"
+	PointLotTransfer       PointLotSource = "TRANSFER"
+	PointLotPromo          PointLotSource = "PROMO"
+	PointLotCampaign       PointLotSource = "CAMPAIGN"
+	PointLotReferral       PointLotSource = "REFERRAL"
+	PointLotDispute        PointLotSource = "DISPUTE"
+)
+
+// ExpirationReport итоги одного прохода сгорания баллов
+type ExpirationReport struct {
+	Users  int
+	Points float64
+}
+
+// Profile профиль пользователя с его уровнем в программе лояльности
+type Profile struct {
+	Login string       `json:"login"`
+	Tier  TierProgress `json:"tier"`
+	// ReferralCode код, который пользователь передаёт приглашённым
+	ReferralCode string `json:"referral_code"`
+	// ReferredBy кто пригласил пользователя
+	ReferredBy string `json:"referred_by,omitempty"`
+	// DeletionScheduledAt когда аккаунт будет обезличен, если пользователь запросил удаление
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
 }
 
+// TierProgress текущий уровень и прогресс до следующего.
+// Уровень пересчитывается периодически, а QualifyingAccrual считается на момент запроса,
+// поэтому сумма может уже превышать порог следующего уровня.
+type TierProgress struct {
+	Name       string  `json:"name"`
+	Multiplier float64 `json:"multiplier"`
+	// QualifyingAccrual начисления системы лояльности за окно расчёта уровня, без учёта множителей
+	QualifyingAccrual float64   `json:"qualifying_accrual"`
+	Next              *NextTier `json:"next,omitempty"`
+}
+
+// NextTier следующий уровень; Remaining — сколько начислений не хватает до его порога
+type NextTier struct {
+	Name       string  `json:"name"`
+	MinAccrual float64 `json:"min_accrual"`
+	Remaining  float64 `json:"remaining"`
+}
+
+// OrderSum списание баллов в счёт заказа. По умолчанию заказ оплачивается одним списанием;
+// Partial разрешает доплачивать тот же заказ следующими списаниями с Partial.
 type OrderSum struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order   string  `json:"order" validate:"required"`
+	Sum     float64 `json:"sum" validate:"gt=0,decimals=2"`
+	Partial bool    `json:"partial,omitempty"`
 }
 
+// WithdrawalLimits ограничения на списания; нулевое значение снимает ограничение
+type WithdrawalLimits struct {
+	// Max наибольшая сумма одного списания или резерва
+	Max float64
+	// Daily сколько можно списать за последние сутки вместе с активными резервами
+	Daily float64
+}
+
+type WithdrawalStatus string
+
+const (
+	WithdrawalCompleted         WithdrawalStatus = "COMPLETED"
+	WithdrawalPartiallyRefunded WithdrawalStatus = "PARTIALLY_REFUNDED"
+	WithdrawalRefunded          WithdrawalStatus = "REFUNDED"
+)
+
 type Withdrawn struct {
-	Order       string    `json:"order"`
-	Sum         float64   `json:"sum"`
-	ProccesedAt time.Time `json:"proccesed_at"`
+	Order       string           `json:"order"`
+	Sum         float64          `json:"sum"`
+	ProccesedAt time.Time        `json:"proccesed_at"`
+	Status      WithdrawalStatus `json:"status"`
+	Refunded    float64          `json:"refunded"`
+}
+
+// RefundRequest запрос на возврат списанных баллов. Если Sum не указан, возвращается весь остаток.
+type RefundRequest struct {
+	Sum    *float64 `json:"sum,omitempty" validate:"omitempty,gt=0,decimals=2"`
+	Reason string   `json:"reason" validate:"required"`
 }
 
 type Withdrawals []Withdrawn
+
+// HoldRequest резерв баллов под оплату заказа в магазине
+type HoldRequest struct {
+	Order string  `json:"order" validate:"required"`
+	Sum   float64 `json:"sum" validate:"gt=0,decimals=2"`
+}
+
+// CaptureRequest списание зарезервированных баллов. Если Sum не указан, списывается весь резерв,
+// иначе списывается Sum, а остаток резерва возвращается на баланс.
+type CaptureRequest struct {
+	Sum *float64 `json:"sum,omitempty" validate:"omitempty,gt=0,decimals=2"`
+}
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldReleased HoldStatus = "RELEASED"
+	HoldExpired  HoldStatus = "EXPIRED"
+)
+
+// Hold резерв баллов; после списания Captured попадает в историю списаний по заказу Order
+type Hold struct {
+	ID        int64      `json:"id"`
+	Order     string     `json:"order"`
+	Sum       float64    `json:"sum"`
+	Captured  float64    `json:"captured"`
+	Status    HoldStatus `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// TransferRequest перевод баллов другому пользователю
+type TransferRequest struct {
+	Recipient string  `json:"recipient" validate:"required"`
+	Sum       float64 `json:"sum" validate:"gt=0,decimals=2"`
+	Comment   string  `json:"comment,omitempty" validate:"max=200"`
+}
+
+// TransferDirection направление перевода относительно пользователя, смотрящего историю
+type TransferDirection string
+
+const (
+	TransferOutgoing TransferDirection = "OUT"
+	TransferIncoming TransferDirection = "IN"
+)
+
+// Transfer перевод в истории пользователя; Counterparty — второй участник перевода
+type Transfer struct {
+	ID           int64             `json:"id"`
+	Direction    TransferDirection `json:"direction"`
+	Counterparty string            `json:"counterparty"`
+	Sum          float64           `json:"sum"`
+	Comment      string            `json:"comment,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type Transfers []Transfer
+
+// PromoCode промокод на начисление баллов. Если ExpiresAt или MaxRedemptions не заданы, ограничения нет.
+type PromoCode struct {
+	Code           string     `json:"code" validate:"required,max=64"`
+	Amount         float64    `json:"amount" validate:"gt=0,decimals=2"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxRedemptions *int       `json:"max_redemptions,omitempty" validate:"omitempty,gt=0"`
+	// PerUserLimit сколько раз один пользователь может активировать код, по умолчанию один
+	PerUserLimit int `json:"per_user_limit" validate:"gte=0"`
+	Redemptions  int `json:"redemptions"`
+}
+
+// PromoRequest активация промокода пользователем
+type PromoRequest struct {
+	Code string `json:"code" validate:"required,max=64"`
+}
+
+// PromoRedemption начисление баллов по промокоду
+type PromoRedemption struct {
+	ID         int64     `json:"id"`
+	Code       string    `json:"code"`
+	Amount     float64   `json:"amount"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// CampaignEvent событие, при котором кампания начисляет бонус
+type CampaignEvent string
+
+const (
+	CampaignRegistration CampaignEvent = "REGISTRATION"
+)
+
+// Campaign бонусная кампания: каждому пользователю при событии Event в период кампании начисляется Amount
+type Campaign struct {
+	ID       int64         `json:"id"`
+	Name     string        `json:"name" validate:"required,max=100"`
+	Event    CampaignEvent `json:"event" validate:"required,oneof=REGISTRATION"`
+	Amount   float64       `json:"amount" validate:"gt=0,decimals=2"`
+	StartsAt *time.Time    `json:"starts_at,omitempty"`
+	EndsAt   *time.Time    `json:"ends_at,omitempty"`
+}
+
+// ReferralRules награды реферальной программы: начисляются обоим, когда первый заказ приглашённого обработан
+type ReferralRules struct {
+	ReferrerReward float64
+	RefereeReward  float64
+	// MonthlyLimit сколько наград пригласивший может получить за месяц, 0 — без ограничения
+	MonthlyLimit int
+}
+
+// Enabled сообщает, включена ли программа: при нулевых наградах начислять нечего
+func (r ReferralRules) Enabled() bool {
+	return r.ReferrerReward > 0 || r.RefereeReward > 0
+}
+
+type ReferralRewardStatus string
+
+const (
+	// награды начислены обоим
+	ReferralRewardCredited ReferralRewardStatus = "CREDITED"
+	// пригласивший исчерпал месячный лимит, награды не начислены
+	ReferralRewardLimited ReferralRewardStatus = "LIMITED"
+)
+
+// TransferLimits ограничения на переводы отправителя за последние сутки; нулевое значение снимает ограничение
+type TransferLimits struct {
+	Sum   float64
+	Count int
+}
+
+// IdempotencyRecord сохранённый результат запроса, выполненного с заголовком Idempotency-Key.
+type IdempotencyRecord struct {
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Completed сообщает, что исходный запрос уже выполнен и его ответ можно повторить.
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+type AdjustmentStatus string
+
+const (
+	// корректировка применена полностью
+	AdjustmentApplied AdjustmentStatus = "APPLIED"
+	// списание ограничено текущим балансом, остаток не взыскан
+	AdjustmentClamped AdjustmentStatus = "CLAMPED"
+	// списание увело бы баланс в минус, корректировка ждёт ручного разбора
+	AdjustmentHeld AdjustmentStatus = "HELD"
+)
+
+// NegativeBalancePolicy определяет, что делать, если отзыв начисления уводит баланс в минус.
+type NegativeBalancePolicy string
+
+const (
+	NegativeBalanceClamp NegativeBalancePolicy = "clamp"
+	NegativeBalanceHold  NegativeBalancePolicy = "hold"
+)
+
+// ProcessedOrder заказ с окончательно рассчитанным начислением.
+type ProcessedOrder struct {
+	Number  string
+	Accrual float64
+}
+
+// AccrualAdjustment корректировка начисления после пересчёта в системе лояльности.
+// Delta — разница начислений системы лояльности, Applied — изменение баланса с учётом множителя уровня,
+// действовавшего при исходном начислении.
+type AccrualAdjustment struct {
+	Order           string           `json:"order"`
+	UserLogin       string           `json:"user_login"`
+	PreviousAccrual float64          `json:"previous_accrual"`
+	NewAccrual      float64          `json:"new_accrual"`
+	Delta           float64          `json:"delta"`
+	Applied         float64          `json:"applied"`
+	Status          AdjustmentStatus `json:"status"`
+	CreatedAt       time.Time        `json:"created_at"`
+}
+
+type AccrualAdjustments []AccrualAdjustment
+
+type CheckStatus string
+
+const (
+	CheckOK   CheckStatus = "ok"
+	CheckFail CheckStatus = "fail"
+)
+
+// ReadinessCheck результат проверки одной зависимости.
+// Некритичные проверки отражаются в ответе, но не снимают готовность.
+type ReadinessCheck struct {
+	Status   CheckStatus `json:"status"`
+	Critical bool        `json:"critical"`
+}
+
+// Readiness ответ /readyz
+type Readiness struct {
+	Ready  bool                      `json:"ready"`
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+// LogLevel уровень логирования и пользователи, для запросов которых пишутся отладочные логи.
+type LogLevel struct {
+	Level      string   `json:"level" validate:"required,oneof=debug info warn error"`
+	DebugUsers []string `json:"debug_users" validate:"dive,required"`
+}
+
+// FraudOperation операция, которую проверяют антифрод-правила
+type FraudOperation string
+
+const (
+	FraudOrderUpload FraudOperation = "ORDER_UPLOAD"
+	FraudWithdrawal  FraudOperation = "WITHDRAWAL"
+)
+
+// FraudDecision решение антифрода; решения упорядочены по строгости
+type FraudDecision string
+
+const (
+	FraudAllow     FraudDecision = "ALLOW"
+	FraudChallenge FraudDecision = "CHALLENGE"
+	FraudBlock     FraudDecision = "BLOCK"
+)
+
+// Severity строгость решения: из решений нескольких правил действует самое строгое
+func (d FraudDecision) Severity() int {
+	switch d {
+	case FraudChallenge:
+		return 1
+	case FraudBlock:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// FraudSignals сведения о пользователе, по которым антифрод-правила принимают решение
+type FraudSignals struct {
+	// OrdersLastHour сколько заказов пользователь загрузил за последний час
+	OrdersLastHour int
+	// FinishedOrders заказы в конечном статусе, ProcessedOrders — из них обработанные с начислением
+	FinishedOrders  int
+	ProcessedOrders int
+	// IPFirstSeen когда пользователь впервые вошёл с адреса запроса, nil — ни разу не входил с него
+	IPFirstSeen *time.Time
+	// OtherIPs со скольких других адресов пользователь входил раньше
+	OtherIPs int
+	// Flagged аккаунт отправлен на проверку
+	Flagged bool
+}
+
+// FraudVerdict итог проверки операции: сработавшие правила, решение и нужно ли отправить аккаунт на проверку
+type FraudVerdict struct {
+	UserLogin string
+	Operation FraudOperation
+	IP        string
+	Decision  FraudDecision
+	Rules     []string
+	Flag      bool
+	// ChallengePassed пользователь подтвердил операцию паролем
+	ChallengePassed bool
+}
+
+// FlaggedUser аккаунт, отправленный антифродом на проверку
+type FlaggedUser struct {
+	Login     string    `json:"login"`
+	Reason    string    `json:"reason"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+type FlaggedUsers []FlaggedUser
+
+// DisputeRequest заявка на заказ, который загрузил другой пользователь
+type DisputeRequest struct {
+	Evidence string `json:"evidence" validate:"required,max=2000"`
+}
+
+// DisputeResolution решение администратора по спору
+type DisputeResolution struct {
+	Comment string `json:"comment" validate:"required,max=2000"`
+}
+
+type DisputeStatus string
+
+const (
+	DisputeOpen        DisputeStatus = "OPEN"
+	DisputeTransferred DisputeStatus = "TRANSFERRED"
+	DisputeRejected    DisputeStatus = "REJECTED"
+)
+
+// Valid сообщает, известен ли статус
+func (s DisputeStatus) Valid() bool {
+	switch s {
+	case DisputeOpen, DisputeTransferred, DisputeRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Dispute спор о принадлежности заказа. Owner — кто владел заказом, когда спор открыли.
+// Если заказ передан уже обработанным, Reclaimed списано с прежнего владельца (не больше, чем было на счету),
+// а Credited начислено заявителю.
+type Dispute struct {
+	ID         int64         `json:"id"`
+	Order      string        `json:"order"`
+	Claimant   string        `json:"claimant"`
+	Owner      string        `json:"owner"`
+	Evidence   string        `json:"evidence"`
+	Status     DisputeStatus `json:"status"`
+	Comment    string        `json:"comment,omitempty"`
+	Reclaimed  float64       `json:"reclaimed"`
+	Credited   float64       `json:"credited"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ResolvedAt *time.Time    `json:"resolved_at,omitempty"`
+}
+
+type Disputes []Dispute
+
+// BalanceEntryKind вид операции в истории баланса
+type BalanceEntryKind string
+
+const (
+	// BalanceCredit зачисление партии баллов; источник партии — в Source
+	BalanceCredit       BalanceEntryKind = "CREDIT"
+	BalanceWithdrawal   BalanceEntryKind = "WITHDRAWAL"
+	BalanceExpiration   BalanceEntryKind = "EXPIRATION"
+	BalanceTransferOut  BalanceEntryKind = "TRANSFER_OUT"
+	BalanceAdjustment   BalanceEntryKind = "ADJUSTMENT"
+	BalanceDisputeDebit BalanceEntryKind = "DISPUTE"
+)
+
+// BalanceEntry операция, изменившая баланс: Sum положителен для зачислений и отрицателен для списаний.
+// Резервы в историю не попадают, пока не списаны.
+type BalanceEntry struct {
+	Kind      BalanceEntryKind `json:"kind"`
+	Source    PointLotSource   `json:"source,omitempty"`
+	Order     string           `json:"order,omitempty"`
+	Sum       float64          `json:"sum"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// UserExport все данные пользователя, которые он может выгрузить
+type UserExport struct {
+	ExportedAt     time.Time      `json:"exported_at"`
+	Profile        Profile        `json:"profile"`
+	Balance        Balance        `json:"balance"`
+	Orders         Orders         `json:"orders"`
+	Withdrawals    Withdrawals    `json:"withdrawals"`
+	Transfers      Transfers      `json:"transfers"`
+	BalanceHistory []BalanceEntry `json:"balance_history"`
+}
+
+// DeletionRequest запрос на удаление аккаунта подтверждается паролем
+type DeletionRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// AccountDeletion запланированное удаление аккаунта: после DeleteAfter логин и личные данные обезличиваются,
+// а финансовые записи остаются для учёта
+type AccountDeletion struct {
+	RequestedAt time.Time `json:"requested_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
@@ -0,0 +1,15 @@
+package openapi
+
+import (
+	_ "embed"
+)
+
+// Spec содержит OpenAPI 3 описание HTTP API гофермарта.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// DocsPage содержит HTML-страницу со Swagger UI, которая загружает Spec.
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE withdrawals
+ADD COLUMN status VARCHAR(50) NOT NULL DEFAULT 'COMPLETED',
+ADD COLUMN refunded NUMERIC NOT NULL DEFAULT 0,
+ADD CONSTRAINT refunded_not_exceed_sum CHECK (refunded >= 0 AND refunded <= sum);
+CREATE TABLE withdrawal_refunds (
+    id SERIAL PRIMARY KEY,
+    "order" VARCHAR(100) NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY ("order") REFERENCES withdrawals("order")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE withdrawal_refunds;
+ALTER TABLE withdrawals
+DROP CONSTRAINT refunded_not_exceed_sum,
+DROP COLUMN refunded,
+DROP COLUMN status;
+-- +goose StatementEnd
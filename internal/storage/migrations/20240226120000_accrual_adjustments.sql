@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE accrual_adjustments (
+    id SERIAL PRIMARY KEY,
+    "order" VARCHAR(100) NOT NULL,
+    user_login VARCHAR(100) NOT NULL,
+    previous_accrual NUMERIC NOT NULL,
+    new_accrual NUMERIC NOT NULL,
+    delta NUMERIC NOT NULL,
+    applied NUMERIC NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY ("order") REFERENCES orders(number),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX accrual_adjustments_created_at_idx ON accrual_adjustments (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE accrual_adjustments;
+-- +goose StatementEnd
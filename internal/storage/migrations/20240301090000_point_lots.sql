@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE point_lots (
+    id BIGSERIAL PRIMARY KEY,
+    user_login VARCHAR(100) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    "order" VARCHAR(100),
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    remaining NUMERIC NOT NULL,
+    accrued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT remaining_within_amount CHECK (remaining >= 0 AND remaining <= amount),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX point_lots_user_login_idx ON point_lots (user_login, accrued_at, id) WHERE remaining > 0;
+CREATE INDEX point_lots_expires_at_idx ON point_lots (expires_at) WHERE remaining > 0;
+CREATE TABLE point_expirations (
+    id BIGSERIAL PRIMARY KEY,
+    lot_id BIGINT NOT NULL,
+    user_login VARCHAR(100) NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    expired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lot_id) REFERENCES point_lots(id),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX point_expirations_user_login_idx ON point_expirations (user_login, expired_at);
+-- накопленный до появления партий баланс переносим бессрочной партией
+INSERT INTO point_lots (user_login, source, amount, remaining)
+SELECT user_login, 'OPENING_BALANCE', current, current FROM user_balance WHERE current > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE point_expirations;
+DROP TABLE point_lots;
+-- +goose StatementEnd
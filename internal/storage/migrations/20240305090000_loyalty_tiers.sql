@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE loyalty_tiers (
+    name VARCHAR(50) PRIMARY KEY,
+    min_accrual NUMERIC NOT NULL UNIQUE CHECK (min_accrual >= 0),
+    multiplier NUMERIC NOT NULL CHECK (multiplier > 0)
+);
+INSERT INTO loyalty_tiers (name, min_accrual, multiplier) VALUES
+    ('BASE', 0, 1),
+    ('SILVER', 1000, 1.05),
+    ('GOLD', 5000, 1.1),
+    ('PLATINUM', 20000, 1.2);
+ALTER TABLE users
+ADD COLUMN tier VARCHAR(50) NOT NULL DEFAULT 'BASE',
+ADD COLUMN tier_updated_at TIMESTAMP WITH TIME ZONE,
+ADD CONSTRAINT fk_users_tier FOREIGN KEY (tier) REFERENCES loyalty_tiers(name) ON UPDATE CASCADE;
+-- multiplier фиксирует множитель уровня на момент начисления, accrual остаётся значением системы лояльности
+ALTER TABLE orders
+ADD COLUMN processed_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN multiplier NUMERIC NOT NULL DEFAULT 1 CHECK (multiplier > 0);
+UPDATE orders SET processed_at = COALESCE(updated_at, uploaded_at) WHERE status = 'PROCESSED';
+CREATE INDEX orders_user_login_processed_at_idx ON orders (user_login, processed_at) WHERE status = 'PROCESSED';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_user_login_processed_at_idx;
+ALTER TABLE orders
+DROP COLUMN multiplier,
+DROP COLUMN processed_at;
+ALTER TABLE users
+DROP CONSTRAINT fk_users_tier,
+DROP COLUMN tier_updated_at,
+DROP COLUMN tier;
+DROP TABLE loyalty_tiers;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transfers (
+    id BIGSERIAL PRIMARY KEY,
+    sender_login VARCHAR(100) NOT NULL,
+    recipient_login VARCHAR(100) NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT transfer_to_other_user CHECK (sender_login <> recipient_login),
+    FOREIGN KEY (sender_login) REFERENCES users(login),
+    FOREIGN KEY (recipient_login) REFERENCES users(login)
+);
+CREATE INDEX transfers_sender_login_idx ON transfers (sender_login, created_at);
+CREATE INDEX transfers_recipient_login_idx ON transfers (recipient_login, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE transfers;
+-- +goose StatementEnd
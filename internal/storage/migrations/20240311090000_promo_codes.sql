@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE promo_codes (
+    code VARCHAR(64) PRIMARY KEY,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    -- NULL — код не истекает
+    expires_at TIMESTAMP WITH TIME ZONE,
+    -- NULL — число активаций не ограничено
+    max_redemptions INTEGER CHECK (max_redemptions > 0),
+    per_user_limit INTEGER NOT NULL DEFAULT 1 CHECK (per_user_limit > 0),
+    redemptions INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT redemptions_within_max CHECK (max_redemptions IS NULL OR redemptions <= max_redemptions)
+);
+CREATE TABLE promo_redemptions (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(64) NOT NULL,
+    user_login VARCHAR(100) NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    redeemed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (code) REFERENCES promo_codes(code),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX promo_redemptions_code_user_login_idx ON promo_redemptions (code, user_login);
+CREATE TABLE campaigns (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- NULL — кампания бессрочная
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT campaign_period CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+CREATE INDEX campaigns_event_idx ON campaigns (event, starts_at);
+CREATE TABLE campaign_credits (
+    id BIGSERIAL PRIMARY KEY,
+    campaign_id BIGINT NOT NULL,
+    user_login VARCHAR(100) NOT NULL,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (campaign_id, user_login),
+    FOREIGN KEY (campaign_id) REFERENCES campaigns(id),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE campaign_credits;
+DROP TABLE campaigns;
+DROP TABLE promo_redemptions;
+DROP TABLE promo_codes;
+-- +goose StatementEnd
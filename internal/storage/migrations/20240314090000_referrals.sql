@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN referral_code VARCHAR(16),
+ADD COLUMN referred_by VARCHAR(100),
+ADD CONSTRAINT users_referral_code_key UNIQUE (referral_code),
+ADD CONSTRAINT fk_users_referred_by FOREIGN KEY (referred_by) REFERENCES users(login);
+-- коды существующих пользователей; новые коды генерирует сервис при регистрации
+UPDATE users SET referral_code = upper(substr(md5(login || random()::text), 1, 8));
+ALTER TABLE users ALTER COLUMN referral_code SET NOT NULL;
+CREATE INDEX users_referred_by_idx ON users (referred_by);
+CREATE TABLE referral_rewards (
+    id BIGSERIAL PRIMARY KEY,
+    referrer_login VARCHAR(100) NOT NULL,
+    referee_login VARCHAR(100) NOT NULL UNIQUE,
+    "order" VARCHAR(100) NOT NULL,
+    referrer_amount NUMERIC NOT NULL CHECK (referrer_amount >= 0),
+    referee_amount NUMERIC NOT NULL CHECK (referee_amount >= 0),
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (referrer_login) REFERENCES users(login),
+    FOREIGN KEY (referee_login) REFERENCES users(login),
+    FOREIGN KEY ("order") REFERENCES orders(number)
+);
+CREATE INDEX referral_rewards_referrer_login_idx ON referral_rewards (referrer_login, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE referral_rewards;
+DROP INDEX users_referred_by_idx;
+ALTER TABLE users
+DROP CONSTRAINT fk_users_referred_by,
+DROP CONSTRAINT users_referral_code_key,
+DROP COLUMN referred_by,
+DROP COLUMN referral_code;
+-- +goose StatementEnd
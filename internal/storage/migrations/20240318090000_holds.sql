@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_balance
+ADD COLUMN held NUMERIC NOT NULL DEFAULT 0,
+ADD CONSTRAINT held_not_negative CHECK (held >= 0);
+CREATE TABLE holds (
+    id BIGSERIAL PRIMARY KEY,
+    user_login VARCHAR(100) NOT NULL,
+    "order" VARCHAR(100) NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    captured NUMERIC NOT NULL DEFAULT 0,
+    status VARCHAR(50) NOT NULL DEFAULT 'ACTIVE',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT captured_within_sum CHECK (captured >= 0 AND captured <= sum),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+-- по заказу может быть только один незавершённый резерв
+CREATE UNIQUE INDEX holds_active_order_idx ON holds ("order") WHERE status = 'ACTIVE';
+CREATE INDEX holds_expires_at_idx ON holds (expires_at) WHERE status = 'ACTIVE';
+-- из каких партий взяты зарезервированные баллы: при отмене резерва они возвращаются в те же партии
+CREATE TABLE hold_lots (
+    hold_id BIGINT NOT NULL,
+    lot_id BIGINT NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    PRIMARY KEY (hold_id, lot_id),
+    FOREIGN KEY (hold_id) REFERENCES holds(id),
+    FOREIGN KEY (lot_id) REFERENCES point_lots(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE hold_lots;
+DROP TABLE holds;
+ALTER TABLE user_balance
+DROP CONSTRAINT held_not_negative,
+DROP COLUMN held;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- partial разрешает оплачивать заказ баллами в несколько списаний; сумма списания по заказу накапливается
+ALTER TABLE withdrawals
+ADD COLUMN partial BOOLEAN NOT NULL DEFAULT FALSE;
+-- каждое списание по отдельности: по ним считается суточный лимит
+CREATE TABLE withdrawal_payments (
+    id BIGSERIAL PRIMARY KEY,
+    "order" VARCHAR(100) NOT NULL,
+    user_login VARCHAR(100) NOT NULL,
+    sum NUMERIC NOT NULL CHECK (sum > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY ("order") REFERENCES withdrawals("order"),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX withdrawal_payments_user_login_idx ON withdrawal_payments (user_login, created_at);
+INSERT INTO withdrawal_payments ("order", user_login, sum, created_at)
+SELECT "order", user_login, sum, COALESCE(proccesed_at, CURRENT_TIMESTAMP) FROM withdrawals WHERE sum > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE withdrawal_payments;
+ALTER TABLE withdrawals
+DROP COLUMN partial;
+-- +goose StatementEnd
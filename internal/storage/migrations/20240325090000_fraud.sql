@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- адреса, с которых пользователь регистрировался и входил
+CREATE TABLE user_ips (
+    user_login VARCHAR(100) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_login, ip),
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+-- сработавшие антифрод-правила; операции, где ни одно правило не сработало, не записываются
+CREATE TABLE fraud_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    user_login VARCHAR(100) NOT NULL,
+    operation VARCHAR(50) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    decision VARCHAR(50) NOT NULL,
+    rules TEXT[] NOT NULL,
+    challenge_passed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_login) REFERENCES users(login)
+);
+CREATE INDEX fraud_decisions_user_login_idx ON fraud_decisions (user_login, created_at);
+ALTER TABLE users
+ADD COLUMN flagged_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN flag_reason TEXT;
+CREATE INDEX users_flagged_at_idx ON users (flagged_at) WHERE flagged_at IS NOT NULL;
+CREATE INDEX orders_user_login_uploaded_at_idx ON orders (user_login, uploaded_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_user_login_uploaded_at_idx;
+DROP INDEX users_flagged_at_idx;
+ALTER TABLE users
+DROP COLUMN flag_reason,
+DROP COLUMN flagged_at;
+DROP TABLE fraud_decisions;
+DROP TABLE user_ips;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE order_disputes (
+    id BIGSERIAL PRIMARY KEY,
+    "order" VARCHAR(100) NOT NULL,
+    claimant_login VARCHAR(100) NOT NULL,
+    owner_login VARCHAR(100) NOT NULL,
+    evidence TEXT NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'OPEN',
+    comment TEXT,
+    reclaimed NUMERIC NOT NULL DEFAULT 0,
+    credited NUMERIC NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY ("order") REFERENCES orders(number),
+    FOREIGN KEY (claimant_login) REFERENCES users(login),
+    FOREIGN KEY (owner_login) REFERENCES users(login)
+);
+-- пользователь может держать открытым только один спор по заказу
+CREATE UNIQUE INDEX order_disputes_open_idx ON order_disputes ("order", claimant_login) WHERE status = 'OPEN';
+CREATE INDEX order_disputes_status_idx ON order_disputes (status, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_disputes;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- при обезличивании логин меняется, и новое значение должно дойти до всех таблиц, которые на него ссылаются
+DO $$
+DECLARE
+    fk RECORD;
+BEGIN
+    FOR fk IN
+        SELECT conrelid::regclass AS tbl, conname, pg_get_constraintdef(oid) AS def
+        FROM pg_constraint WHERE contype = 'f' AND confrelid = 'users'::regclass
+    LOOP
+        EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I, ADD CONSTRAINT %I %s ON UPDATE CASCADE',
+            fk.tbl, fk.conname, fk.conname, fk.def);
+    END LOOP;
+END $$;
+
+ALTER TABLE users
+ADD COLUMN deletion_requested_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN deletion_due_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX users_deletion_due_at_idx ON users (deletion_due_at) WHERE deletion_due_at IS NOT NULL AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_deletion_due_at_idx;
+ALTER TABLE users
+DROP COLUMN deleted_at,
+DROP COLUMN deletion_due_at,
+DROP COLUMN deletion_requested_at;
+
+DO $$
+DECLARE
+    fk RECORD;
+BEGIN
+    FOR fk IN
+        SELECT conrelid::regclass AS tbl, conname, pg_get_constraintdef(oid) AS def
+        FROM pg_constraint WHERE contype = 'f' AND confrelid = 'users'::regclass
+    LOOP
+        EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I, ADD CONSTRAINT %I %s',
+            fk.tbl, fk.conname, fk.conname, replace(fk.def, ' ON UPDATE CASCADE', ''));
+    END LOOP;
+END $$;
+-- +goose StatementEnd
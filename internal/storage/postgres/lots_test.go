@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/zYoma/gophermart/internal/metrics"
+)
+
+func TestLotShortfall(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, lotShortfall(ctx, "consume", 0))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PointLotShortfalls.WithLabelValues("consume")))
+
+	// нехватка партий откатывает операцию и видна в метриках
+	err := lotShortfall(ctx, "consume", 12.5)
+	assert.ErrorIs(t, err, ErrLotsShortfall)
+	assert.Contains(t, err.Error(), "12.50")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.PointLotShortfalls.WithLabelValues("consume")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PointLotShortfalls.WithLabelValues("hold")))
+}
@@ -2,10 +2,19 @@ package postgres
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
@@ -13,8 +22,11 @@ import (
 	"github.com/zYoma/gophermart/internal/config"
 	"github.com/zYoma/gophermart/internal/integrations/loyalty"
 	"github.com/zYoma/gophermart/internal/logger"
+	"github.com/zYoma/gophermart/internal/metrics"
 	"github.com/zYoma/gophermart/internal/models"
 	"github.com/zYoma/gophermart/internal/storage"
+	"github.com/zYoma/gophermart/internal/tracing"
+	"go.uber.org/zap"
 )
 
 var (
@@ -36,6 +48,38 @@ var (
 	ErrOrdersNotFound      = errors.New("orders for user not found")
 	ErrFewPoints           = errors.New("few points for operations")
 	ErrWithdrawalsNotFound = errors.New("withdrawals not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrIdempotencyKey      = errors.New("idempotency key")
+	ErrWithdrawalNotFound  = errors.New("withdrawal not found")
+	ErrAlreadyRefunded     = errors.New("withdrawal already refunded")
+	ErrRefundExceedsSum    = errors.New("refund exceeds withdrawn sum")
+	ErrAdjustmentsNotFound = errors.New("accrual adjustments not found")
+	ErrMigrationsPending   = errors.New("migrations are not applied")
+	ErrRecipientNotFound   = errors.New("recipient not found")
+	ErrTransferLimit       = errors.New("daily transfer limit exceeded")
+	ErrTransfersNotFound   = errors.New("transfers not found")
+	ErrPromoNotFound       = errors.New("promo code not found")
+	ErrPromoExpired        = errors.New("promo code expired")
+	ErrPromoExhausted      = errors.New("promo code redemption limit reached")
+	ErrPromoRedeemed       = errors.New("promo code already redeemed")
+	ErrPromoExists         = errors.New("promo code already exists")
+	ErrReferralNotFound    = errors.New("referral code not found")
+	ErrOrderPaid           = errors.New("order already has a withdrawal or an active hold")
+	ErrWithdrawalTooLarge  = errors.New("withdrawal exceeds the per-withdrawal limit")
+	ErrWithdrawalDaily     = errors.New("daily withdrawal limit exceeded")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldNotActive       = errors.New("hold is not active")
+	ErrCaptureExceedsHold  = errors.New("capture exceeds held sum")
+	ErrFlaggedNotFound     = errors.New("flagged users not found")
+	ErrFlagNotFound        = errors.New("flagged user not found")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderAlreadyYours   = errors.New("order already belongs to the user")
+	ErrDisputeExists       = errors.New("dispute for the order is already open")
+	ErrDisputeNotFound     = errors.New("dispute not found")
+	ErrDisputesNotFound    = errors.New("disputes not found")
+	ErrDisputeNotOpen      = errors.New("dispute is already resolved")
+	ErrDeletionNotFound    = errors.New("account deletion is not requested")
+	ErrLotsShortfall       = errors.New("point lots do not cover the sum")
 	noFinalStatuses        = []string{"REGISTERED", "PROCESSING", "NEW"}
 )
 
@@ -44,6 +88,13 @@ const MigrationDir = "./internal/storage/migrations"
 type Storage struct {
 	db   *sql.DB
 	pool *pgxpool.Pool
+	// через сколько месяцев сгорают начисленные баллы, 0 — не сгорают
+	pointsExpiryMonths int
+	// за сколько последних месяцев начисления учитываются при расчёте уровня
+	tierWindowMonths int
+	referral         models.ReferralRules
+	// версия последней миграции каталога; запоминается в Init, чтобы проверка готовности не читала каталог
+	latestMigration int64
 }
 
 func New(cfg *config.Config) (storage.StorageProvider, error) {
@@ -51,18 +102,44 @@ func New(cfg *config.Config) (storage.StorageProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	// каждый запрос к БД попадает в трассировку отдельным спаном
+	config.ConnConfig.Tracer = tracing.QueryTracer{}
+	// новые соединения берут учётные данные из текущего DSN, чтобы ротация пароля не требовала перезапуска
+	beforeConnect := rotatedCredentials(cfg)
+	config.BeforeConnect = beforeConnect
 
-	// Используйте stdlib.RegisterConnConfig для регистрации настроек подключения в database/sql.
-	db, err := sql.Open("pgx", stdlib.RegisterConnConfig(config.ConnConfig))
+	// database/sql для миграций открывается с теми же настройками подключения
+	db := stdlib.OpenDB(*config.ConnConfig, stdlib.OptionBeforeConnect(beforeConnect))
+
+	dbpool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, ErrCreatePool
 	}
+	return &Storage{
+		db:                 db,
+		pool:               dbpool,
+		pointsExpiryMonths: cfg.PointsExpiryMonths,
+		tierWindowMonths:   cfg.TierWindowMonths,
+		referral: models.ReferralRules{
+			ReferrerReward: cfg.ReferralReferrerReward,
+			RefereeReward:  cfg.ReferralRefereeReward,
+			MonthlyLimit:   cfg.ReferralMonthlyLimit,
+		},
+	}, nil
+}
 
-	dbpool, err := pgxpool.New(context.Background(), cfg.DSN)
-	if err != nil {
-		return nil, ErrCreatePool
+// подставляет пользователя и пароль из актуального DSN; адрес БД без перезапуска не меняется
+func rotatedCredentials(cfg *config.Config) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		current, err := pgx.ParseConfig(cfg.Secrets().DSN)
+		if err != nil {
+			// DSN проверяется при загрузке и ротации, сюда попадать не должны
+			return fmt.Errorf("parse DSN: %w", err)
+		}
+		connConfig.User = current.User
+		connConfig.Password = current.Password
+		return nil
 	}
-	return &Storage{db: db, pool: dbpool}, nil
 }
 
 // для накатывания миграций при старте приложения
@@ -75,36 +152,69 @@ func (s *Storage) Init() error {
 		return ErrMigrate
 	}
 
+	migrations, err := goose.CollectMigrations(MigrationDir, 0, goose.MaxVersion)
+	if err != nil {
+		return ErrMigrate
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return ErrMigrate
+	}
+	s.latestMigration = last.Version
+
 	return nil
 }
 
 // создает пользователя
-func (s *Storage) CreateUser(ctx context.Context, login string, password string) error {
+func (s *Storage) CreateUser(ctx context.Context, login string, password string, referralCode string) error {
 
 	// Начало транзакции
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при начале транзакции: %s", err)
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
 		return ErrBeginTransaction
 	}
 
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(ctx); rbErr != nil {
-				logger.Log.Sugar().Errorf("Ошибка при откате транзакции: %s", rbErr)
+				logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
 			}
 		}
 	}()
 
+	var referredBy *string
+	if referralCode != "" {
+		err = tx.QueryRow(ctx, `SELECT login FROM users WHERE referral_code = $1;`, referralCode).Scan(&referredBy)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReferralNotFound
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось найти пригласившего пользователя", zap.Error(err))
+			return ErrCreateUser
+		}
+	}
+
+	ownCode, err := newReferralCode()
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось сгенерировать реферальный код", zap.Error(err))
+		return ErrCreateUser
+	}
+
 	_, err = tx.Exec(ctx, `
-        INSERT INTO users (login, password) VALUES ($1, $2);
-    `, login, password)
+        INSERT INTO users (login, password, referral_code, referred_by) VALUES ($1, $2, $3, $4);
+    `, login, password, ownCode, referredBy)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			// совпадение сгенерированного кода маловероятно, но это не занятый логин
+			if pgErr.ConstraintName == "users_referral_code_key" {
+				logger.FromContext(ctx).Error("Сгенерирован занятый реферальный код", zap.Error(err))
+				return ErrCreateUser
+			}
 			return ErrConflict
 		}
-		logger.Log.Sugar().Errorf("Не удалось создать пользователя: %s", err)
+		logger.FromContext(ctx).Error("Не удалось создать пользователя", zap.Error(err))
 		return ErrCreateUser
 	}
 
@@ -116,12 +226,18 @@ func (s *Storage) CreateUser(ctx context.Context, login string, password string)
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
 			return ErrConflict
 		}
-		logger.Log.Sugar().Errorf("Не удалось создать баланс пользователя: %s", err)
+		logger.FromContext(ctx).Error("Не удалось создать баланс пользователя", zap.Error(err))
+		return ErrCreateUserBalance
+	}
+
+	// бонус за регистрацию начисляется в той же транзакции: пользователь без бонуса не появится
+	err = s.creditCampaigns(ctx, tx, login, models.CampaignRegistration)
+	if err != nil {
 		return ErrCreateUserBalance
 	}
 
 	if commitErr := tx.Commit(ctx); commitErr != nil {
-		logger.Log.Sugar().Errorf("Ошибка при фиксации транзакции: %s", commitErr)
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(commitErr))
 		return ErrCommit
 	}
 
@@ -135,6 +251,9 @@ func (s *Storage) GetPasswordHash(ctx context.Context, login string) (string, er
 	row := s.pool.QueryRow(ctx, `SELECT password FROM users WHERE login = $1;`, login)
 	err := row.Scan(&userPassword)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
 		return "", err
 	}
 
@@ -161,7 +280,7 @@ func (s *Storage) CreateOrder(ctx context.Context, number string, login string)
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
 			return ErrCreatedByOtherUser
 		}
-		logger.Log.Sugar().Errorf("Не удалось создать заказ: %s", err)
+		logger.FromContext(ctx).Error("Не удалось создать заказ", zap.Error(err))
 		return ErrCreateUser
 	}
 
@@ -179,7 +298,7 @@ func (s *Storage) GetRegisteresOrders(ctx context.Context) ([]string, error) {
 	var orders []string
 	rows, err := s.pool.Query(ctx, `SELECT number FROM orders WHERE status in ($1);`, noFinalStatuses)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Не удалось выполнить запрос: %s", err)
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
 		return nil, ErrRegisteresOrders
 	}
 	defer rows.Close()
@@ -187,54 +306,154 @@ func (s *Storage) GetRegisteresOrders(ctx context.Context) ([]string, error) {
 	for rows.Next() {
 		var number string
 		if err := rows.Scan(&number); err != nil {
-			logger.Log.Sugar().Errorf("Ошибка при сканировании строки: %s", err)
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
 			return nil, ErrScanRows
 		}
 		orders = append(orders, number)
 	}
 
 	if err = rows.Err(); err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при итерации по строкам: %s", err)
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
 		return nil, ErrRows
 	}
 
 	return orders, nil
 }
 
-// в одной транзакции обновляет заказ и начисляет баллы
-func (s *Storage) UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) error {
+// считает заказы с неконечными статусами в разрезе статуса
+func (s *Storage) CountPendingOrders(ctx context.Context) (map[string]int64, error) {
+
+	counts := make(map[string]int64, len(noFinalStatuses))
+	for _, status := range noFinalStatuses {
+		counts[status] = 0
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT status, COUNT(*) FROM orders WHERE status = ANY($1) GROUP BY status;`, noFinalStatuses)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		counts[status] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	return counts, nil
+}
+
+// статистика пула соединений для метрик
+func (s *Storage) PoolStat() *pgxpool.Stat {
+	return s.pool.Stat()
+}
+
+// закрывает пул и соединение для миграций; пул ждёт возврата всех выданных соединений
+func (s *Storage) Close() error {
+	s.pool.Close()
+	return s.db.Close()
+}
+
+// проверяет, что пул может выдать живое соединение
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// сравнивает версию схемы БД с последней миграцией, найденной в Init
+func (s *Storage) CheckMigrations(ctx context.Context) error {
+	current, err := goose.GetDBVersion(s.db)
+	if err != nil {
+		return err
+	}
+	if current < s.latestMigration {
+		return fmt.Errorf("%w: database version %d, latest %d", ErrMigrationsPending, current, s.latestMigration)
+	}
+	return nil
+}
+
+// в одной транзакции обновляет заказ и начисляет баллы. Возвращает начисленную сумму с учётом множителя уровня
+func (s *Storage) UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) (float64, error) {
 	// Начало транзакции
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при начале транзакции: %s", err)
-		return ErrBeginTransaction
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return 0, ErrBeginTransaction
 	}
 
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(ctx); rbErr != nil {
-				logger.Log.Sugar().Errorf("Ошибка при откате транзакции: %s", rbErr)
+				logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
 			}
 		}
 	}()
 
 	var userLogin string
+	var credited float64
 
 	if orderData.Status == "PROCESSED" {
-		// Обновляем заказ и получаем user_login
+		// сначала блокируем заказ, затем баланс покупателя и, если за заказ положена реферальная награда,
+		// баланс пригласившего: тот же порядок, что при сверке и передаче заказа по спору, иначе возможна
+		// взаимная блокировка, а владелец заказа может смениться между чтением и начислением
+		var referrer *string
 		err = tx.QueryRow(ctx, `
-            UPDATE orders SET status = $1, accrual = $2 WHERE number = $3 RETURNING user_login;
-        `, orderData.Status, orderData.Accrual, orderData.Order).Scan(&userLogin)
+            SELECT o.user_login, u.referred_by FROM orders o JOIN users u ON u.login = o.user_login WHERE o.number = $1 FOR UPDATE OF o;
+        `, orderData.Order).Scan(&userLogin, &referrer)
 		if err != nil {
-			return ErrUpdate
+			return 0, ErrUpdate
+		}
+		rewardReferral := referrer != nil && s.referral.Enabled()
+		locked := []string{userLogin}
+		if rewardReferral {
+			locked = append(locked, *referrer)
+		}
+		if _, err = lockBalances(ctx, tx, locked...); err != nil {
+			return 0, ErrUpdate
+		}
+
+		// Обновляем заказ, фиксируя множитель текущего уровня пользователя, и получаем user_login.
+		// В accrual остаётся значение системы лояльности: по нему идут сверка и расчёт уровня.
+		err = tx.QueryRow(ctx, `
+            UPDATE orders SET status = $1, accrual = $2, processed_at = NOW(),
+                multiplier = COALESCE((
+                    SELECT t.multiplier FROM users u JOIN loyalty_tiers t ON t.name = u.tier WHERE u.login = orders.user_login
+                ), 1)
+            WHERE number = $3 RETURNING user_login, COALESCE(ROUND(accrual * multiplier, 2), 0);
+        `, orderData.Status, orderData.Accrual, orderData.Order).Scan(&userLogin, &credited)
+		if err != nil {
+			return 0, ErrUpdate
 		}
 
 		// Используем полученный user_login для обновления баланса пользователя
 		_, err = tx.Exec(ctx, `
             UPDATE user_balance SET current = current + $1 WHERE user_login = $2;
-        `, orderData.Accrual, userLogin)
+        `, credited, userLogin)
 		if err != nil {
-			return ErrUpdate
+			return 0, ErrUpdate
+		}
+
+		// начисление становится отдельной партией со своим сроком сгорания
+		if credited > 0 {
+			err = s.addPointLot(ctx, tx, userLogin, models.PointLotAccrual, orderData.Order, credited)
+			if err != nil {
+				return 0, ErrUpdate
+			}
+		}
+
+		if rewardReferral {
+			if err = s.rewardReferral(ctx, tx, *referrer, userLogin, orderData.Order); err != nil {
+				return 0, ErrUpdate
+			}
 		}
 	} else if orderData.Status == "INVALID" || orderData.Status == "PROCESSING" {
 		// Обновляем статус заказа без начисления баллов
@@ -242,26 +461,26 @@ func (s *Storage) UpdateOrderAndAccrualPoints(ctx context.Context, orderData *lo
             UPDATE orders SET status = $1 WHERE number = $2;
         `, orderData.Status, orderData.Order)
 		if err != nil {
-			return ErrUpdate
+			return 0, ErrUpdate
 		}
 	}
 	// Другие статусы не обрабатываем
 
 	if commitErr := tx.Commit(ctx); commitErr != nil {
-		logger.Log.Sugar().Errorf("Ошибка при фиксации транзакции: %s", commitErr)
-		return commitErr
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(commitErr))
+		return 0, commitErr
 	}
 
-	return nil
+	return credited, nil
 }
 
 // получает заказов пользователя
 func (s *Storage) GetUserOrders(ctx context.Context, userLogin string) ([]models.Order, error) {
 
 	var orders []models.Order
-	rows, err := s.pool.Query(ctx, `SELECT number, status, accrual, uploaded_at FROM orders WHERE user_login = $1 ORDER BY uploaded_at desc;`, userLogin)
+	rows, err := s.pool.Query(ctx, `SELECT number, status, ROUND(accrual * multiplier, 2), uploaded_at FROM orders WHERE user_login = $1 ORDER BY uploaded_at desc;`, userLogin)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Не удалось выполнить запрос: %s", err)
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
 		return nil, ErrSelect
 	}
 	defer rows.Close()
@@ -269,14 +488,14 @@ func (s *Storage) GetUserOrders(ctx context.Context, userLogin string) ([]models
 	for rows.Next() {
 		var order models.Order
 		if err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt); err != nil {
-			logger.Log.Sugar().Errorf("Ошибка при сканировании строки: %s", err)
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
 			return nil, ErrScanRows
 		}
 		orders = append(orders, order)
 	}
 
 	if err = rows.Err(); err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при итерации по строкам: %s", err)
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
 		return nil, ErrRows
 	}
 
@@ -290,89 +509,201 @@ func (s *Storage) GetUserOrders(ctx context.Context, userLogin string) ([]models
 // получает баланс пользователя
 func (s *Storage) GetUserBalance(ctx context.Context, userLogin string) (models.Balance, error) {
 	var userBalance models.Balance
-	row := s.pool.QueryRow(ctx, `SELECT current, withdrawn FROM user_balance WHERE user_login = $1;`, userLogin)
+	row := s.pool.QueryRow(ctx, `SELECT current, held, withdrawn FROM user_balance WHERE user_login = $1;`, userLogin)
 
-	err := row.Scan(&userBalance.Current, &userBalance.Withdrawn)
+	err := row.Scan(&userBalance.Current, &userBalance.Held, &userBalance.Withdrawn)
 	if err != nil {
 		// Другая ошибка выполнения запроса
-		logger.Log.Sugar().Errorf("Не удалось выполнить запрос: %s", err)
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
 		return models.Balance{}, err
 	}
 
 	return userBalance, nil
 }
 
-// в рамках транзакции списание баллов с баланса и создании записи об этом
-func (s *Storage) Withdrow(ctx context.Context, sum float64, userLogin string, order string) error {
+// в рамках транзакции списание баллов с баланса и создании записи об этом.
+// Заказ оплачивается одним списанием, если только и первое, и следующие списания по нему не частичные.
+func (s *Storage) Withdrow(ctx context.Context, sum float64, userLogin string, order string, partial bool, limits models.WithdrawalLimits) error {
 
 	// Начало транзакции
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при начале транзакции: %s", err)
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
 		return ErrBeginTransaction
 	}
 
 	defer func() {
-		if err != nil {
-			logger.Log.Sugar().Errorf("Ошибка: %s", err)
-			if rbErr := tx.Rollback(ctx); rbErr != nil {
-				logger.Log.Sugar().Errorf("Ошибка при откате транзакции: %s", rbErr)
-			}
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
 		}
 	}()
 
+	// блокируем баланс: параллельные списания и сгорание баллов пользователя выполняются по очереди
+	if _, err = lockBalances(ctx, tx, userLogin); err != nil {
+		return ErrUpdate
+	}
+
+	if err = s.checkWithdrawalLimits(ctx, tx, userLogin, sum, limits); err != nil {
+		return err
+	}
+
+	var owner string
+	var existingPartial, held bool
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(w.user_login, ''), COALESCE(w.partial, FALSE), EXISTS (SELECT 1 FROM holds WHERE "order" = $1 AND status = $2)
+		FROM (SELECT 1) AS one LEFT JOIN withdrawals w ON w."order" = $1;
+	`, order, models.HoldActive).Scan(&owner, &existingPartial, &held)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return ErrSelect
+	}
+	exists := owner != ""
+	if held || (exists && !(partial && existingPartial && owner == userLogin)) {
+		return ErrOrderPaid
+	}
+
+	// просроченные партии сгорают до списания, чтобы их нельзя было потратить
+	if _, err = s.expireUserLots(ctx, tx, userLogin); err != nil {
+		return ErrUpdate
+	}
+
 	_, err = tx.Exec(ctx, `
 		UPDATE user_balance SET current = current - $1, withdrawn = withdrawn + $2 WHERE user_login = $3;
     `, sum, sum, userLogin)
 	if err != nil {
 		var pgErr *pgconn.PgError
-		if ok := errors.As(err, &pgErr); ok {
-			// Проверка кода ошибки на соответствие коду нарушения ограничения CHECK
-			if pgErr.Code == "23514" {
-				logger.Log.Sugar().Errorf("невозможно выполнить операцию: недостаточно средств на балансе: %s", err)
-				return ErrFewPoints
-			}
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "current_positive" {
+			logger.FromContext(ctx).Error("невозможно выполнить операцию: недостаточно средств на балансе", zap.Error(err))
+			return ErrFewPoints
 		}
 		return ErrUpdate
 	}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO withdrawals ("order", sum, user_login) VALUES ($1, $2, $3) ;
-    `, order, sum, userLogin)
+	if err = s.consumePointLots(ctx, tx, userLogin, sum); err != nil {
+		return ErrUpdate
+	}
+
+	if exists {
+		// доплата по заказу: часть суммы заказа могла быть уже возвращена
+		_, err = tx.Exec(ctx, `
+			UPDATE withdrawals SET sum = sum + $1, status = CASE WHEN refunded > 0 THEN $2 ELSE status END WHERE "order" = $3;
+		`, sum, models.WithdrawalPartiallyRefunded, order)
+	} else {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO withdrawals ("order", sum, user_login, partial) VALUES ($1, $2, $3, $4);
+		`, order, sum, userLogin, partial)
+	}
 	if err != nil {
+		var pgErr *pgconn.PgError
+		// заказ успели оплатить параллельным запросом
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return ErrOrderPaid
+		}
+		logger.FromContext(ctx).Error("Не удалось записать списание", zap.Error(err))
+		return ErrUpdate
+	}
+
+	if err = addWithdrawalPayment(ctx, tx, userLogin, order, sum); err != nil {
 		return ErrUpdate
 	}
 
 	if commitErr := tx.Commit(ctx); commitErr != nil {
-		logger.Log.Sugar().Errorf("Ошибка при фиксации транзакции: %s", commitErr)
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(commitErr))
 		return commitErr
 	}
 
 	return nil
 }
 
+// проверяет ограничения на списание под блокировкой баланса пользователя, чтобы суточный лимит
+// нельзя было обойти параллельными запросами. Активные резервы учитываются как уже списанные.
+func (s *Storage) checkWithdrawalLimits(ctx context.Context, tx pgx.Tx, userLogin string, sum float64, limits models.WithdrawalLimits) error {
+	if limits.Max > 0 && exceedsLimit(0, sum, limits.Max) {
+		return fmt.Errorf("%w: at most %.2f points at once", ErrWithdrawalTooLarge, limits.Max)
+	}
+	if limits.Daily <= 0 {
+		return nil
+	}
+
+	var spent float64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE((SELECT SUM(sum) FROM withdrawal_payments WHERE user_login = $1 AND created_at > NOW() - INTERVAL '1 day'), 0)
+			+ COALESCE((SELECT SUM(sum) FROM holds WHERE user_login = $1 AND status = $2), 0);
+	`, userLogin, models.HoldActive).Scan(&spent)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return ErrSelect
+	}
+	if exceedsLimit(spent, sum, limits.Daily) {
+		return fmt.Errorf("%w: %.2f of %.2f points left for today", ErrWithdrawalDaily, math.Max(limits.Daily-spent, 0), limits.Daily)
+	}
+	return nil
+}
+
+// баллы хранятся в NUMERIC с точностью до копеек, поэтому суммы в float64 сравниваются в целых копейках
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// проверяет, выйдет ли операция на sum за лимит с учётом уже израсходованного spent
+func exceedsLimit(spent, sum, limit float64) bool {
+	return toCents(spent)+toCents(sum) > toCents(limit)
+}
+
+// определяет сумму возврата по списанию; без запрошенной суммы возвращается весь остаток
+func refundAmount(sum, refunded float64, requested *float64) (float64, error) {
+	remaining := toCents(sum) - toCents(refunded)
+	if remaining <= 0 {
+		return 0, ErrAlreadyRefunded
+	}
+
+	amount := remaining
+	if requested != nil {
+		amount = toCents(*requested)
+	}
+	if amount > remaining {
+		return 0, ErrRefundExceedsSum
+	}
+	return fromCents(amount), nil
+}
+
+// записывает отдельное списание по заказу; по этим записям считается суточный лимит
+func addWithdrawalPayment(ctx context.Context, tx pgx.Tx, userLogin string, order string, sum float64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO withdrawal_payments ("order", user_login, sum) VALUES ($1, $2, $3);
+	`, order, userLogin, sum)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать списание по заказу", zap.Error(err))
+	}
+	return err
+}
+
 // получает инфо о выводах средств
 func (s *Storage) GetUserWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawn, error) {
 
 	var withdrawals []models.Withdrawn
-	rows, err := s.pool.Query(ctx, `SELECT "order", sum, proccesed_at FROM withdrawals WHERE user_login = $1 ORDER BY proccesed_at desc;`, userLogin)
+	rows, err := s.pool.Query(ctx, `SELECT "order", sum, proccesed_at, status, refunded FROM withdrawals WHERE user_login = $1 ORDER BY proccesed_at desc;`, userLogin)
 	if err != nil {
-		logger.Log.Sugar().Errorf("Не удалось выполнить запрос: %s", err)
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
 		return nil, ErrSelect
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var withdraw models.Withdrawn
-		if err := rows.Scan(&withdraw.Order, &withdraw.Sum, &withdraw.ProccesedAt); err != nil {
-			logger.Log.Sugar().Errorf("Ошибка при сканировании строки: %s", err)
+		if err := rows.Scan(&withdraw.Order, &withdraw.Sum, &withdraw.ProccesedAt, &withdraw.Status, &withdraw.Refunded); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
 			return nil, ErrScanRows
 		}
 		withdrawals = append(withdrawals, withdraw)
 	}
 
 	if err = rows.Err(); err != nil {
-		logger.Log.Sugar().Errorf("Ошибка при итерации по строкам: %s", err)
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
 		return nil, ErrRows
 	}
 
@@ -382,3 +713,1872 @@ func (s *Storage) GetUserWithdrawals(ctx context.Context, userLogin string) ([]m
 
 	return withdrawals, nil
 }
+
+// в рамках транзакции возвращает баллы за списание: полностью, если sum не указан, или частично
+func (s *Storage) RefundWithdrawal(ctx context.Context, order string, sum *float64, reason string) (models.Withdrawn, error) {
+	var withdraw models.Withdrawn
+
+	// Начало транзакции
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return withdraw, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	var userLogin string
+	err = tx.QueryRow(ctx, `SELECT user_login FROM withdrawals WHERE "order" = $1;`, order).Scan(&userLogin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return withdraw, ErrWithdrawalNotFound
+		}
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return withdraw, ErrSelect
+	}
+
+	// тот же порядок блокировок, что и при списании: сначала баланс, затем списание,
+	// иначе возврат и доплата по тому же заказу могут заблокировать друг друга
+	if _, err = lockBalances(ctx, tx, userLogin); err != nil {
+		return withdraw, ErrUpdate
+	}
+
+	// блокируем списание, чтобы параллельные возвраты не превысили его сумму
+	var lockedLogin string
+	err = tx.QueryRow(ctx, `
+		SELECT "order", sum, proccesed_at, status, refunded, user_login FROM withdrawals WHERE "order" = $1 FOR UPDATE;
+	`, order).Scan(&withdraw.Order, &withdraw.Sum, &withdraw.ProccesedAt, &withdraw.Status, &withdraw.Refunded, &lockedLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return withdraw, ErrSelect
+	}
+	// логин мог смениться при обезличивании аккаунта между чтением и блокировкой
+	if lockedLogin != userLogin {
+		logger.FromContext(ctx).Error("Владелец списания сменился во время возврата", zap.String("order", order))
+		return withdraw, ErrUpdate
+	}
+
+	if withdraw.Status == models.WithdrawalRefunded {
+		return withdraw, ErrAlreadyRefunded
+	}
+	amount, err := refundAmount(withdraw.Sum, withdraw.Refunded, sum)
+	if err != nil {
+		return withdraw, err
+	}
+
+	// полный возврат определяется сравнением в NUMERIC, а не в float64
+	err = tx.QueryRow(ctx, `
+		UPDATE withdrawals SET refunded = refunded + $1::numeric,
+			status = CASE WHEN refunded + $1::numeric >= sum THEN $2 ELSE $3 END
+		WHERE "order" = $4 AND refunded + $1::numeric <= sum RETURNING refunded, status;
+	`, amount, models.WithdrawalRefunded, models.WithdrawalPartiallyRefunded, order).Scan(&withdraw.Refunded, &withdraw.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return withdraw, ErrRefundExceedsSum
+		}
+		return withdraw, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_balance SET current = current + $1, withdrawn = withdrawn - $2 WHERE user_login = $3;
+	`, amount, amount, userLogin)
+	if err != nil {
+		return withdraw, ErrUpdate
+	}
+
+	// возвращённые баллы получают новый срок сгорания
+	err = s.addPointLot(ctx, tx, userLogin, models.PointLotRefund, order, amount)
+	if err != nil {
+		return withdraw, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO withdrawal_refunds ("order", sum, reason) VALUES ($1, $2, $3);
+	`, order, amount, reason)
+	if err != nil {
+		return withdraw, ErrUpdate
+	}
+
+	if commitErr := tx.Commit(ctx); commitErr != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(commitErr))
+		return withdraw, ErrCommit
+	}
+
+	return withdraw, nil
+}
+
+// получает обработанные заказы, загруженные не раньше since, для сверки с системой лояльности
+func (s *Storage) GetProcessedOrdersSince(ctx context.Context, since time.Time) ([]models.ProcessedOrder, error) {
+
+	var orders []models.ProcessedOrder
+	rows, err := s.pool.Query(ctx, `
+		SELECT number, COALESCE(accrual, 0) FROM orders WHERE status = $1 AND uploaded_at >= $2 ORDER BY uploaded_at;
+	`, loyalty.StatusProcessed, since)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order models.ProcessedOrder
+		if err := rows.Scan(&order.Number, &order.Accrual); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	return orders, nil
+}
+
+// в одной транзакции записывает новое начисление по заказу и корректирует баланс на разницу.
+// Возвращает nil, если начисление не изменилось или такая корректировка уже ждёт разбора.
+func (s *Storage) AdjustOrderAccrual(ctx context.Context, order string, newAccrual float64, policy models.NegativeBalancePolicy) (*models.AccrualAdjustment, error) {
+	// Начало транзакции
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return nil, ErrBeginTransaction
+	}
+
+	// откатываем транзакцию при любом выходе без фиксации, в том числе когда менять нечего
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	adjustment := models.AccrualAdjustment{Order: order, NewAccrual: newAccrual}
+	var multiplier float64
+	err = tx.QueryRow(ctx, `
+		SELECT user_login, COALESCE(accrual, 0), multiplier FROM orders WHERE number = $1 FOR UPDATE;
+	`, order).Scan(&adjustment.UserLogin, &adjustment.PreviousAccrual, &multiplier)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+
+	adjustment.Delta = newAccrual - adjustment.PreviousAccrual
+	if adjustment.Delta == 0 {
+		return nil, nil
+	}
+
+	var current float64
+	err = tx.QueryRow(ctx, `
+		SELECT current FROM user_balance WHERE user_login = $1 FOR UPDATE;
+	`, adjustment.UserLogin).Scan(&current)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+
+	// отзыв начисления не может забрать уже сгоревшие баллы
+	expired, err := s.expireUserLots(ctx, tx, adjustment.UserLogin)
+	if err != nil {
+		return nil, ErrUpdate
+	}
+	current -= expired
+
+	// разница пересчитывается с тем же множителем уровня, с которым баллы были начислены
+	credited := math.Round(adjustment.Delta*multiplier*100) / 100
+	adjustment.Applied = credited
+	adjustment.Status = models.AdjustmentApplied
+	if current+credited < 0 {
+		switch policy {
+		case models.NegativeBalanceHold:
+			var alreadyHeld bool
+			err = tx.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM accrual_adjustments WHERE "order" = $1 AND status = $2 AND new_accrual = $3);
+			`, order, models.AdjustmentHeld, newAccrual).Scan(&alreadyHeld)
+			if err != nil {
+				logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+				return nil, ErrSelect
+			}
+			if alreadyHeld {
+				return nil, nil
+			}
+			adjustment.Applied = 0
+			adjustment.Status = models.AdjustmentHeld
+		default:
+			adjustment.Applied = -current
+			adjustment.Status = models.AdjustmentClamped
+		}
+	}
+
+	if adjustment.Status != models.AdjustmentHeld {
+		_, err = tx.Exec(ctx, `
+			UPDATE orders SET accrual = $1, updated_at = NOW() WHERE number = $2;
+		`, newAccrual, order)
+		if err != nil {
+			return nil, ErrUpdate
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE user_balance SET current = current + $1 WHERE user_login = $2;
+		`, adjustment.Applied, adjustment.UserLogin)
+		if err != nil {
+			return nil, ErrUpdate
+		}
+
+		switch {
+		case adjustment.Applied > 0:
+			err = s.addPointLot(ctx, tx, adjustment.UserLogin, models.PointLotAdjustment, order, adjustment.Applied)
+		case adjustment.Applied < 0:
+			err = s.consumePointLots(ctx, tx, adjustment.UserLogin, -adjustment.Applied)
+		}
+		if err != nil {
+			return nil, ErrUpdate
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO accrual_adjustments ("order", user_login, previous_accrual, new_accrual, delta, applied, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at;
+	`, order, adjustment.UserLogin, adjustment.PreviousAccrual, newAccrual, adjustment.Delta, adjustment.Applied, adjustment.Status).Scan(&adjustment.CreatedAt)
+	if err != nil {
+		return nil, ErrUpdate
+	}
+
+	if commitErr := tx.Commit(ctx); commitErr != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(commitErr))
+		return nil, ErrCommit
+	}
+
+	return &adjustment, nil
+}
+
+// получает корректировки начислений, созданные не раньше since
+func (s *Storage) GetAccrualAdjustments(ctx context.Context, since time.Time) ([]models.AccrualAdjustment, error) {
+
+	var adjustments []models.AccrualAdjustment
+	rows, err := s.pool.Query(ctx, `
+		SELECT "order", user_login, previous_accrual, new_accrual, delta, applied, status, created_at
+		FROM accrual_adjustments WHERE created_at >= $1 ORDER BY created_at desc;
+	`, since)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.AccrualAdjustment
+		if err := rows.Scan(&a.Order, &a.UserLogin, &a.PreviousAccrual, &a.NewAccrual, &a.Delta, &a.Applied, &a.Status, &a.CreatedAt); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	if len(adjustments) == 0 {
+		return nil, ErrAdjustmentsNotFound
+	}
+
+	return adjustments, nil
+}
+
+// резервирует ключ идемпотентности за пользователем.
+// Если ключ свободен или истёк, возвращает nil. Иначе возвращает ранее сохранённую запись.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, userLogin string, key string, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	var reserved bool
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (user_login, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		ON CONFLICT (user_login, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, content_type = NULL, body = NULL,
+			created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+		RETURNING true;
+	`, userLogin, key, fingerprint, ttl.Seconds()).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logger.FromContext(ctx).Error("Не удалось зарезервировать ключ идемпотентности", zap.Error(err))
+		return nil, ErrIdempotencyKey
+	}
+
+	// ключ уже занят действующей записью
+	var record models.IdempotencyRecord
+	var statusCode *int
+	var contentType *string
+	err = s.pool.QueryRow(ctx, `
+		SELECT fingerprint, status_code, content_type, body FROM idempotency_keys WHERE user_login = $1 AND key = $2;
+	`, userLogin, key).Scan(&record.Fingerprint, &statusCode, &contentType, &record.Body)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось получить ключ идемпотентности", zap.Error(err))
+		return nil, ErrIdempotencyKey
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	if contentType != nil {
+		record.ContentType = *contentType
+	}
+
+	return &record, nil
+}
+
+// сохраняет ответ на запрос с ключом идемпотентности
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, userLogin string, key string, record models.IdempotencyRecord) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE idempotency_keys SET status_code = $1, content_type = $2, body = $3 WHERE user_login = $4 AND key = $5;
+	`, record.StatusCode, record.ContentType, record.Body, userLogin, key)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось сохранить ответ для ключа идемпотентности", zap.Error(err))
+		return ErrIdempotencyKey
+	}
+
+	return nil
+}
+
+// освобождает ключ идемпотентности, чтобы клиент мог повторить запрос
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, userLogin string, key string) error {
+	_, err := s.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys WHERE user_login = $1 AND key = $2 AND status_code IS NULL;
+	`, userLogin, key)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось освободить ключ идемпотентности", zap.Error(err))
+		return ErrIdempotencyKey
+	}
+
+	return nil
+}
+
+// удаляет истёкшие ключи идемпотентности
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW();`)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось удалить истёкшие ключи идемпотентности", zap.Error(err))
+		return 0, ErrIdempotencyKey
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// добавляет партию баллов; срок сгорания отсчитывается от момента начисления
+func (s *Storage) addPointLot(ctx context.Context, tx pgx.Tx, userLogin string, source models.PointLotSource, order string, amount float64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO point_lots (user_login, source, "order", amount, remaining, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $4, CASE WHEN $5 > 0 THEN NOW() + make_interval(months => $5) END);
+	`, userLogin, source, order, amount, s.pointsExpiryMonths)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось добавить партию баллов", zap.Error(err))
+	}
+	return err
+}
+
+// списывает sum с партий пользователя, начиная с самых старых.
+// Партии меняются только под блокировкой строки баланса, поэтому параллельные списания не расходуют одну партию дважды.
+func (s *Storage) consumePointLots(ctx context.Context, tx pgx.Tx, userLogin string, sum float64) error {
+	var shortfall float64
+	err := tx.QueryRow(ctx, `
+		WITH ordered AS (
+			SELECT id, remaining, SUM(remaining) OVER (ORDER BY accrued_at, id) - remaining AS before
+			FROM point_lots WHERE user_login = $1 AND remaining > 0
+		), consumed AS (
+			UPDATE point_lots p SET remaining = p.remaining - LEAST(o.remaining, $2::numeric - o.before)
+			FROM ordered o
+			WHERE p.id = o.id AND o.before < $2::numeric
+			RETURNING LEAST(o.remaining, $2::numeric - o.before) AS taken
+		)
+		SELECT $2::numeric - COALESCE(SUM(taken), 0) FROM consumed;
+	`, userLogin, sum).Scan(&shortfall)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось списать баллы с партий", zap.Error(err))
+		return err
+	}
+	return lotShortfall(ctx, "consume", shortfall)
+}
+
+// баланс проверен ограничением таблицы, поэтому нехватка партий значит, что они разошлись с балансом.
+// Операция откатывается: зафиксированное расхождение незаметно испортило бы сгорание и историю баланса.
+func lotShortfall(ctx context.Context, operation string, shortfall float64) error {
+	if shortfall <= 0 {
+		return nil
+	}
+	metrics.PointLotShortfalls.WithLabelValues(operation).Inc()
+	logger.FromContext(ctx).Error("партий не хватило на операцию",
+		zap.String("operation", operation),
+		zap.Float64("shortfall", shortfall),
+	)
+	return fmt.Errorf("%w: %s is short of %.2f points", ErrLotsShortfall, operation, shortfall)
+}
+
+// сжигает просроченные партии пользователя, записывает их в историю и уменьшает баланс.
+// Вызывается под блокировкой строки баланса; возвращает сгоревшую сумму.
+func (s *Storage) expireUserLots(ctx context.Context, tx pgx.Tx, userLogin string) (float64, error) {
+	var lots int
+	var expired float64
+	err := tx.QueryRow(ctx, `
+		WITH due AS (
+			SELECT id, remaining FROM point_lots
+			WHERE user_login = $1 AND remaining > 0 AND expires_at <= NOW()
+		), expired AS (
+			UPDATE point_lots p SET remaining = 0 FROM due WHERE p.id = due.id
+			RETURNING p.id, due.remaining AS sum
+		), history AS (
+			INSERT INTO point_expirations (lot_id, user_login, sum) SELECT id, $1, sum FROM expired
+		)
+		SELECT COUNT(*), COALESCE(SUM(sum), 0) FROM expired;
+	`, userLogin).Scan(&lots, &expired)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось сжечь просроченные баллы", zap.Error(err))
+		return 0, err
+	}
+	if lots == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_balance SET current = GREATEST(current - $1, 0) WHERE user_login = $2;
+	`, expired, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось уменьшить баланс на сгоревшие баллы", zap.Error(err))
+		return 0, err
+	}
+	return expired, nil
+}
+
+// сжигает просроченные партии всех пользователей; каждый пользователь обрабатывается в своей транзакции
+func (s *Storage) ExpirePoints(ctx context.Context) (models.ExpirationReport, error) {
+	var report models.ExpirationReport
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT user_login FROM point_lots WHERE remaining > 0 AND expires_at <= NOW();
+	`)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return report, ErrSelect
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var userLogin string
+		if err := rows.Scan(&userLogin); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return report, ErrScanRows
+		}
+		users = append(users, userLogin)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return report, ErrRows
+	}
+	// освобождаем соединение до транзакций по пользователям
+	rows.Close()
+
+	var errs []error
+	for _, userLogin := range users {
+		expired, err := s.expireUserPoints(ctx, userLogin)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", userLogin, err))
+			continue
+		}
+		if expired > 0 {
+			report.Users++
+			report.Points += expired
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
+
+func (s *Storage) expireUserPoints(ctx context.Context, userLogin string) (float64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return 0, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	// тот же порядок блокировок, что и при списании: сначала баланс, затем партии
+	_, err = tx.Exec(ctx, `SELECT 1 FROM user_balance WHERE user_login = $1 FOR UPDATE;`, userLogin)
+	if err != nil {
+		return 0, ErrUpdate
+	}
+
+	expired, err := s.expireUserLots(ctx, tx, userLogin)
+	if err != nil {
+		return 0, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return 0, ErrCommit
+	}
+
+	return expired, nil
+}
+
+// получает баллы пользователя, которые сгорят до before, по дням сгорания
+func (s *Storage) GetExpiringPoints(ctx context.Context, userLogin string, before time.Time) ([]models.ExpiringPoints, error) {
+
+	var expiring []models.ExpiringPoints
+	rows, err := s.pool.Query(ctx, `
+		SELECT SUM(remaining), MIN(expires_at) FROM point_lots
+		WHERE user_login = $1 AND remaining > 0 AND expires_at > NOW() AND expires_at <= $2
+		GROUP BY date_trunc('day', expires_at AT TIME ZONE 'UTC')
+		ORDER BY MIN(expires_at);
+	`, userLogin, before)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var points models.ExpiringPoints
+		if err := rows.Scan(&points.Sum, &points.ExpiresAt); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		expiring = append(expiring, points)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	return expiring, nil
+}
+
+// пересчитывает уровни всех пользователей по начислениям системы лояльности за окно расчёта.
+// Множители в сумму не входят, иначе уровень подпитывал бы сам себя. Возвращает число пользователей со сменившимся уровнем.
+func (s *Storage) RecomputeTiers(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH qualifying AS (
+			SELECT u.login, COALESCE(SUM(o.accrual), 0) AS total
+			FROM users u
+			LEFT JOIN orders o ON o.user_login = u.login AND o.status = $1
+				AND o.processed_at >= NOW() - make_interval(months => $2)
+			GROUP BY u.login
+		), target AS (
+			SELECT q.login, COALESCE(
+				(SELECT name FROM loyalty_tiers WHERE min_accrual <= q.total ORDER BY min_accrual DESC LIMIT 1),
+				(SELECT name FROM loyalty_tiers ORDER BY min_accrual LIMIT 1)
+			) AS tier
+			FROM qualifying q
+		)
+		UPDATE users u SET tier = target.tier, tier_updated_at = NOW()
+		FROM target
+		WHERE u.login = target.login AND u.tier <> target.tier;
+	`, loyalty.StatusProcessed, s.tierWindowMonths)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось пересчитать уровни пользователей", zap.Error(err))
+		return 0, ErrUpdate
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// получает профиль пользователя: текущий уровень, его множитель и прогресс до следующего уровня
+func (s *Storage) GetUserProfile(ctx context.Context, userLogin string) (models.Profile, error) {
+	profile := models.Profile{Login: userLogin}
+
+	var nextName *string
+	var nextMin *float64
+	err := s.pool.QueryRow(ctx, `
+		WITH qualifying AS (
+			SELECT COALESCE(SUM(accrual), 0) AS total FROM orders
+			WHERE user_login = $1 AND status = $2 AND processed_at >= NOW() - make_interval(months => $3)
+		)
+		SELECT u.tier, t.multiplier, q.total, n.name, n.min_accrual, u.referral_code, COALESCE(u.referred_by, ''), u.deletion_due_at
+		FROM users u
+		JOIN loyalty_tiers t ON t.name = u.tier
+		CROSS JOIN qualifying q
+		LEFT JOIN LATERAL (
+			SELECT name, min_accrual FROM loyalty_tiers WHERE min_accrual > t.min_accrual ORDER BY min_accrual LIMIT 1
+		) n ON true
+		WHERE u.login = $1;
+	`, userLogin, loyalty.StatusProcessed, s.tierWindowMonths).Scan(
+		&profile.Tier.Name, &profile.Tier.Multiplier, &profile.Tier.QualifyingAccrual, &nextName, &nextMin,
+		&profile.ReferralCode, &profile.ReferredBy, &profile.DeletionScheduledAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return profile, ErrUserNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return profile, ErrSelect
+	}
+
+	if nextName != nil && nextMin != nil {
+		profile.Tier.Next = &models.NextTier{
+			Name:       *nextName,
+			MinAccrual: *nextMin,
+			Remaining:  math.Max(*nextMin-profile.Tier.QualifyingAccrual, 0),
+		}
+	}
+
+	return profile, nil
+}
+
+// переводит баллы другому пользователю: списание и зачисление выполняются в одной транзакции
+func (s *Storage) Transfer(ctx context.Context, sender string, recipient string, sum float64, comment string, limits models.TransferLimits) (models.Transfer, error) {
+	transfer := models.Transfer{Direction: models.TransferOutgoing, Counterparty: recipient, Sum: sum, Comment: comment}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return transfer, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	missing, err := lockBalances(ctx, tx, sender, recipient)
+	if errors.Is(err, pgx.ErrNoRows) && missing == recipient {
+		return transfer, ErrRecipientNotFound
+	}
+	if err != nil {
+		return transfer, ErrUpdate
+	}
+
+	// под блокировкой баланса отправителя его переводы идут по очереди, и лимит нельзя обойти параллельными запросами
+	var sentSum float64
+	var sentCount int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(sum), 0), COUNT(*) FROM transfers WHERE sender_login = $1 AND created_at > NOW() - INTERVAL '1 day';
+	`, sender).Scan(&sentSum, &sentCount)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return transfer, ErrSelect
+	}
+	if limits.Count > 0 && sentCount >= limits.Count {
+		return transfer, fmt.Errorf("%w: at most %d transfers per day", ErrTransferLimit, limits.Count)
+	}
+	if limits.Sum > 0 && exceedsLimit(sentSum, sum, limits.Sum) {
+		return transfer, fmt.Errorf("%w: %.2f of %.2f points left for today", ErrTransferLimit, math.Max(limits.Sum-sentSum, 0), limits.Sum)
+	}
+
+	// просроченные партии сгорают до списания, чтобы их нельзя было передать
+	if _, err = s.expireUserLots(ctx, tx, sender); err != nil {
+		return transfer, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current - $1 WHERE user_login = $2;`, sum, sender)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "current_positive" {
+			return transfer, ErrFewPoints
+		}
+		logger.FromContext(ctx).Error("Не удалось списать баллы отправителя", zap.Error(err))
+		return transfer, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current + $1 WHERE user_login = $2;`, sum, recipient)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось зачислить баллы получателю", zap.Error(err))
+		return transfer, ErrUpdate
+	}
+
+	if err = s.transferPointLots(ctx, tx, sender, recipient, sum); err != nil {
+		return transfer, ErrUpdate
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transfers (sender_login, recipient_login, sum, comment) VALUES ($1, $2, $3, $4) RETURNING id, created_at;
+	`, sender, recipient, sum, comment).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать перевод", zap.Error(err))
+		return transfer, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return transfer, ErrCommit
+	}
+
+	return transfer, nil
+}
+
+// перекладывает sum из самых старых партий отправителя в партии получателя.
+// Переданные баллы сохраняют срок сгорания, иначе переводами туда и обратно его можно было бы продлевать.
+func (s *Storage) transferPointLots(ctx context.Context, tx pgx.Tx, sender string, recipient string, sum float64) error {
+	var shortfall float64
+	err := tx.QueryRow(ctx, `
+		WITH ordered AS (
+			SELECT id, remaining, expires_at, SUM(remaining) OVER (ORDER BY accrued_at, id) - remaining AS before
+			FROM point_lots WHERE user_login = $1 AND remaining > 0
+		), consumed AS (
+			UPDATE point_lots p SET remaining = p.remaining - LEAST(o.remaining, $2::numeric - o.before)
+			FROM ordered o
+			WHERE p.id = o.id AND o.before < $2::numeric
+			RETURNING o.expires_at, LEAST(o.remaining, $2::numeric - o.before) AS taken
+		), received AS (
+			INSERT INTO point_lots (user_login, source, amount, remaining, expires_at)
+			SELECT $3, $4, taken, taken, expires_at FROM consumed
+		)
+		SELECT $2::numeric - COALESCE(SUM(taken), 0) FROM consumed;
+	`, sender, sum, recipient, models.PointLotTransfer).Scan(&shortfall)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось передать партии баллов", zap.Error(err))
+		return err
+	}
+	return lotShortfall(ctx, "transfer", shortfall)
+}
+
+// получает входящие и исходящие переводы пользователя, новые первыми
+func (s *Storage) GetUserTransfers(ctx context.Context, userLogin string) ([]models.Transfer, error) {
+
+	var transfers []models.Transfer
+	rows, err := s.pool.Query(ctx, `
+		SELECT id,
+			CASE WHEN sender_login = $1 THEN $2 ELSE $3 END,
+			CASE WHEN sender_login = $1 THEN recipient_login ELSE sender_login END,
+			sum, comment, created_at
+		FROM transfers WHERE sender_login = $1 OR recipient_login = $1
+		ORDER BY created_at DESC, id DESC;
+	`, userLogin, models.TransferOutgoing, models.TransferIncoming)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transfer models.Transfer
+		if err := rows.Scan(&transfer.ID, &transfer.Direction, &transfer.Counterparty, &transfer.Sum, &transfer.Comment, &transfer.CreatedAt); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	if len(transfers) == 0 {
+		return nil, ErrTransfersNotFound
+	}
+
+	return transfers, nil
+}
+
+// начисляет бонусы всех кампаний события, действующих сейчас; каждая кампания начисляет пользователю бонус один раз
+func (s *Storage) creditCampaigns(ctx context.Context, tx pgx.Tx, userLogin string, event models.CampaignEvent) error {
+	var credited float64
+	err := tx.QueryRow(ctx, `
+		WITH active AS (
+			SELECT id, amount FROM campaigns
+			WHERE event = $2 AND starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())
+		), credited AS (
+			INSERT INTO campaign_credits (campaign_id, user_login, amount)
+			SELECT id, $1, amount FROM active
+			ON CONFLICT (campaign_id, user_login) DO NOTHING
+			RETURNING amount
+		)
+		SELECT COALESCE(SUM(amount), 0) FROM credited;
+	`, userLogin, event).Scan(&credited)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось начислить бонусы кампаний", zap.Error(err))
+		return err
+	}
+	if credited == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current + $1 WHERE user_login = $2;`, credited, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось начислить бонусы кампаний", zap.Error(err))
+		return err
+	}
+	return s.addPointLot(ctx, tx, userLogin, models.PointLotCampaign, "", credited)
+}
+
+// создаёт бонусную кампанию; без starts_at кампания начинается сразу
+func (s *Storage) CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	var startsAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO campaigns (name, event, amount, starts_at, ends_at)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()), $5) RETURNING id, starts_at;
+	`, campaign.Name, campaign.Event, campaign.Amount, campaign.StartsAt, campaign.EndsAt).Scan(&campaign.ID, &startsAt)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось создать кампанию", zap.Error(err))
+		return campaign, ErrUpdate
+	}
+	campaign.StartsAt = &startsAt
+
+	return campaign, nil
+}
+
+// создаёт промокод
+func (s *Storage) CreatePromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO promo_codes (code, amount, expires_at, max_redemptions, per_user_limit) VALUES ($1, $2, $3, $4, $5);
+	`, promo.Code, promo.Amount, promo.ExpiresAt, promo.MaxRedemptions, promo.PerUserLimit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return promo, ErrPromoExists
+		}
+		logger.FromContext(ctx).Error("Не удалось создать промокод", zap.Error(err))
+		return promo, ErrUpdate
+	}
+
+	return promo, nil
+}
+
+// активирует промокод и начисляет баллы.
+// Строка промокода блокируется, поэтому параллельные активации не превышают ни общий, ни пользовательский лимит.
+func (s *Storage) RedeemPromoCode(ctx context.Context, userLogin string, code string) (models.PromoRedemption, error) {
+	redemption := models.PromoRedemption{Code: code}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return redemption, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	var expired, exhausted bool
+	var perUserLimit int
+	err = tx.QueryRow(ctx, `
+		SELECT amount, COALESCE(expires_at <= NOW(), false), COALESCE(redemptions >= max_redemptions, false), per_user_limit
+		FROM promo_codes WHERE code = $1 FOR UPDATE;
+	`, code).Scan(&redemption.Amount, &expired, &exhausted, &perUserLimit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return redemption, ErrPromoNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return redemption, ErrSelect
+	}
+	if expired {
+		return redemption, ErrPromoExpired
+	}
+	if exhausted {
+		return redemption, ErrPromoExhausted
+	}
+
+	var redeemed int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM promo_redemptions WHERE code = $1 AND user_login = $2;
+	`, code, userLogin).Scan(&redeemed)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return redemption, ErrSelect
+	}
+	if redeemed >= perUserLimit {
+		return redemption, ErrPromoRedeemed
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE promo_codes SET redemptions = redemptions + 1 WHERE code = $1;`, code)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось обновить промокод", zap.Error(err))
+		return redemption, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current + $1 WHERE user_login = $2;`, redemption.Amount, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось начислить баллы по промокоду", zap.Error(err))
+		return redemption, ErrUpdate
+	}
+
+	if err = s.addPointLot(ctx, tx, userLogin, models.PointLotPromo, "", redemption.Amount); err != nil {
+		return redemption, ErrUpdate
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO promo_redemptions (code, user_login, amount) VALUES ($1, $2, $3) RETURNING id, redeemed_at;
+	`, code, userLogin, redemption.Amount).Scan(&redemption.ID, &redemption.RedeemedAt)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать активацию промокода", zap.Error(err))
+		return redemption, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return redemption, ErrCommit
+	}
+
+	return redemption, nil
+}
+
+// блокирует строки баланса в порядке логинов: транзакции, меняющие балансы нескольких пользователей,
+// берут блокировки в одном порядке и не взаимоблокируются. Если баланса нет, возвращает его логин и pgx.ErrNoRows.
+func lockBalances(ctx context.Context, tx pgx.Tx, logins ...string) (string, error) {
+	sorted := append([]string(nil), logins...)
+	sort.Strings(sorted)
+	for _, login := range sorted {
+		var locked int
+		err := tx.QueryRow(ctx, `SELECT 1 FROM user_balance WHERE user_login = $1 FOR UPDATE;`, login).Scan(&locked)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return login, err
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось заблокировать баланс", zap.Error(err))
+			return login, err
+		}
+	}
+	return "", nil
+}
+
+// генерирует реферальный код из 8 символов без легко путаемых при вводе букв и цифр
+func newReferralCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(buf), nil
+}
+
+// начисляет награды за первый обработанный заказ приглашённого. Балансы обоих уже заблокированы,
+// поэтому проверка месячного лимита пригласившего не обходится параллельными начислениями.
+func (s *Storage) rewardReferral(ctx context.Context, tx pgx.Tx, referrer string, referee string, order string) error {
+	status := models.ReferralRewardCredited
+	referrerAmount, refereeAmount := s.referral.ReferrerReward, s.referral.RefereeReward
+
+	if s.referral.MonthlyLimit > 0 {
+		var rewarded int
+		err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM referral_rewards
+			WHERE referrer_login = $1 AND status = $2 AND created_at > NOW() - INTERVAL '1 month';
+		`, referrer, models.ReferralRewardCredited).Scan(&rewarded)
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+			return err
+		}
+		// сверх лимита не получает никто: массовые приглашения фиктивных аккаунтов не приносят баллов ни одной из сторон
+		if rewarded >= s.referral.MonthlyLimit {
+			status = models.ReferralRewardLimited
+			referrerAmount, refereeAmount = 0, 0
+		}
+	}
+
+	var id int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO referral_rewards (referrer_login, referee_login, "order", referrer_amount, referee_amount, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (referee_login) DO NOTHING RETURNING id;
+	`, referrer, referee, order, referrerAmount, refereeAmount, status).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// награда за приглашённого уже начислена по одному из прежних заказов
+		return nil
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать реферальную награду", zap.Error(err))
+		return err
+	}
+
+	for login, amount := range map[string]float64{referrer: referrerAmount, referee: refereeAmount} {
+		if amount <= 0 {
+			continue
+		}
+		_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current + $1 WHERE user_login = $2;`, amount, login)
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось начислить реферальную награду", zap.Error(err))
+			return err
+		}
+		if err = s.addPointLot(ctx, tx, login, models.PointLotReferral, order, amount); err != nil {
+			return err
+		}
+	}
+
+	logger.FromContext(ctx).Info("реферальная награда",
+		zap.String("referrer", referrer),
+		zap.String("referee", referee),
+		zap.String("status", string(status)),
+	)
+	return nil
+}
+
+// резервирует баллы под оплату заказа: они переходят из current в held и не могут быть потрачены до списания или отмены резерва
+func (s *Storage) CreateHold(ctx context.Context, userLogin string, order string, sum float64, ttl time.Duration, limits models.WithdrawalLimits) (models.Hold, error) {
+	hold := models.Hold{Order: order, Sum: sum, Status: models.HoldActive}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return hold, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	if _, err = lockBalances(ctx, tx, userLogin); err != nil {
+		return hold, ErrUpdate
+	}
+
+	// резерв превращается в списание, поэтому ограничения на списания проверяются уже при резервировании
+	if err = s.checkWithdrawalLimits(ctx, tx, userLogin, sum, limits); err != nil {
+		return hold, err
+	}
+
+	var paid bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM withdrawals WHERE "order" = $1)
+			OR EXISTS (SELECT 1 FROM holds WHERE "order" = $1 AND status = $2);
+	`, order, models.HoldActive).Scan(&paid)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return hold, ErrSelect
+	}
+	if paid {
+		return hold, ErrOrderPaid
+	}
+
+	// просроченные партии сгорают до резерва, чтобы их нельзя было зарезервировать
+	if _, err = s.expireUserLots(ctx, tx, userLogin); err != nil {
+		return hold, ErrUpdate
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current - $1, held = held + $1 WHERE user_login = $2;`, sum, userLogin)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "current_positive" {
+			return hold, ErrFewPoints
+		}
+		logger.FromContext(ctx).Error("Не удалось зарезервировать баллы", zap.Error(err))
+		return hold, ErrUpdate
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO holds (user_login, "order", sum, expires_at) VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		RETURNING id, created_at, expires_at;
+	`, userLogin, order, sum, ttl.Seconds()).Scan(&hold.ID, &hold.CreatedAt, &hold.ExpiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// параллельный резерв того же заказа другим пользователем
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "holds_active_order_idx" {
+			return hold, ErrOrderPaid
+		}
+		logger.FromContext(ctx).Error("Не удалось записать резерв", zap.Error(err))
+		return hold, ErrUpdate
+	}
+
+	if err = s.holdPointLots(ctx, tx, hold.ID, userLogin, sum); err != nil {
+		return hold, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return hold, ErrCommit
+	}
+
+	return hold, nil
+}
+
+// списывает зарезервированные баллы: sum или весь резерв, если sum не указан; остаток резерва возвращается на баланс
+func (s *Storage) CaptureHold(ctx context.Context, userLogin string, id int64, sum *float64) (models.Hold, error) {
+	return s.settleHold(ctx, userLogin, id, models.HoldCaptured, sum)
+}
+
+// отменяет резерв и возвращает баллы на баланс
+func (s *Storage) ReleaseHold(ctx context.Context, userLogin string, id int64) (models.Hold, error) {
+	return s.settleHold(ctx, userLogin, id, models.HoldReleased, nil)
+}
+
+// снимает просроченные резервы; каждый резерв снимается в своей транзакции
+func (s *Storage) ReleaseExpiredHolds(ctx context.Context) (int64, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, user_login FROM holds WHERE status = $1 AND expires_at <= NOW();
+	`, models.HoldActive)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return 0, ErrSelect
+	}
+	defer rows.Close()
+
+	type expiredHold struct {
+		id        int64
+		userLogin string
+	}
+	var holds []expiredHold
+	for rows.Next() {
+		var hold expiredHold
+		if err := rows.Scan(&hold.id, &hold.userLogin); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return 0, ErrScanRows
+		}
+		holds = append(holds, hold)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return 0, ErrRows
+	}
+	// освобождаем соединение до транзакций по резервам
+	rows.Close()
+
+	var released int64
+	var errs []error
+	for _, hold := range holds {
+		_, err := s.settleHold(ctx, hold.userLogin, hold.id, models.HoldExpired, nil)
+		// резерв успели списать или отменить после выборки
+		if errors.Is(err, ErrHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hold %d: %w", hold.id, err))
+			continue
+		}
+		released++
+	}
+
+	return released, errors.Join(errs...)
+}
+
+// закрывает активный резерв со статусом status. При списании captured баллов попадает в историю списаний,
+// остальное возвращается из held в current и в те партии, из которых было взято.
+func (s *Storage) settleHold(ctx context.Context, userLogin string, id int64, status models.HoldStatus, sum *float64) (models.Hold, error) {
+	var hold models.Hold
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return hold, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	// тот же порядок блокировок, что и при резервировании: сначала баланс, затем резерв и партии
+	if _, err = lockBalances(ctx, tx, userLogin); err != nil {
+		return hold, ErrUpdate
+	}
+
+	var expired bool
+	err = tx.QueryRow(ctx, `
+		SELECT id, "order", sum, captured, status, created_at, expires_at, expires_at <= NOW()
+		FROM holds WHERE id = $1 AND user_login = $2 FOR UPDATE;
+	`, id, userLogin).Scan(&hold.ID, &hold.Order, &hold.Sum, &hold.Captured, &hold.Status, &hold.CreatedAt, &hold.ExpiresAt, &expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return hold, ErrHoldNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return hold, ErrSelect
+	}
+	if hold.Status != models.HoldActive {
+		return hold, ErrHoldNotActive
+	}
+
+	var captured float64
+	switch status {
+	case models.HoldCaptured:
+		// просроченный резерв уже не гарантирует оплату, даже если фоновая задача ещё не успела его снять
+		if expired {
+			return hold, ErrHoldNotActive
+		}
+		captured = hold.Sum
+		if sum != nil {
+			if *sum > hold.Sum {
+				return hold, fmt.Errorf("%w: %.2f points held", ErrCaptureExceedsHold, hold.Sum)
+			}
+			captured = *sum
+		}
+	case models.HoldExpired:
+		if !expired {
+			return hold, ErrHoldNotActive
+		}
+	}
+	released := math.Round((hold.Sum-captured)*100) / 100
+
+	if released > 0 {
+		if err = s.releaseHoldLots(ctx, tx, hold.ID, released); err != nil {
+			return hold, ErrUpdate
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_balance SET held = held - $1, current = current + $2, withdrawn = withdrawn + $3 WHERE user_login = $4;
+	`, hold.Sum, released, captured, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось закрыть резерв на балансе", zap.Error(err))
+		return hold, ErrUpdate
+	}
+
+	if captured > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO withdrawals ("order", sum, user_login) VALUES ($1, $2, $3);
+		`, hold.Order, captured, userLogin)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			// по заказу успели списать баллы напрямую, пока действовал резерв
+			if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+				return hold, ErrOrderPaid
+			}
+			logger.FromContext(ctx).Error("Не удалось записать списание", zap.Error(err))
+			return hold, ErrUpdate
+		}
+		if err = addWithdrawalPayment(ctx, tx, userLogin, hold.Order, captured); err != nil {
+			return hold, ErrUpdate
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE holds SET status = $1, captured = $2, closed_at = NOW() WHERE id = $3;
+	`, status, captured, hold.ID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось закрыть резерв", zap.Error(err))
+		return hold, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return hold, ErrCommit
+	}
+
+	hold.Status = status
+	hold.Captured = captured
+	return hold, nil
+}
+
+// списывает sum с самых старых партий пользователя и запоминает, сколько взято из каждой партии
+func (s *Storage) holdPointLots(ctx context.Context, tx pgx.Tx, holdID int64, userLogin string, sum float64) error {
+	var shortfall float64
+	err := tx.QueryRow(ctx, `
+		WITH ordered AS (
+			SELECT id, remaining, SUM(remaining) OVER (ORDER BY accrued_at, id) - remaining AS before
+			FROM point_lots WHERE user_login = $1 AND remaining > 0
+		), consumed AS (
+			UPDATE point_lots p SET remaining = p.remaining - LEAST(o.remaining, $2::numeric - o.before)
+			FROM ordered o
+			WHERE p.id = o.id AND o.before < $2::numeric
+			RETURNING p.id, LEAST(o.remaining, $2::numeric - o.before) AS taken
+		), held AS (
+			INSERT INTO hold_lots (hold_id, lot_id, sum) SELECT $3, id, taken FROM consumed
+		)
+		SELECT $2::numeric - COALESCE(SUM(taken), 0) FROM consumed;
+	`, userLogin, sum, holdID).Scan(&shortfall)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось зарезервировать партии баллов", zap.Error(err))
+		return err
+	}
+	return lotShortfall(ctx, "hold", shortfall)
+}
+
+// возвращает sum в партии резерва, начиная с самых новых: при частичном списании
+// первыми тратятся баллы, которые сгорели бы раньше. Срок сгорания партий не меняется.
+func (s *Storage) releaseHoldLots(ctx context.Context, tx pgx.Tx, holdID int64, sum float64) error {
+	_, err := tx.Exec(ctx, `
+		WITH ordered AS (
+			SELECT h.lot_id, h.sum, SUM(h.sum) OVER (ORDER BY p.accrued_at DESC, p.id DESC) - h.sum AS before
+			FROM hold_lots h JOIN point_lots p ON p.id = h.lot_id
+			WHERE h.hold_id = $1
+		)
+		UPDATE point_lots p SET remaining = p.remaining + LEAST(o.sum, $2::numeric - o.before)
+		FROM ordered o
+		WHERE p.id = o.lot_id AND o.before < $2::numeric;
+	`, holdID, sum)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось вернуть баллы в партии", zap.Error(err))
+	}
+	return err
+}
+
+// запоминает адрес, с которого пользователь зарегистрировался или вошёл
+func (s *Storage) RecordUserIP(ctx context.Context, userLogin string, ip string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO user_ips (user_login, ip) VALUES ($1, $2)
+		ON CONFLICT (user_login, ip) DO UPDATE SET last_seen_at = NOW();
+	`, userLogin, ip)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать адрес пользователя", zap.Error(err))
+		return ErrUpdate
+	}
+	return nil
+}
+
+// собирает сведения о пользователе для антифрод-правил одним запросом
+func (s *Storage) GetFraudSignals(ctx context.Context, userLogin string, ip string) (models.FraudSignals, error) {
+	var signals models.FraudSignals
+	err := s.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM orders WHERE user_login = $1 AND uploaded_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM orders WHERE user_login = $1 AND status IN ('PROCESSED', 'INVALID')),
+			(SELECT COUNT(*) FROM orders WHERE user_login = $1 AND status = 'PROCESSED'),
+			(SELECT first_seen_at FROM user_ips WHERE user_login = $1 AND ip = $2),
+			(SELECT COUNT(*) FROM user_ips WHERE user_login = $1 AND ip <> $2),
+			(SELECT flagged_at IS NOT NULL FROM users WHERE login = $1);
+	`, userLogin, ip).Scan(
+		&signals.OrdersLastHour, &signals.FinishedOrders, &signals.ProcessedOrders,
+		&signals.IPFirstSeen, &signals.OtherIPs, &signals.Flagged,
+	)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return signals, ErrSelect
+	}
+	return signals, nil
+}
+
+// записывает решение антифрода и, если нужно, отправляет аккаунт на проверку.
+// Уже отмеченный аккаунт сохраняет время и причину первой отметки.
+func (s *Storage) RecordFraudDecision(ctx context.Context, verdict models.FraudVerdict) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO fraud_decisions (user_login, operation, ip, decision, rules, challenge_passed)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, verdict.UserLogin, verdict.Operation, verdict.IP, verdict.Decision, verdict.Rules, verdict.ChallengePassed)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось записать решение антифрода", zap.Error(err))
+		return ErrUpdate
+	}
+
+	if verdict.Flag {
+		_, err = tx.Exec(ctx, `
+			UPDATE users SET flagged_at = NOW(), flag_reason = $1 WHERE login = $2 AND flagged_at IS NULL;
+		`, strings.Join(verdict.Rules, ", "), verdict.UserLogin)
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось отметить аккаунт для проверки", zap.Error(err))
+			return ErrUpdate
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return ErrCommit
+	}
+	return nil
+}
+
+// получает аккаунты, отправленные на проверку, начиная с самых давних
+func (s *Storage) GetFlaggedUsers(ctx context.Context) ([]models.FlaggedUser, error) {
+
+	var users []models.FlaggedUser
+	rows, err := s.pool.Query(ctx, `
+		SELECT login, COALESCE(flag_reason, ''), flagged_at FROM users WHERE flagged_at IS NOT NULL ORDER BY flagged_at;
+	`)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.FlaggedUser
+		if err := rows.Scan(&user.Login, &user.Reason, &user.FlaggedAt); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	if len(users) == 0 {
+		return nil, ErrFlaggedNotFound
+	}
+
+	return users, nil
+}
+
+// снимает отметку о проверке после того, как аккаунт проверен
+func (s *Storage) ClearFraudFlag(ctx context.Context, userLogin string) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users SET flagged_at = NULL, flag_reason = NULL WHERE login = $1 AND flagged_at IS NOT NULL;
+	`, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось снять отметку о проверке", zap.Error(err))
+		return ErrUpdate
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFlagNotFound
+	}
+	return nil
+}
+
+const disputeColumns = `id, "order", claimant_login, owner_login, evidence, status, COALESCE(comment, ''), reclaimed, credited, created_at, resolved_at`
+
+func scanDispute(row pgx.Row, dispute *models.Dispute) error {
+	return row.Scan(&dispute.ID, &dispute.Order, &dispute.Claimant, &dispute.Owner, &dispute.Evidence, &dispute.Status,
+		&dispute.Comment, &dispute.Reclaimed, &dispute.Credited, &dispute.CreatedAt, &dispute.ResolvedAt)
+}
+
+// открывает спор о заказе, который загрузил другой пользователь
+func (s *Storage) CreateDispute(ctx context.Context, order string, claimant string, evidence string) (models.Dispute, error) {
+	var dispute models.Dispute
+
+	var owner string
+	err := s.pool.QueryRow(ctx, `SELECT user_login FROM orders WHERE number = $1;`, order).Scan(&owner)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dispute, ErrOrderNotFound
+		}
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return dispute, ErrSelect
+	}
+	if owner == claimant {
+		return dispute, ErrOrderAlreadyYours
+	}
+
+	err = scanDispute(s.pool.QueryRow(ctx, `
+		INSERT INTO order_disputes ("order", claimant_login, owner_login, evidence) VALUES ($1, $2, $3, $4)
+		RETURNING `+disputeColumns+`;
+	`, order, claimant, owner, evidence), &dispute)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "order_disputes_open_idx" {
+			return dispute, ErrDisputeExists
+		}
+		logger.FromContext(ctx).Error("Не удалось открыть спор", zap.Error(err))
+		return dispute, ErrUpdate
+	}
+	return dispute, nil
+}
+
+// получает споры в статусе status, пустой статус — все споры; сначала самые давние
+func (s *Storage) GetDisputes(ctx context.Context, status models.DisputeStatus) ([]models.Dispute, error) {
+
+	var disputes []models.Dispute
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+disputeColumns+` FROM order_disputes WHERE $1 = '' OR status = $1 ORDER BY created_at, id;
+	`, status)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dispute models.Dispute
+		if err := scanDispute(rows, &dispute); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	if len(disputes) == 0 {
+		return nil, ErrDisputesNotFound
+	}
+
+	return disputes, nil
+}
+
+// отклоняет спор, заказ остаётся у владельца
+func (s *Storage) RejectDispute(ctx context.Context, id int64, comment string) (models.Dispute, error) {
+	var dispute models.Dispute
+	err := scanDispute(s.pool.QueryRow(ctx, `
+		UPDATE order_disputes SET status = $1, comment = $2, resolved_at = NOW() WHERE id = $3 AND status = $4
+		RETURNING `+disputeColumns+`;
+	`, models.DisputeRejected, comment, id, models.DisputeOpen), &dispute)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return dispute, s.disputeNotOpen(ctx, id)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось отклонить спор", zap.Error(err))
+		return dispute, ErrUpdate
+	}
+	return dispute, nil
+}
+
+// передаёт заказ заявителю. Если заказ уже обработан, начисление переходит вместе с ним:
+// прежний владелец теряет начисленные за заказ баллы, но не больше, чем у него осталось,
+// а заявитель получает начисление с множителем своего уровня. Оба баланса меняются в одной транзакции.
+func (s *Storage) TransferDisputedOrder(ctx context.Context, id int64, comment string) (models.Dispute, error) {
+	var dispute models.Dispute
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return dispute, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	err = scanDispute(tx.QueryRow(ctx, `
+		SELECT `+disputeColumns+` FROM order_disputes WHERE id = $1 FOR UPDATE;
+	`, id), &dispute)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dispute, ErrDisputeNotFound
+		}
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return dispute, ErrSelect
+	}
+	if dispute.Status != models.DisputeOpen {
+		return dispute, ErrDisputeNotOpen
+	}
+
+	// заказ блокируется до балансов, как при начислении и сверке
+	var owner, status string
+	var accrual, multiplier float64
+	err = tx.QueryRow(ctx, `
+		SELECT user_login, status, COALESCE(accrual, 0), multiplier FROM orders WHERE number = $1 FOR UPDATE;
+	`, dispute.Order).Scan(&owner, &status, &accrual, &multiplier)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return dispute, ErrSelect
+	}
+	// заказ мог перейти к заявителю по другому его спору
+	if owner == dispute.Claimant {
+		return dispute, ErrOrderAlreadyYours
+	}
+
+	if _, err = lockBalances(ctx, tx, owner, dispute.Claimant); err != nil {
+		return dispute, ErrUpdate
+	}
+
+	var reclaimed, credited float64
+	if status == "PROCESSED" {
+		// сгоревшие баллы уже не вернуть, поэтому забираем не больше оставшегося после сгорания
+		if _, err = s.expireUserLots(ctx, tx, owner); err != nil {
+			return dispute, ErrUpdate
+		}
+		var current float64
+		err = tx.QueryRow(ctx, `SELECT current FROM user_balance WHERE user_login = $1;`, owner).Scan(&current)
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+			return dispute, ErrSelect
+		}
+		reclaimed = math.Min(math.Round(accrual*multiplier*100)/100, current)
+		if reclaimed > 0 {
+			_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current - $1 WHERE user_login = $2;`, reclaimed, owner)
+			if err != nil {
+				logger.FromContext(ctx).Error("Не удалось списать баллы прежнего владельца", zap.Error(err))
+				return dispute, ErrUpdate
+			}
+			if err = s.consumePointLots(ctx, tx, owner, reclaimed); err != nil {
+				return dispute, ErrUpdate
+			}
+		}
+
+		err = tx.QueryRow(ctx, `
+			SELECT COALESCE(t.multiplier, 1) FROM users u LEFT JOIN loyalty_tiers t ON t.name = u.tier WHERE u.login = $1;
+		`, dispute.Claimant).Scan(&multiplier)
+		if err != nil {
+			logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+			return dispute, ErrSelect
+		}
+		credited = math.Round(accrual*multiplier*100) / 100
+		if credited > 0 {
+			_, err = tx.Exec(ctx, `UPDATE user_balance SET current = current + $1 WHERE user_login = $2;`, credited, dispute.Claimant)
+			if err != nil {
+				logger.FromContext(ctx).Error("Не удалось начислить баллы заявителю", zap.Error(err))
+				return dispute, ErrUpdate
+			}
+			if err = s.addPointLot(ctx, tx, dispute.Claimant, models.PointLotDispute, dispute.Order, credited); err != nil {
+				return dispute, ErrUpdate
+			}
+		}
+	}
+
+	// необработанный заказ система лояльности начислит уже новому владельцу
+	_, err = tx.Exec(ctx, `
+		UPDATE orders SET user_login = $1, multiplier = $2, updated_at = NOW() WHERE number = $3;
+	`, dispute.Claimant, multiplier, dispute.Order)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось передать заказ", zap.Error(err))
+		return dispute, ErrUpdate
+	}
+
+	err = scanDispute(tx.QueryRow(ctx, `
+		UPDATE order_disputes SET status = $1, comment = $2, reclaimed = $3, credited = $4, resolved_at = NOW() WHERE id = $5
+		RETURNING `+disputeColumns+`;
+	`, models.DisputeTransferred, comment, reclaimed, credited, id), &dispute)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось закрыть спор", zap.Error(err))
+		return dispute, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return dispute, ErrCommit
+	}
+
+	return dispute, nil
+}
+
+// различает несуществующий и уже закрытый спор
+func (s *Storage) disputeNotOpen(ctx context.Context, id int64) error {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM order_disputes WHERE id = $1);`, id).Scan(&exists)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return ErrSelect
+	}
+	if !exists {
+		return ErrDisputeNotFound
+	}
+	return ErrDisputeNotOpen
+}
+
+// получает историю баланса пользователя от новых операций к старым. Зачисления берутся из партий баллов,
+// поэтому история сходится с балансом, даже если партия пришла из операции без собственной таблицы.
+func (s *Storage) GetBalanceHistory(ctx context.Context, userLogin string) ([]models.BalanceEntry, error) {
+
+	var history []models.BalanceEntry
+	rows, err := s.pool.Query(ctx, `
+		SELECT kind, COALESCE(source, ''), COALESCE("order", ''), sum, created_at FROM (
+			SELECT 'CREDIT' AS kind, source, "order", amount AS sum, accrued_at AS created_at
+			FROM point_lots WHERE user_login = $1
+			UNION ALL
+			SELECT 'WITHDRAWAL', NULL, "order", -sum, created_at FROM withdrawal_payments WHERE user_login = $1
+			UNION ALL
+			SELECT 'EXPIRATION', NULL, NULL, -sum, expired_at FROM point_expirations WHERE user_login = $1
+			UNION ALL
+			SELECT 'TRANSFER_OUT', NULL, NULL, -sum, created_at FROM transfers WHERE sender_login = $1
+			UNION ALL
+			SELECT 'ADJUSTMENT', NULL, "order", applied, created_at FROM accrual_adjustments
+			WHERE user_login = $1 AND applied < 0
+			UNION ALL
+			SELECT 'DISPUTE', NULL, "order", -reclaimed, resolved_at FROM order_disputes
+			WHERE owner_login = $1 AND status = 'TRANSFERRED' AND reclaimed > 0
+		) history
+		ORDER BY created_at DESC;
+	`, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return nil, ErrSelect
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.BalanceEntry
+		if err := rows.Scan(&entry.Kind, &entry.Source, &entry.Order, &entry.Sum, &entry.CreatedAt); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return nil, ErrScanRows
+		}
+		history = append(history, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return nil, ErrRows
+	}
+
+	return history, nil
+}
+
+// планирует удаление аккаунта через delay. Повторный запрос не сдвигает уже назначенный срок.
+func (s *Storage) RequestAccountDeletion(ctx context.Context, userLogin string, delay time.Duration) (models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	err := s.pool.QueryRow(ctx, `
+		UPDATE users SET
+			deletion_requested_at = COALESCE(deletion_requested_at, NOW()),
+			deletion_due_at = COALESCE(deletion_due_at, NOW() + make_interval(secs => $2))
+		WHERE login = $1 AND deleted_at IS NULL
+		RETURNING deletion_requested_at, deletion_due_at;
+	`, userLogin, delay.Seconds()).Scan(&deletion.RequestedAt, &deletion.DeleteAfter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return deletion, ErrUserNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось запланировать удаление аккаунта", zap.Error(err))
+		return deletion, ErrUpdate
+	}
+	return deletion, nil
+}
+
+// отменяет запланированное удаление. Если аккаунт уже обезличен, возвращает ErrUserNotFound:
+// вход не должен выдать токен логину, которого больше нет.
+func (s *Storage) CancelAccountDeletion(ctx context.Context, userLogin string) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users SET deletion_requested_at = NULL, deletion_due_at = NULL
+		WHERE login = $1 AND deletion_due_at IS NOT NULL AND deleted_at IS NULL;
+	`, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось отменить удаление аккаунта", zap.Error(err))
+		return ErrUpdate
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE login = $1);`, userLogin).Scan(&exists)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return ErrSelect
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return ErrDeletionNotFound
+}
+
+// обезличивает аккаунты, срок удаления которых истёк. Каждый аккаунт обезличивается в своей транзакции,
+// ошибка одного не мешает остальным. Возвращает число обезличенных аккаунтов.
+func (s *Storage) AnonymizeDueAccounts(ctx context.Context) (int64, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT login FROM users WHERE deletion_due_at <= NOW() AND deleted_at IS NULL;
+	`)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return 0, ErrSelect
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var userLogin string
+		if err := rows.Scan(&userLogin); err != nil {
+			logger.FromContext(ctx).Error("Ошибка при сканировании строки", zap.Error(err))
+			return 0, ErrScanRows
+		}
+		users = append(users, userLogin)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при итерации по строкам", zap.Error(err))
+		return 0, ErrRows
+	}
+	// освобождаем соединение до транзакций по пользователям
+	rows.Close()
+
+	var anonymized int64
+	var errs []error
+	for i, userLogin := range users {
+		done, err := s.anonymizeAccount(ctx, userLogin)
+		if err != nil {
+			// исходный логин в ошибку не попадает: это те самые данные, которые удаляем
+			errs = append(errs, fmt.Errorf("account %d of %d: %w", i+1, len(users), err))
+			continue
+		}
+		if done {
+			anonymized++
+		}
+	}
+
+	return anonymized, errors.Join(errs...)
+}
+
+// заменяет логин случайным, стирает пароль и личные данные. Заказы, списания, переводы и партии баллов
+// остаются для учёта и переходят на новый логин через ON UPDATE CASCADE.
+// Возвращает false, если удаление успели отменить.
+func (s *Storage) anonymizeAccount(ctx context.Context, userLogin string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Ошибка при начале транзакции", zap.Error(err))
+		return false, ErrBeginTransaction
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Error("Ошибка при откате транзакции", zap.Error(rbErr))
+		}
+	}()
+
+	// тот же порядок блокировок, что и у операций с баллами: сначала баланс, затем остальное
+	if _, err = lockBalances(ctx, tx, userLogin); err != nil {
+		return false, ErrUpdate
+	}
+
+	// вход мог отменить удаление после выборки
+	var due bool
+	err = tx.QueryRow(ctx, `
+		SELECT deletion_due_at <= NOW() AND deleted_at IS NULL FROM users WHERE login = $1 FOR UPDATE;
+	`, userLogin).Scan(&due)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось выполнить запрос", zap.Error(err))
+		return false, ErrSelect
+	}
+	if !due {
+		return false, nil
+	}
+
+	anonLogin, err := newAnonymousLogin()
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось сгенерировать обезличенный логин", zap.Error(err))
+		return false, ErrUpdate
+	}
+	referralCode, err := newReferralCode()
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось сгенерировать реферальный код", zap.Error(err))
+		return false, ErrUpdate
+	}
+
+	// адреса, решения антифрода и сохранённые ответы для учёта не нужны
+	for _, query := range []string{
+		`DELETE FROM user_ips WHERE user_login = $1;`,
+		`DELETE FROM fraud_decisions WHERE user_login = $1;`,
+		`DELETE FROM idempotency_keys WHERE user_login = $1;`,
+		`UPDATE order_disputes SET evidence = '' WHERE claimant_login = $1;`,
+		`UPDATE transfers SET comment = '' WHERE sender_login = $1 OR recipient_login = $1;`,
+	} {
+		if _, err = tx.Exec(ctx, query, userLogin); err != nil {
+			logger.FromContext(ctx).Error("Не удалось удалить личные данные", zap.Error(err))
+			return false, ErrUpdate
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET login = $1, password = '', referral_code = $2, flagged_at = NULL, flag_reason = NULL, deleted_at = NOW()
+		WHERE login = $3;
+	`, anonLogin, referralCode, userLogin)
+	if err != nil {
+		logger.FromContext(ctx).Error("Не удалось обезличить аккаунт", zap.Error(err))
+		return false, ErrUpdate
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.FromContext(ctx).Error("Ошибка при фиксации транзакции", zap.Error(err))
+		return false, ErrCommit
+	}
+
+	return true, nil
+}
+
+// логин обезличенного аккаунта; пустой пароль не совпадает ни с одним хешем, поэтому войти под ним нельзя
+func newAnonymousLogin() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "deleted-" + hex.EncodeToString(buf), nil
+}
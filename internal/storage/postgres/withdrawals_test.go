@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundAmount(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+
+	// 0.3 - 0.1 в float64 даёт 0.19999999999999998, а вернуть можно ровно 0.2
+	amount, err := refundAmount(0.3, 0.1, ptr(0.2))
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, amount)
+
+	// полный возврат остатка записывается в копейках
+	amount, err = refundAmount(0.3, 0.1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, amount)
+
+	_, err = refundAmount(0.3, 0.1, ptr(0.21))
+	assert.ErrorIs(t, err, ErrRefundExceedsSum)
+
+	_, err = refundAmount(0.3, 0.3, nil)
+	assert.ErrorIs(t, err, ErrAlreadyRefunded)
+}
+
+func TestExceedsLimit(t *testing.T) {
+	// 0.1 + 0.2 в float64 даёт 0.30000000000000004, но в лимит 0.3 укладывается
+	assert.False(t, exceedsLimit(0.1, 0.2, 0.3))
+	assert.True(t, exceedsLimit(0.1, 0.21, 0.3))
+	assert.False(t, exceedsLimit(0, 0.3, 0.3))
+	assert.True(t, exceedsLimit(0, 0.31, 0.3))
+}
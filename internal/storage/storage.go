@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/zYoma/gophermart/internal/integrations/loyalty"
 	"github.com/zYoma/gophermart/internal/models"
@@ -13,13 +14,51 @@ type StorageProvider interface {
 
 type Provider interface {
 	Init() error
-	CreateUser(ctx context.Context, login string, password string) error
+	CreateUser(ctx context.Context, login string, password string, referralCode string) error
 	GetPasswordHash(ctx context.Context, login string) (string, error)
 	CreateOrder(ctx context.Context, number string, login string) error
 	GetRegisteresOrders(ctx context.Context) ([]string, error)
-	UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) error
+	CountPendingOrders(ctx context.Context) (map[string]int64, error)
+	UpdateOrderAndAccrualPoints(ctx context.Context, orderData *loyalty.OrderResponse) (float64, error)
 	GetUserOrders(ctx context.Context, userLogin string) ([]models.Order, error)
 	GetUserBalance(ctx context.Context, userLogin string) (models.Balance, error)
-	Withdrow(ctx context.Context, sum float64, userLogin string, order string) error
+	GetExpiringPoints(ctx context.Context, userLogin string, before time.Time) ([]models.ExpiringPoints, error)
+	ExpirePoints(ctx context.Context) (models.ExpirationReport, error)
+	GetUserProfile(ctx context.Context, userLogin string) (models.Profile, error)
+	RecomputeTiers(ctx context.Context) (int64, error)
+	Withdrow(ctx context.Context, sum float64, userLogin string, order string, partial bool, limits models.WithdrawalLimits) error
 	GetUserWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawn, error)
+	CreateHold(ctx context.Context, userLogin string, order string, sum float64, ttl time.Duration, limits models.WithdrawalLimits) (models.Hold, error)
+	CaptureHold(ctx context.Context, userLogin string, id int64, sum *float64) (models.Hold, error)
+	ReleaseHold(ctx context.Context, userLogin string, id int64) (models.Hold, error)
+	ReleaseExpiredHolds(ctx context.Context) (int64, error)
+	Transfer(ctx context.Context, sender string, recipient string, sum float64, comment string, limits models.TransferLimits) (models.Transfer, error)
+	GetUserTransfers(ctx context.Context, userLogin string) ([]models.Transfer, error)
+	CreatePromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error)
+	RedeemPromoCode(ctx context.Context, userLogin string, code string) (models.PromoRedemption, error)
+	CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error)
+	RecordUserIP(ctx context.Context, userLogin string, ip string) error
+	GetFraudSignals(ctx context.Context, userLogin string, ip string) (models.FraudSignals, error)
+	RecordFraudDecision(ctx context.Context, verdict models.FraudVerdict) error
+	GetFlaggedUsers(ctx context.Context) ([]models.FlaggedUser, error)
+	ClearFraudFlag(ctx context.Context, userLogin string) error
+	CreateDispute(ctx context.Context, order string, claimant string, evidence string) (models.Dispute, error)
+	GetDisputes(ctx context.Context, status models.DisputeStatus) ([]models.Dispute, error)
+	TransferDisputedOrder(ctx context.Context, id int64, comment string) (models.Dispute, error)
+	RejectDispute(ctx context.Context, id int64, comment string) (models.Dispute, error)
+	GetBalanceHistory(ctx context.Context, userLogin string) ([]models.BalanceEntry, error)
+	RequestAccountDeletion(ctx context.Context, userLogin string, delay time.Duration) (models.AccountDeletion, error)
+	CancelAccountDeletion(ctx context.Context, userLogin string) error
+	AnonymizeDueAccounts(ctx context.Context) (int64, error)
+	RefundWithdrawal(ctx context.Context, order string, sum *float64, reason string) (models.Withdrawn, error)
+	GetProcessedOrdersSince(ctx context.Context, since time.Time) ([]models.ProcessedOrder, error)
+	AdjustOrderAccrual(ctx context.Context, order string, newAccrual float64, policy models.NegativeBalancePolicy) (*models.AccrualAdjustment, error)
+	GetAccrualAdjustments(ctx context.Context, since time.Time) ([]models.AccrualAdjustment, error)
+	ReserveIdempotencyKey(ctx context.Context, userLogin string, key string, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, userLogin string, key string, record models.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, userLogin string, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+	Close() error
 }